@@ -9,6 +9,7 @@ import (
 	_ "github.com/openchami/coresmd/plugin/coredns"
 
 	// CoreDNS plugins activated below
+	_ "github.com/coredns/coredns/plugin/acl"
 	_ "github.com/coredns/coredns/plugin/bind"
 	_ "github.com/coredns/coredns/plugin/cache"
 	_ "github.com/coredns/coredns/plugin/debug"
@@ -26,6 +27,7 @@ import (
 	_ "github.com/coredns/coredns/plugin/reload"
 	_ "github.com/coredns/coredns/plugin/root"
 	_ "github.com/coredns/coredns/plugin/template"
+	_ "github.com/coredns/coredns/plugin/transfer"
 	_ "github.com/coredns/coredns/plugin/whoami"
 	_ "github.com/coredns/rrl/plugins/rrl"
 	_ "github.com/ori-edge/k8s_gateway"
@@ -42,6 +44,8 @@ var directives = []string{
 	"health",
 	"ready",
 	"prometheus",
+	"acl",
+	"transfer",
 	// Your plugin
 	"coresmd",
 	"k8s_gateway",
@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"sync"
 	"time"
 
@@ -17,7 +18,12 @@ import (
 	"github.com/openchami/coresmd/internal/smdclient"
 )
 
-const DefaultCacheValid = "30s"
+const (
+	DefaultCacheValid = "30s"
+
+	ethIfacesPath  = "/hsm/v2/Inventory/EthernetInterfaces"
+	componentsPath = "/hsm/v2/State/Components"
+)
 
 type Cache struct {
 	Client      *smdclient.SmdClient
@@ -26,8 +32,75 @@ type Cache struct {
 	Mutex       sync.RWMutex
 	Log         *logrus.Entry
 
+	// FullResync enables incremental refreshes when nonzero. Refreshes only
+	// fetch records that changed since the previous refresh, and a full
+	// resync (which also picks up deletions) is performed once FullResync
+	// has elapsed since the last one. If zero, every refresh is a full one.
+	FullResync      time.Duration
+	LastFullRefresh time.Time
+
+	// Number of full and incremental refreshes that have completed
+	// successfully
+	FullRefreshes        uint64
+	IncrementalRefreshes uint64
+
+	// Number of change notification events applied (see ApplyEvent)
+	EventsApplied uint64
+
+	// EventToken supplies the bearer token that requests to the event
+	// webhook (see EventHandler) must present. If nil, every request to the
+	// webhook is rejected.
+	EventToken smdclient.TokenSource
+
+	// SnapshotPath, if set, is the file the cache is written to after each
+	// successful refresh and loaded from if the initial refresh fails.
+	// LoadedFromSnapshot is true while the cache contains data loaded from the
+	// snapshot that has not since been refreshed from SMD.
+	SnapshotPath       string
+	LoadedFromSnapshot bool
+
+	// EthernetInterfaces are keyed by their hardware address normalized with
+	// NormalizeHWAddr, and keep their MACAddress as recorded in SMD
 	EthernetInterfaces map[string]smdclient.EthernetInterface
 	Components         map[string]smdclient.Component
+
+	// EthernetInterfaces whose hardware address could not be normalized, by
+	// MACAddress (which is also their key in EthernetInterfaces), and
+	// EthernetInterfaces left out because their hardware address is the same
+	// as that of another after normalization. Each collision is recorded
+	// once, until the next full refresh or until either interface is
+	// deleted.
+	InvalidHWAddrs   map[string]smdclient.EthernetInterface
+	HWAddrCollisions []HWAddrCollision
+
+	// Keys of EthernetInterfaces with EUI-64 (8-byte) or IPoIB (20-byte)
+	// hardware addresses by the InfiniBand GUID they contain (the address
+	// itself or its last 8 bytes), so that IPoIB clients, which identify
+	// themselves by GUID, can be looked up without scanning
+	// EthernetInterfaces. See GUIDKey.
+	GUIDs map[string]string
+
+	// Group labels (sorted) and partition names by component ID, see
+	// Membership
+	Groups     map[string][]string
+	Partitions map[string]string
+
+	// Generation is incremented (with Mutex held for writing) whenever the
+	// contents of EthernetInterfaces, Components, Groups, or Partitions
+	// change, so that data derived from them can tell when it is out of date.
+	Generation uint64
+
+	// OnUpdate, if set, is called without any locks held after the contents
+	// of the cache change.
+	OnUpdate func()
+
+	// Only accessed by refreshes, which are serialized by refreshMutex
+	refreshMutex    sync.Mutex
+	ifaceValidators smdclient.Validators
+	compValidators  smdclient.Validators
+	groupValidators smdclient.Validators
+	partValidators  smdclient.Validators
+	ifaceWatermark  time.Time // newest EthernetInterface LastUpdate seen
 }
 
 func NewCache(log *logrus.Entry, duration string, client *smdclient.SmdClient) (*Cache, error) {
@@ -51,77 +124,312 @@ func NewCache(log *logrus.Entry, duration string, client *smdclient.SmdClient) (
 	return c, nil
 }
 
+// Refresh updates the cache from SMD. If incremental refreshes are enabled
+// (FullResync is nonzero), only changed records are fetched unless a full
+// resync is due.
 func (c *Cache) Refresh() error {
-	c.Log.Info("initiating cache refresh")
-
 	if c == nil {
 		return fmt.Errorf("cache is nil")
 	}
 
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+
+	c.Mutex.RLock()
+	full := c.FullResync <= 0 ||
+		c.EthernetInterfaces == nil ||
+		c.Components == nil ||
+		time.Since(c.LastFullRefresh) >= c.FullResync
+	c.Mutex.RUnlock()
+
+	var err error
+	if full {
+		err = c.fullRefresh()
+	} else {
+		err = c.incrementalRefresh()
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.SnapshotPath != "" {
+		if err := c.WriteSnapshot(); err != nil {
+			c.Log.Errorf("failed to write cache snapshot: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// notifyUpdate calls OnUpdate, if set.
+func (c *Cache) notifyUpdate() {
+	if c.OnUpdate != nil {
+		c.OnUpdate()
+	}
+}
+
+// fullRefresh fetches all EthernetInterfaces, Components, groups, and
+// partitions from SMD and replaces the contents of the cache with them.
+func (c *Cache) fullRefresh() error {
+	c.Log.Info("initiating full cache refresh")
+
 	// Fetch data
+	membership, err := c.fetchMembership(false)
+	if err != nil {
+		return err
+	}
 	c.Log.Debug("fetching EthernetInterfaces")
-	ethIfaceData, err := c.Client.APIGet("/hsm/v2/Inventory/EthernetInterfaces")
+	ethIfaceData, ifaceValidators, _, err := c.Client.APIGetConditional(ethIfacesPath, nil, smdclient.Validators{})
 	if err != nil {
 		return fmt.Errorf("failed to fetch EthernetInterfaces from SMD: %w", err)
 	}
-	c.Log.Debug("EthernetInterfaces: " + string(ethIfaceData))
+	c.Log.Debugf("fetched %d bytes of EthernetInterfaces", len(ethIfaceData))
 	c.Log.Debug("fetching Components")
-	compsData, err := c.Client.APIGet("/hsm/v2/State/Components")
+	compsData, compValidators, _, err := c.Client.APIGetConditional(componentsPath, nil, smdclient.Validators{})
 	if err != nil {
 		return fmt.Errorf("failed to fetch Components from SMD: %w", err)
 	}
-	c.Log.Debug("Components: " + string(compsData))
+	c.Log.Debugf("fetched %d bytes of Components", len(compsData))
 
 	// Unmarshal it
 	c.Log.Debug("unmarshaling EthernetInterfaces")
-	var ethIfaceSlice []smdclient.EthernetInterface
-	err = json.Unmarshal(ethIfaceData, &ethIfaceSlice)
+	ethIfaceSlice, err := unmarshalEthernetInterfaces(ethIfaceData)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal EthernetInterface data: %w", err)
+		return err
 	}
 	c.Log.Debug("unmarshaling Components")
-	var compsStruct struct {
-		Components []smdclient.Component `json:"Components"`
-	}
-	err = json.Unmarshal(compsData, &compsStruct)
+	compSlice, err := unmarshalComponents(compsData)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal Components data: %w", err)
+		return err
 	}
 
 	// Organize it to be referenced via map
-	c.Log.Debug("organizing EthernetInterfaces into map")
-	eiMap := make(map[string]smdclient.EthernetInterface)
-	for _, ei := range ethIfaceSlice {
-		eiMap[ei.MACAddress] = ei
-	}
 	c.Log.Debug("organizing Component into map")
 	compMap := make(map[string]smdclient.Component)
-	for _, comp := range compsStruct.Components {
+	for _, comp := range compSlice {
 		compMap[comp.ID] = comp
 	}
 
+	c.ifaceValidators = ifaceValidators
+	c.compValidators = compValidators
+	c.ifaceWatermark = newestLastUpdate(time.Time{}, ethIfaceSlice)
+
 	// Update cache with info
 	c.Log.Debug("updating cache with map data")
 	c.Mutex.Lock()
-	c.EthernetInterfaces = eiMap
+	c.indexIfaces(ethIfaceSlice)
 	c.Components = compMap
+	membership.apply(c)
 	c.LastUpdated = time.Now()
+	c.LastFullRefresh = c.LastUpdated
+	c.LoadedFromSnapshot = false
+	c.FullRefreshes++
+	c.Generation++
+	numIfaces, numInvalid, numCollisions := len(c.EthernetInterfaces), len(c.InvalidHWAddrs), len(c.HWAddrCollisions)
 	c.Mutex.Unlock()
-	c.Log.Infof("Cache updated with %d EthernetInterfaces and %d Components", len(eiMap), len(compMap))
-	c.Log.Debugf("EthernetInterfaces: %v", eiMap)
-	c.Log.Debugf("Components: %v", compMap)
+	c.Log.Infof("Cache updated with %d EthernetInterfaces and %d Components (%d in groups, %d in partitions)",
+		numIfaces, len(compMap), len(membership.groups), len(membership.partitions))
+	if numInvalid > 0 || numCollisions > 0 {
+		c.Log.Warnf("%d EthernetInterfaces have unparseable hardware addresses and %d were ignored for duplicate hardware addresses", numInvalid, numCollisions)
+	}
+	c.notifyUpdate()
 
 	return nil
 }
 
+// incrementalRefresh fetches EthernetInterfaces and Components that changed
+// since the last refresh and merges them into the cache.
+//
+// EthernetInterfaces are requested with SMD's NewerThan filter, using the
+// newest LastUpdate timestamp seen so far. If SMD did not report LastUpdate
+// timestamps, the full list is requested conditionally instead. Deleted
+// EthernetInterfaces are only removed by full resyncs. Components, groups,
+// and partitions are requested in full, conditionally if SMD returned ETag or
+// Last-Modified headers for them, since changes such as a Component being
+// disabled don't touch its EthernetInterfaces.
+func (c *Cache) incrementalRefresh() error {
+	c.Log.Info("initiating incremental cache refresh")
+
+	membership, err := c.fetchMembership(true)
+	if err != nil {
+		return err
+	}
+
+	// Fetch changed EthernetInterfaces
+	var (
+		changedIfaces  []smdclient.EthernetInterface
+		replaceIfaces  bool // changedIfaces is the full list
+		ifaceValidator = c.ifaceValidators
+	)
+	if !c.ifaceWatermark.IsZero() {
+		query := url.Values{"NewerThan": []string{c.ifaceWatermark.Format(time.RFC3339Nano)}}
+		c.Log.Debugf("fetching EthernetInterfaces newer than %s", query.Get("NewerThan"))
+		data, _, _, err := c.Client.APIGetConditional(ethIfacesPath, query, smdclient.Validators{})
+		if err != nil {
+			return fmt.Errorf("failed to fetch changed EthernetInterfaces from SMD: %w", err)
+		}
+		if changedIfaces, err = unmarshalEthernetInterfaces(data); err != nil {
+			return err
+		}
+	} else {
+		c.Log.Debug("fetching EthernetInterfaces (conditional)")
+		data, v, notModified, err := c.Client.APIGetConditional(ethIfacesPath, nil, c.ifaceValidators)
+		if err != nil {
+			return fmt.Errorf("failed to fetch EthernetInterfaces from SMD: %w", err)
+		}
+		if !notModified {
+			if changedIfaces, err = unmarshalEthernetInterfaces(data); err != nil {
+				return err
+			}
+			replaceIfaces = true
+			ifaceValidator = v
+		}
+	}
+	c.Log.Debugf("fetched %d changed EthernetInterfaces", len(changedIfaces))
+
+	// Fetch Components if they changed, or if SMD cannot tell
+	var (
+		comps         []smdclient.Component
+		replaceComps  bool // comps is the full list
+		compValidator = c.compValidators
+	)
+	if c.compValidators.IsZero() {
+		c.Log.Debug("fetching Components")
+	} else {
+		c.Log.Debug("fetching Components (conditional)")
+	}
+	data, v, notModified, err := c.Client.APIGetConditional(componentsPath, nil, c.compValidators)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Components from SMD: %w", err)
+	}
+	if !notModified {
+		if comps, err = unmarshalComponents(data); err != nil {
+			return err
+		}
+		replaceComps = true
+		compValidator = v
+	}
+
+	c.ifaceValidators = ifaceValidator
+	c.compValidators = compValidator
+	c.ifaceWatermark = newestLastUpdate(c.ifaceWatermark, changedIfaces)
+
+	// Merge changes into cache
+	c.Log.Debug("merging changes into cache")
+	c.Mutex.Lock()
+	if replaceIfaces {
+		c.indexIfaces(changedIfaces)
+	} else {
+		for _, ei := range changedIfaces {
+			c.putIface(ei, true)
+		}
+	}
+	changedComps := 0
+	if replaceComps {
+		compMap := make(map[string]smdclient.Component, len(comps))
+		for _, comp := range comps {
+			compMap[comp.ID] = comp
+		}
+		changedComps = countChangedComponents(c.Components, compMap)
+		c.Components = compMap
+	}
+	membershipChanged := membership.apply(c)
+	changed := replaceIfaces || len(changedIfaces) > 0 || changedComps > 0 || membershipChanged
+	if changed {
+		c.Generation++
+	}
+	c.LastUpdated = time.Now()
+	c.LoadedFromSnapshot = false
+	c.IncrementalRefreshes++
+	numIfaces, numComps := len(c.EthernetInterfaces), len(c.Components)
+	c.Mutex.Unlock()
+	c.Log.Infof("Cache incrementally updated with %d changed EthernetInterfaces and %d changed Components (%d EthernetInterfaces and %d Components total)",
+		len(changedIfaces), changedComps, numIfaces, numComps)
+	if changed {
+		c.notifyUpdate()
+	}
+
+	return nil
+}
+
+func unmarshalEthernetInterfaces(data []byte) ([]smdclient.EthernetInterface, error) {
+	var ethIfaceSlice []smdclient.EthernetInterface
+	if err := json.Unmarshal(data, &ethIfaceSlice); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal EthernetInterface data: %w", err)
+	}
+	return ethIfaceSlice, nil
+}
+
+func unmarshalComponents(data []byte) ([]smdclient.Component, error) {
+	var compsStruct struct {
+		Components []smdclient.Component `json:"Components"`
+	}
+	if err := json.Unmarshal(data, &compsStruct); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Components data: %w", err)
+	}
+	return compsStruct.Components, nil
+}
+
+// newestLastUpdate returns the newest LastUpdate timestamp out of since and
+// those of eis. Unparseable timestamps are ignored.
+func newestLastUpdate(since time.Time, eis []smdclient.EthernetInterface) time.Time {
+	newest := since
+	for _, ei := range eis {
+		t, err := time.Parse(time.RFC3339Nano, ei.LastUpdate)
+		if err != nil {
+			continue
+		}
+		if t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// countChangedComponents returns the number of Components that were added to,
+// removed from, or changed between old and new.
+func countChangedComponents(old, new map[string]smdclient.Component) int {
+	n := 0
+	for id, comp := range new {
+		if prev, ok := old[id]; !ok || !sameComponent(prev, comp) {
+			n++
+		}
+	}
+	for id := range old {
+		if _, ok := new[id]; !ok {
+			n++
+		}
+	}
+	return n
+}
+
+// sameComponent returns true if a and b are equal, treating an unset Enabled
+// as enabled.
+func sameComponent(a, b smdclient.Component) bool {
+	if a.IsEnabled() != b.IsEnabled() {
+		return false
+	}
+	a.Enabled, b.Enabled = nil, nil
+	return a == b
+}
+
 func (c *Cache) RefreshLoop() {
 	c.Log.Info("initiating cache refresh loop")
 	c.Log.Infof("refreshing cache every duration: %s", c.Duration.String())
+	if c.FullResync > 0 {
+		c.Log.Infof("incremental refreshes enabled, performing full resync every duration: %s", c.FullResync.String())
+	}
 
-	// Initial refresh
+	// Initial refresh, falling back to the snapshot (if any) so that there is
+	// something to serve while SMD is unavailable
 	err := c.Refresh()
 	if err != nil {
 		c.Log.Errorf("failed to refresh cache: %v", err)
+		if c.SnapshotPath != "" {
+			if err := c.LoadSnapshot(); err != nil {
+				c.Log.Errorf("failed to load cache snapshot: %v", err)
+			}
+		}
 	}
 
 	// ...then each duration
@@ -131,6 +439,9 @@ func (c *Cache) RefreshLoop() {
 			err := c.Refresh()
 			if err != nil {
 				c.Log.Errorf("failed to refresh cache: %v", err)
+				if stale, age := c.Stale(); stale {
+					c.Log.Warnf("still serving cache snapshot data that is %s old", age.Round(time.Second))
+				}
 			}
 		}
 	}()
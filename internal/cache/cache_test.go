@@ -6,6 +6,11 @@
 package cache
 
 import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -109,3 +114,350 @@ func TestNewCache(t *testing.T) {
 		})
 	}
 }
+
+// fakeSMD is a minimal SMD serving EthernetInterfaces, Components, groups,
+// and partitions, which records the requests made to it.
+type fakeSMD struct {
+	mu       sync.Mutex
+	ifaces   string // full EthernetInterfaces response
+	newer    string // response to NewerThan queries
+	comps    string // full Components response
+	groups   string // groups response (404 if empty)
+	parts    string // partitions response (404 if empty)
+	etag     string // if set, sent as ETag and honored for If-None-Match
+	requests []*http.Request
+}
+
+func (f *fakeSMD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+
+	if f.etag != "" {
+		if r.Header.Get("If-None-Match") == f.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", f.etag)
+	}
+
+	switch r.URL.Path {
+	case ethIfacesPath:
+		if r.URL.Query().Get("NewerThan") != "" {
+			_, _ = w.Write([]byte(f.newer))
+			return
+		}
+		_, _ = w.Write([]byte(f.ifaces))
+	case componentsPath:
+		_, _ = w.Write([]byte(f.comps))
+	case groupsPath:
+		if f.groups == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(f.groups))
+	case partitionsPath:
+		if f.parts == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(f.parts))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeSMD) lastRequests(n int) []*http.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if n > len(f.requests) {
+		n = len(f.requests)
+	}
+	return f.requests[len(f.requests)-n:]
+}
+
+func newTestCache(t *testing.T, f *fakeSMD, fullResync time.Duration) *Cache {
+	t.Helper()
+
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	client := smdclient.NewSmdClient(baseURL)
+	client.Client = srv.Client()
+	client.Retry = smdclient.RetryPolicy{}
+
+	c, err := NewCache(nil, "30s", client)
+	if err != nil {
+		t.Fatalf("NewCache() unexpected error: %v", err)
+	}
+	c.FullResync = fullResync
+
+	return c
+}
+
+func TestCacheRefresh_Full(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","IPAddresses":[{"IPAddress":"172.16.0.1"}]}]`,
+		comps:  `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"}]}`,
+	}
+	c := newTestCache(t, f, 0)
+
+	for i := 0; i < 2; i++ {
+		if err := c.Refresh(); err != nil {
+			t.Fatalf("Refresh() unexpected error: %v", err)
+		}
+	}
+
+	if c.FullRefreshes != 2 || c.IncrementalRefreshes != 0 {
+		t.Errorf("FullRefreshes=%d IncrementalRefreshes=%d, want 2 and 0", c.FullRefreshes, c.IncrementalRefreshes)
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; !ok {
+		t.Errorf("EthernetInterfaces missing de:ca:fc:0f:fe:e1: %v", c.EthernetInterfaces)
+	}
+	if comp, ok := c.Components["x3000c0s0b0n0"]; !ok || comp.NID != 1 {
+		t.Errorf("Components[x3000c0s0b0n0] = %v, %v", comp, ok)
+	}
+	if c.LastUpdated.IsZero() || c.LastFullRefresh != c.LastUpdated {
+		t.Errorf("LastUpdated=%v LastFullRefresh=%v, want equal and nonzero", c.LastUpdated, c.LastFullRefresh)
+	}
+}
+
+func TestCacheRefresh_IncrementalNewerThan(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","LastUpdate":"2026-01-02T03:04:05.123456Z"}]`,
+		newer:  `[{"MACAddress":"de:ca:fc:0f:fe:e2","ComponentID":"x3000c0s1b0n0","LastUpdate":"2026-01-02T03:05:00Z"}]`,
+		comps:  `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"}]}`,
+	}
+	c := newTestCache(t, f, time.Hour)
+
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("initial Refresh() unexpected error: %v", err)
+	}
+	f.mu.Lock()
+	f.comps = `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"},{"ID":"x3000c0s1b0n0","NID":2,"Type":"Node"}]}`
+	f.mu.Unlock()
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+
+	if c.FullRefreshes != 1 || c.IncrementalRefreshes != 1 {
+		t.Errorf("FullRefreshes=%d IncrementalRefreshes=%d, want 1 and 1", c.FullRefreshes, c.IncrementalRefreshes)
+	}
+	reqs := f.lastRequests(2)
+	if got := reqs[0].URL.Query().Get("NewerThan"); got != "2026-01-02T03:04:05.123456Z" {
+		t.Errorf("NewerThan=%q, want %q", got, "2026-01-02T03:04:05.123456Z")
+	}
+	if reqs[1].URL.Path != componentsPath || reqs[1].URL.RawQuery != "" {
+		t.Errorf("Components request=%s, want all Components", reqs[1].URL)
+	}
+	if len(c.EthernetInterfaces) != 2 {
+		t.Errorf("len(EthernetInterfaces)=%d, want 2", len(c.EthernetInterfaces))
+	}
+	if comp, ok := c.Components["x3000c0s1b0n0"]; !ok || comp.NID != 2 {
+		t.Errorf("Components[x3000c0s1b0n0] = %v, %v", comp, ok)
+	}
+	if _, ok := c.Components["x3000c0s0b0n0"]; !ok {
+		t.Errorf("existing component x3000c0s0b0n0 was dropped by incremental refresh")
+	}
+	want, _ := time.Parse(time.RFC3339, "2026-01-02T03:05:00Z")
+	if !c.ifaceWatermark.Equal(want) {
+		t.Errorf("ifaceWatermark=%v, want %v", c.ifaceWatermark, want)
+	}
+}
+
+func TestCacheRefresh_IncrementalNotModified(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0"}]`,
+		comps:  `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"}]}`,
+		etag:   `"v1"`,
+	}
+	c := newTestCache(t, f, time.Hour)
+	updates := 0
+	c.OnUpdate = func() { updates++ }
+
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("initial Refresh() unexpected error: %v", err)
+	}
+	gen := c.Generation
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+
+	for _, r := range f.lastRequests(2) {
+		if got := r.Header.Get("If-None-Match"); got != `"v1"` {
+			t.Errorf("%s If-None-Match=%q, want %q", r.URL.Path, got, `"v1"`)
+		}
+		if r.URL.RawQuery != "" {
+			t.Errorf("%s query=%q, want none", r.URL.Path, r.URL.RawQuery)
+		}
+	}
+	if c.IncrementalRefreshes != 1 {
+		t.Errorf("IncrementalRefreshes=%d, want 1", c.IncrementalRefreshes)
+	}
+	if len(c.EthernetInterfaces) != 1 || len(c.Components) != 1 {
+		t.Errorf("cache contents changed on 304: %v %v", c.EthernetInterfaces, c.Components)
+	}
+	if gen == 0 || c.Generation != gen {
+		t.Errorf("Generation=%d after 304, want %d (nonzero)", c.Generation, gen)
+	}
+	if updates != 1 {
+		t.Errorf("OnUpdate called %d times, want 1", updates)
+	}
+}
+
+func TestCacheRefresh_IncrementalComponentChanged(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","LastUpdate":"2026-01-02T03:04:05Z"}]`,
+		newer:  `[]`,
+		comps:  `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node","State":"Ready"}]}`,
+	}
+	c := newTestCache(t, f, time.Hour)
+	updates := 0
+	c.OnUpdate = func() { updates++ }
+
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("initial Refresh() unexpected error: %v", err)
+	}
+
+	// Without validators, unchanged Components don't count as an update
+	gen := c.Generation
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+	if c.Generation != gen || updates != 1 {
+		t.Errorf("Generation=%d updates=%d after unchanged refresh, want %d and 1", c.Generation, updates, gen)
+	}
+
+	// A Component disabled in SMD is seen without its EthernetInterface
+	// changing
+	f.mu.Lock()
+	f.comps = `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node","State":"Ready","Enabled":false}]}`
+	f.mu.Unlock()
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+	if c.FullRefreshes != 1 || c.IncrementalRefreshes != 2 {
+		t.Errorf("FullRefreshes=%d IncrementalRefreshes=%d, want 1 and 2", c.FullRefreshes, c.IncrementalRefreshes)
+	}
+	if c.Components["x3000c0s0b0n0"].IsEnabled() {
+		t.Errorf("Components[x3000c0s0b0n0] still enabled after incremental refresh")
+	}
+	if c.Generation == gen || updates != 2 {
+		t.Errorf("Generation=%d updates=%d after change, want a new generation and 2", c.Generation, updates)
+	}
+}
+
+func TestCacheRefresh_FullResyncRemovesDeleted(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","LastUpdate":"2026-01-02T03:04:05Z"},` +
+			`{"MACAddress":"de:ca:fc:0f:fe:e2","ComponentID":"x3000c0s1b0n0","LastUpdate":"2026-01-02T03:04:05Z"}]`,
+		newer: `[]`,
+		comps: `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"},{"ID":"x3000c0s1b0n0","NID":2,"Type":"Node"}]}`,
+	}
+	c := newTestCache(t, f, time.Hour)
+
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("initial Refresh() unexpected error: %v", err)
+	}
+
+	// Delete an interface and component in SMD; incremental refreshes
+	// cannot see deleted EthernetInterfaces.
+	f.mu.Lock()
+	f.ifaces = `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","LastUpdate":"2026-01-02T03:04:05Z"}]`
+	f.comps = `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"}]}`
+	f.mu.Unlock()
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+	if len(c.EthernetInterfaces) != 2 {
+		t.Fatalf("len(EthernetInterfaces)=%d after incremental refresh, want 2", len(c.EthernetInterfaces))
+	}
+
+	// Make full resync due
+	c.LastFullRefresh = time.Now().Add(-2 * time.Hour)
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("full Refresh() unexpected error: %v", err)
+	}
+	if c.FullRefreshes != 2 || c.IncrementalRefreshes != 1 {
+		t.Errorf("FullRefreshes=%d IncrementalRefreshes=%d, want 2 and 1", c.FullRefreshes, c.IncrementalRefreshes)
+	}
+	if len(c.EthernetInterfaces) != 1 || len(c.Components) != 1 {
+		t.Errorf("deleted records not removed by full resync: %v %v", c.EthernetInterfaces, c.Components)
+	}
+}
+
+func TestCacheRefresh_Membership(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[{"MACAddress":"de:ca:fc:0f:fe:e1","ComponentID":"x3000c0s0b0n0","LastUpdate":"2026-01-02T03:04:05Z"}]`,
+		newer:  `[]`,
+		comps:  `{"Components":[{"ID":"x3000c0s0b0n0","NID":1,"Type":"Node"}]}`,
+		groups: `[{"label":"login","members":{"ids":["x3000c0s0b0n0"]}},{"label":"compute","members":{"ids":["x3000c0s0b0n0","x3000c0s1b0n0"]}}]`,
+		parts:  `[{"name":"p1","members":{"ids":["x3000c0s0b0n0"]}},{"name":"p2","members":{"ids":["x3000c0s0b0n0","x3000c0s1b0n0"]}}]`,
+	}
+	c := newTestCache(t, f, time.Hour)
+	updates := 0
+	c.OnUpdate = func() { updates++ }
+
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("initial Refresh() unexpected error: %v", err)
+	}
+	groups, partition := c.Membership("x3000c0s0b0n0")
+	if len(groups) != 2 || groups[0] != "compute" || groups[1] != "login" {
+		t.Errorf("groups=%v, want [compute login]", groups)
+	}
+	if partition != "p1" {
+		t.Errorf("partition=%q, want %q (first partition listing the component)", partition, "p1")
+	}
+	if _, partition := c.Membership("x3000c0s1b0n0"); partition != "p2" {
+		t.Errorf("partition=%q, want %q", partition, "p2")
+	}
+
+	// Unchanged membership doesn't change the cache
+	gen := c.Generation
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+	if c.Generation != gen || updates != 1 {
+		t.Errorf("Generation=%d updates=%d after unchanged refresh, want %d and 1", c.Generation, updates, gen)
+	}
+
+	// Changed membership is picked up by incremental refreshes
+	f.mu.Lock()
+	f.groups = `[{"label":"compute","members":{"ids":["x3000c0s1b0n0"]}}]`
+	f.parts = ""
+	f.mu.Unlock()
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("incremental Refresh() unexpected error: %v", err)
+	}
+	if c.Generation == gen || updates != 2 {
+		t.Errorf("Generation=%d updates=%d after membership change, want changed and 2", c.Generation, updates)
+	}
+	if groups, partition := c.Membership("x3000c0s0b0n0"); len(groups) != 0 || partition != "" {
+		t.Errorf("membership=%v %q, want none", groups, partition)
+	}
+}
+
+func TestCacheRefresh_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	baseURL, _ := url.Parse(srv.URL)
+	client := smdclient.NewSmdClient(baseURL)
+	client.Client = srv.Client()
+	client.Retry = smdclient.RetryPolicy{}
+
+	c, err := NewCache(nil, "30s", client)
+	if err != nil {
+		t.Fatalf("NewCache() unexpected error: %v", err)
+	}
+	if err := c.Refresh(); !errors.Is(err, smdclient.ErrServer) {
+		t.Fatalf("Refresh() error = %v, want smdclient.ErrServer on HTTP 500", err)
+	}
+	if !c.LastUpdated.IsZero() || c.FullRefreshes != 0 {
+		t.Errorf("LastUpdated=%v FullRefreshes=%d, want unchanged after failed refresh", c.LastUpdated, c.FullRefreshes)
+	}
+}
@@ -0,0 +1,396 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+const (
+	EventActionUpdate = "update"
+	EventActionDelete = "delete"
+
+	// DefaultEventPath is the path the event webhook is served on by
+	// ListenEvents.
+	DefaultEventPath = "/events"
+
+	// maxEventSize is the maximum size of a single event, whether POSTed to
+	// the webhook or received from the event feed.
+	maxEventSize = 16 << 20
+
+	eventFeedMinBackoff = 1 * time.Second
+	eventFeedMaxBackoff = 1 * time.Minute
+)
+
+// Event is a change notification applied to the cache as soon as it is
+// received, without waiting for the next refresh.
+//
+// For the "update" action (the default), EthernetInterfaces and Components
+// are added to or replace those in the cache. For the "delete" action, they
+// are removed from the cache; only the MACAddress of EthernetInterfaces and ID
+// of Components need to be set.
+//
+// ComponentIDs lists components that changed in SMD without including their
+// new data. These components and their EthernetInterfaces are fetched from SMD
+// and replace the cached ones, regardless of the action.
+type Event struct {
+	Action             string                        `json:"Action,omitempty"`
+	EthernetInterfaces []smdclient.EthernetInterface `json:"EthernetInterfaces,omitempty"`
+	Components         []smdclient.Component         `json:"Components,omitempty"`
+	ComponentIDs       []string                      `json:"ComponentIDs,omitempty"`
+}
+
+// ApplyEvent applies ev to the cache. Events are serialized with refreshes so
+// that an event is never overwritten by a refresh that started before it.
+func (c *Cache) ApplyEvent(ev Event) error {
+	if c == nil {
+		return fmt.Errorf("cache is nil")
+	}
+
+	action := strings.ToLower(strings.TrimSpace(ev.Action))
+	if action == "" {
+		action = EventActionUpdate
+	}
+	if action != EventActionUpdate && action != EventActionDelete {
+		return fmt.Errorf("unknown event action %q", ev.Action)
+	}
+
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+
+	// Fetch components named only by ID before taking the write lock
+	var (
+		fetchedIfaces []smdclient.EthernetInterface
+		fetchedComps  []smdclient.Component
+	)
+	if len(ev.ComponentIDs) > 0 {
+		var err error
+		if fetchedIfaces, fetchedComps, err = c.fetchComponents(ev.ComponentIDs); err != nil {
+			return err
+		}
+	}
+
+	c.Mutex.Lock()
+	if c.EthernetInterfaces == nil {
+		c.EthernetInterfaces = make(map[string]smdclient.EthernetInterface)
+	}
+	if c.Components == nil {
+		c.Components = make(map[string]smdclient.Component)
+	}
+	for _, ei := range ev.EthernetInterfaces {
+		if action == EventActionDelete {
+			c.deleteIface(ei)
+		} else {
+			c.putIface(ei, true)
+		}
+	}
+	for _, comp := range ev.Components {
+		if action == EventActionDelete {
+			delete(c.Components, comp.ID)
+		} else {
+			c.Components[comp.ID] = comp
+		}
+	}
+	if len(ev.ComponentIDs) > 0 {
+		// What SMD returned for these components is authoritative, so drop
+		// anything cached for them first.
+		ids := make(map[string]bool, len(ev.ComponentIDs))
+		for _, id := range ev.ComponentIDs {
+			ids[id] = true
+			delete(c.Components, id)
+		}
+		var stale []smdclient.EthernetInterface
+		for _, col := range c.HWAddrCollisions {
+			if ids[col.Dropped.ComponentID] {
+				stale = append(stale, col.Dropped)
+			}
+		}
+		for _, ei := range c.EthernetInterfaces {
+			if ids[ei.ComponentID] {
+				stale = append(stale, ei)
+			}
+		}
+		for _, ei := range stale {
+			c.deleteIface(ei)
+		}
+		for _, ei := range fetchedIfaces {
+			c.putIface(ei, true)
+		}
+		for _, comp := range fetchedComps {
+			c.Components[comp.ID] = comp
+		}
+	}
+	c.EventsApplied++
+	c.Generation++
+	c.Mutex.Unlock()
+	c.notifyUpdate()
+
+	c.Log.Infof("applied %s event with %d EthernetInterfaces, %d Components, and %d component IDs",
+		action, len(ev.EthernetInterfaces), len(ev.Components), len(ev.ComponentIDs))
+
+	return nil
+}
+
+// fetchComponents fetches the Components with the given IDs and their
+// EthernetInterfaces from SMD.
+func (c *Cache) fetchComponents(ids []string) ([]smdclient.EthernetInterface, []smdclient.Component, error) {
+	c.Log.Debugf("fetching %d changed components from SMD", len(ids))
+
+	ifaceData, _, _, err := c.Client.APIGetConditional(ethIfacesPath, url.Values{"ComponentID": ids}, smdclient.Validators{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch EthernetInterfaces for changed components from SMD: %w", err)
+	}
+	eis, err := unmarshalEthernetInterfaces(ifaceData)
+	if err != nil {
+		return nil, nil, err
+	}
+	compData, _, _, err := c.Client.APIGetConditional(componentsPath, url.Values{"id": ids}, smdclient.Validators{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch changed Components from SMD: %w", err)
+	}
+	comps, err := unmarshalComponents(compData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return eis, comps, nil
+}
+
+// EventHandler returns an http.Handler implementing the event webhook. It
+// accepts POST requests whose body is a JSON Event or array of Events and that
+// present the token from EventToken in an "Authorization: Bearer" header.
+func (c *Cache) EventHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.eventAuthorized(r) {
+			c.Log.Warnf("rejecting unauthenticated event from %s", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSize))
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		events, err := decodeEvents(body)
+		if err != nil {
+			c.Log.Warnf("rejecting event from %s: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, ev := range events {
+			if err := c.ApplyEvent(ev); err != nil {
+				c.Log.Errorf("failed to apply event from %s: %v", r.RemoteAddr, err)
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// eventAuthorized returns true if r presents the token from EventToken.
+func (c *Cache) eventAuthorized(r *http.Request) bool {
+	if c.EventToken == nil {
+		return false
+	}
+	want, err := c.EventToken.Token()
+	if err != nil {
+		c.Log.Errorf("failed to get event webhook token: %v", err)
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(want)) == 1
+}
+
+// ListenEvents serves the event webhook on DefaultEventPath at addr in a new
+// goroutine. If addr has no host, the webhook is only served on the loopback
+// interface. EventToken must be set. The returned server can be used to shut
+// it down.
+func (c *Cache) ListenEvents(addr string) (*http.Server, error) {
+	if c.EventToken == nil {
+		return nil, fmt.Errorf("the cache event webhook requires a token")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cache event listen address %q: %w", addr, err)
+	}
+	if host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for cache events: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(DefaultEventPath, c.EventHandler())
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	c.Log.Infof("listening for cache events on %s%s", ln.Addr(), DefaultEventPath)
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			c.Log.Errorf("cache event listener failed: %v", err)
+		}
+	}()
+
+	return srv, nil
+}
+
+// WatchEvents subscribes to the Server-Sent Events feed at feed, which is
+// either an absolute URL or a path relative to the SMD base URL, and applies
+// each event received to the cache. The data of each SSE message must be a
+// JSON Event or array of Events. WatchEvents reconnects with exponential
+// backoff whenever the feed fails and only returns once ctx is done.
+func (c *Cache) WatchEvents(ctx context.Context, feed string) {
+	feedURL, err := c.eventFeedURL(feed)
+	if err != nil {
+		c.Log.Errorf("not watching cache events: %v", err)
+		return
+	}
+
+	c.Log.Infof("watching cache events from %s", feedURL)
+	backoff := eventFeedMinBackoff
+	for {
+		connected, err := c.readEventFeed(ctx, feedURL)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = eventFeedMinBackoff
+		}
+		c.Log.Warnf("cache event feed disconnected, reconnecting in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, eventFeedMaxBackoff)
+	}
+}
+
+func (c *Cache) eventFeedURL(feed string) (string, error) {
+	u, err := url.Parse(feed)
+	if err != nil {
+		return "", fmt.Errorf("invalid event feed URL %q: %w", feed, err)
+	}
+	if u.IsAbs() {
+		return u.String(), nil
+	}
+	if c.Client == nil || c.Client.BaseURL == nil {
+		return "", fmt.Errorf("relative event feed %q requires an SMD base URL", feed)
+	}
+	rel := c.Client.BaseURL.JoinPath(u.Path)
+	rel.RawQuery = u.RawQuery
+	return rel.String(), nil
+}
+
+// readEventFeed connects to the event feed and applies events until the
+// connection ends. connected is true if the feed was successfully opened.
+func (c *Cache) readEventFeed(ctx context.Context, feedURL string) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if err := c.Client.Authorize(req); err != nil {
+		return false, err
+	}
+
+	// The feed is long-lived, so reuse the SMD client's transport (for TLS
+	// settings) without any overall request timeout it may have.
+	hc := &http.Client{}
+	if c.Client != nil && c.Client.Client != nil {
+		hc.Transport = c.Client.Transport
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to event feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected HTTP status from event feed: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// A blank line dispatches the message
+			if data.Len() == 0 {
+				continue
+			}
+			events, err := decodeEvents([]byte(data.String()))
+			data.Reset()
+			if err != nil {
+				c.Log.Warnf("ignoring invalid cache event from feed: %v", err)
+				continue
+			}
+			for _, ev := range events {
+				if err := c.ApplyEvent(ev); err != nil {
+					c.Log.Errorf("failed to apply cache event from feed: %v", err)
+				}
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		default:
+			// Comments (keepalives), event names, IDs, and retry fields are
+			// not used
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return true, fmt.Errorf("failed to read event feed: %w", err)
+	}
+
+	return true, fmt.Errorf("event feed closed by server")
+}
+
+// decodeEvents decodes a JSON Event or array of Events.
+func decodeEvents(data []byte) ([]Event, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var events []Event
+		if err := json.Unmarshal([]byte(trimmed), &events); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal events: %w", err)
+		}
+		return events, nil
+	}
+
+	var ev Event
+	if err := json.Unmarshal([]byte(trimmed), &ev); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	return []Event{ev}, nil
+}
@@ -0,0 +1,407 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// testEventToken is the token accepted by the event webhook of caches created
+// by newEventTestCache.
+const testEventToken = "s3cret"
+
+// staticToken is a TokenSource returning itself.
+type staticToken string
+
+func (t staticToken) Token() (string, error) { return string(t), nil }
+
+func newEventTestCache(t *testing.T, baseURL string) *Cache {
+	t.Helper()
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+	c, err := NewCache(nil, "30s", smdclient.NewSmdClient(u))
+	if err != nil {
+		t.Fatalf("NewCache() unexpected error: %v", err)
+	}
+	c.EthernetInterfaces = map[string]smdclient.EthernetInterface{
+		"de:ca:fc:0f:fe:e1": {MACAddress: "de:ca:fc:0f:fe:e1", ComponentID: "x3000c0s0b0n0"},
+	}
+	c.Components = map[string]smdclient.Component{
+		"x3000c0s0b0n0": {ID: "x3000c0s0b0n0", NID: 1, Type: "Node"},
+	}
+	c.EventToken = staticToken(testEventToken)
+
+	return c
+}
+
+// waitFor polls cond until it returns true or a timeout elapses.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func TestApplyEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      Event
+		wantErr    bool
+		wantIfaces []string
+		wantComps  []string
+	}{
+		{
+			name: "update_adds_records",
+			event: Event{
+				EthernetInterfaces: []smdclient.EthernetInterface{{MACAddress: "de:ca:fc:0f:fe:e2", ComponentID: "x3000c0s1b0n0"}},
+				Components:         []smdclient.Component{{ID: "x3000c0s1b0n0", NID: 2, Type: "Node"}},
+			},
+			wantIfaces: []string{"de:ca:fc:0f:fe:e1", "de:ca:fc:0f:fe:e2"},
+			wantComps:  []string{"x3000c0s0b0n0", "x3000c0s1b0n0"},
+		},
+		{
+			name: "delete_removes_records",
+			event: Event{
+				Action:             "Delete",
+				EthernetInterfaces: []smdclient.EthernetInterface{{MACAddress: "de:ca:fc:0f:fe:e1"}},
+				Components:         []smdclient.Component{{ID: "x3000c0s0b0n0"}},
+			},
+		},
+		{
+			name:       "unknown_action",
+			event:      Event{Action: "upsert"},
+			wantErr:    true,
+			wantIfaces: []string{"de:ca:fc:0f:fe:e1"},
+			wantComps:  []string{"x3000c0s0b0n0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newEventTestCache(t, "http://smd.example.test")
+
+			err := c.ApplyEvent(tt.event)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(c.EthernetInterfaces) != len(tt.wantIfaces) {
+				t.Errorf("EthernetInterfaces = %v, want %v", c.EthernetInterfaces, tt.wantIfaces)
+			}
+			for _, mac := range tt.wantIfaces {
+				if _, ok := c.EthernetInterfaces[mac]; !ok {
+					t.Errorf("EthernetInterfaces missing %s", mac)
+				}
+			}
+			if len(c.Components) != len(tt.wantComps) {
+				t.Errorf("Components = %v, want %v", c.Components, tt.wantComps)
+			}
+			for _, id := range tt.wantComps {
+				if _, ok := c.Components[id]; !ok {
+					t.Errorf("Components missing %s", id)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEvent_ComponentIDsRefetched(t *testing.T) {
+	var gotIfaceQuery, gotCompQuery url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case ethIfacesPath:
+			gotIfaceQuery = r.URL.Query()
+			// x3000c0s0b0n0 moved to a new NIC
+			_, _ = w.Write([]byte(`[{"MACAddress":"de:ca:fc:0f:fe:e3","ComponentID":"x3000c0s0b0n0"}]`))
+		case componentsPath:
+			gotCompQuery = r.URL.Query()
+			_, _ = w.Write([]byte(`{"Components":[{"ID":"x3000c0s0b0n0","NID":10,"Type":"Node"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := newEventTestCache(t, srv.URL)
+	if err := c.ApplyEvent(Event{ComponentIDs: []string{"x3000c0s0b0n0"}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+
+	if got := gotIfaceQuery["ComponentID"]; len(got) != 1 || got[0] != "x3000c0s0b0n0" {
+		t.Errorf("EthernetInterfaces ComponentID query = %v", got)
+	}
+	if got := gotCompQuery["id"]; len(got) != 1 || got[0] != "x3000c0s0b0n0" {
+		t.Errorf("Components id query = %v", got)
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; ok {
+		t.Errorf("stale EthernetInterface de:ca:fc:0f:fe:e1 not removed")
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e3"]; !ok {
+		t.Errorf("refetched EthernetInterface de:ca:fc:0f:fe:e3 missing")
+	}
+	if c.Components["x3000c0s0b0n0"].NID != 10 {
+		t.Errorf("Components[x3000c0s0b0n0].NID = %d, want 10", c.Components["x3000c0s0b0n0"].NID)
+	}
+	if c.EventsApplied != 1 {
+		t.Errorf("EventsApplied = %d, want 1", c.EventsApplied)
+	}
+}
+
+func TestEventHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		auth       string // Authorization header
+		body       string
+		wantStatus int
+		wantIfaces int
+	}{
+		{
+			name:       "single_event",
+			method:     http.MethodPost,
+			auth:       "Bearer " + testEventToken,
+			body:       `{"EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e2","ComponentID":"x3000c0s1b0n0"}]}`,
+			wantStatus: http.StatusNoContent,
+			wantIfaces: 2,
+		},
+		{
+			name:   "event_array",
+			method: http.MethodPost,
+			auth:   "Bearer " + testEventToken,
+			body: `[{"EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e2"}]},` +
+				`{"Action":"delete","EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e1"}]}]`,
+			wantStatus: http.StatusNoContent,
+			wantIfaces: 1,
+		},
+		{
+			name:       "invalid_json",
+			method:     http.MethodPost,
+			auth:       "Bearer " + testEventToken,
+			body:       `{"EthernetInterfaces":`,
+			wantStatus: http.StatusBadRequest,
+			wantIfaces: 1,
+		},
+		{
+			name:       "invalid_action",
+			method:     http.MethodPost,
+			auth:       "Bearer " + testEventToken,
+			body:       `{"Action":"explode"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantIfaces: 1,
+		},
+		{
+			name:       "no_token",
+			method:     http.MethodPost,
+			body:       `{"Action":"delete","EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e1"}]}`,
+			wantStatus: http.StatusUnauthorized,
+			wantIfaces: 1,
+		},
+		{
+			name:       "wrong_token",
+			method:     http.MethodPost,
+			auth:       "Bearer not-the-token",
+			body:       `{"Action":"delete","EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e1"}]}`,
+			wantStatus: http.StatusUnauthorized,
+			wantIfaces: 1,
+		},
+		{
+			name:       "basic_auth",
+			method:     http.MethodPost,
+			auth:       "Basic " + testEventToken,
+			body:       `{"Action":"delete","EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e1"}]}`,
+			wantStatus: http.StatusUnauthorized,
+			wantIfaces: 1,
+		},
+		{
+			name:       "wrong_method",
+			method:     http.MethodGet,
+			auth:       "Bearer " + testEventToken,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantIfaces: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newEventTestCache(t, "http://smd.example.test")
+			srv := httptest.NewServer(c.EventHandler())
+			defer srv.Close()
+
+			req, err := http.NewRequest(tt.method, srv.URL+DefaultEventPath, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(c.EthernetInterfaces) != tt.wantIfaces {
+				t.Errorf("len(EthernetInterfaces) = %d, want %d", len(c.EthernetInterfaces), tt.wantIfaces)
+			}
+		})
+	}
+}
+
+func TestEventHandler_NoEventToken(t *testing.T) {
+	c := newEventTestCache(t, "http://smd.example.test")
+	c.EventToken = nil
+	srv := httptest.NewServer(c.EventHandler())
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+DefaultEventPath,
+		strings.NewReader(`{"EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e2"}]}`))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer ")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if len(c.EthernetInterfaces) != 1 {
+		t.Errorf("len(EthernetInterfaces) = %d, want 1", len(c.EthernetInterfaces))
+	}
+}
+
+func TestListenEvents(t *testing.T) {
+	c := newEventTestCache(t, "http://smd.example.test")
+	srv, err := c.ListenEvents(":0")
+	if err != nil {
+		t.Fatalf("ListenEvents() unexpected error: %v", err)
+	}
+	defer srv.Close()
+	if host, _, _ := net.SplitHostPort(srv.Addr); host != "127.0.0.1" {
+		t.Errorf("listening on %s, want the loopback interface", srv.Addr)
+	}
+
+	c.EventToken = nil
+	if _, err := c.ListenEvents("127.0.0.1:0"); err == nil {
+		t.Errorf("ListenEvents() without EventToken succeeded, want error")
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	connects := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hsm/v2/events" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("Accept = %q, want text/event-stream", r.Header.Get("Accept"))
+		}
+		connects <- struct{}{}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "event: update\n")
+		fmt.Fprint(w, `data: {"EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e2","ComponentID":"x3000c0s1b0n0"}],`+"\n")
+		fmt.Fprint(w, `data: "Components":[{"ID":"x3000c0s1b0n0","NID":2,"Type":"Node"}]}`+"\n\n")
+		fmt.Fprint(w, "data: not json\n\n")
+		fmt.Fprint(w, `data: {"Action":"delete","EthernetInterfaces":[{"MACAddress":"de:ca:fc:0f:fe:e1"}]}`+"\n\n")
+		flusher.Flush()
+
+		// Hold the connection open like a real feed
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	c := newEventTestCache(t, srv.URL)
+	c.Client.Client = srv.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.WatchEvents(ctx, "/hsm/v2/events")
+		close(done)
+	}()
+
+	waitFor(t, "events to be applied", func() bool {
+		c.Mutex.RLock()
+		defer c.Mutex.RUnlock()
+		return c.EventsApplied == 2
+	})
+
+	c.Mutex.RLock()
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e2"]; !ok {
+		t.Errorf("EthernetInterface from feed not added")
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; ok {
+		t.Errorf("EthernetInterface deleted by feed still present")
+	}
+	if c.Components["x3000c0s1b0n0"].NID != 2 {
+		t.Errorf("Component from feed not added: %v", c.Components)
+	}
+	c.Mutex.RUnlock()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WatchEvents() did not return after context was cancelled")
+	}
+	if len(connects) != 1 {
+		t.Errorf("feed connections = %d, want 1", len(connects))
+	}
+}
+
+func TestWatchEvents_Reconnects(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `data: {"Components":[{"ID":"x3000c0s1b0n0","NID":2,"Type":"Node"}]}`+"\n\n")
+	}))
+	defer srv.Close()
+
+	c := newEventTestCache(t, "http://smd.example.test")
+	c.Client.Client = srv.Client()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.WatchEvents(ctx, srv.URL+"/events")
+
+	waitFor(t, "event after reconnect", func() bool {
+		c.Mutex.RLock()
+		defer c.Mutex.RUnlock()
+		_, ok := c.Components["x3000c0s1b0n0"]
+		return ok
+	})
+}
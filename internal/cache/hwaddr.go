@@ -0,0 +1,242 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// HWAddrCollision describes an EthernetInterface that was not cached because
+// its hardware address is the same as that of another after normalization.
+type HWAddrCollision struct {
+	HWAddr  string                      // normalized hardware address
+	Kept    smdclient.EthernetInterface // cached interface
+	Dropped smdclient.EthernetInterface // interface not cached
+}
+
+// NormalizeHWAddr returns the canonical form of the hardware address s: its
+// bytes in lowercase hexadecimal separated by colons, as formatted by
+// net.HardwareAddr. MAC (6-byte), EUI-64 (8-byte, e.g. InfiniBand GUIDs), and
+// IPoIB (20-byte) addresses are accepted in any case, either in a notation
+// accepted by net.ParseMAC or as hexadecimal digits without separators (e.g.
+// A4BF0112AB3C).
+func NormalizeHWAddr(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, ":-.") {
+		hw, err := net.ParseMAC(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid hardware address %q: %w", s, err)
+		}
+		return hw.String(), nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid hardware address %q: not hexadecimal", s)
+	}
+	switch len(b) {
+	case 6, 8, 20:
+		return net.HardwareAddr(b).String(), nil
+	}
+	return "", fmt.Errorf("invalid hardware address %q: %d bytes, expected 6, 8, or 20", s, len(b))
+}
+
+// ifaceKey returns the key of ei in EthernetInterfaces: its normalized
+// hardware address, or its MACAddress as is (and an error) if it cannot be
+// normalized.
+func ifaceKey(ei smdclient.EthernetInterface) (string, error) {
+	key, err := NormalizeHWAddr(ei.MACAddress)
+	if err != nil {
+		return ei.MACAddress, err
+	}
+	return key, nil
+}
+
+// putIface adds ei to EthernetInterfaces under its normalized hardware
+// address, replacing the interface cached under it if that has the same
+// MACAddress. Interfaces with addresses that cannot be normalized are cached
+// under their MACAddress as is and recorded in InvalidHWAddrs. If an interface
+// with a different MACAddress is already cached under the same key, it is kept
+// and the collision is recorded in HWAddrCollisions, so that which of the two
+// is served does not depend on the order updates arrive in. Problems not
+// already recorded are logged if warn is true. Must be called with Mutex held
+// for writing.
+func (c *Cache) putIface(ei smdclient.EthernetInterface, warn bool) {
+	key, err := ifaceKey(ei)
+	if err != nil {
+		if _, known := c.InvalidHWAddrs[key]; warn && !known {
+			c.Log.Warnf("EthernetInterface of component %s has an unparseable hardware address and can only be matched exactly: %v", ei.ComponentID, err)
+		}
+		if c.InvalidHWAddrs == nil {
+			c.InvalidHWAddrs = make(map[string]smdclient.EthernetInterface)
+		}
+		c.InvalidHWAddrs[key] = ei
+	}
+	if cached, ok := c.EthernetInterfaces[key]; ok && cached.MACAddress != ei.MACAddress {
+		col := HWAddrCollision{HWAddr: key, Kept: cached, Dropped: ei}
+		if i := c.collision(key, ei.MACAddress); i >= 0 {
+			c.HWAddrCollisions[i] = col
+			return
+		}
+		if warn {
+			c.Log.Warnf("EthernetInterfaces %q (component %s) and %q (component %s) have the same hardware address %s, ignoring %q",
+				cached.MACAddress, cached.ComponentID, ei.MACAddress, ei.ComponentID, key, ei.MACAddress)
+		}
+		c.HWAddrCollisions = append(c.HWAddrCollisions, col)
+		return
+	}
+	c.EthernetInterfaces[key] = ei
+	c.indexGUID(key)
+}
+
+// collision returns the index in HWAddrCollisions of the collision under key
+// in which the interface with MACAddress mac was dropped, or -1 if there is
+// none.
+func (c *Cache) collision(key, mac string) int {
+	return slices.IndexFunc(c.HWAddrCollisions, func(col HWAddrCollision) bool {
+		return col.HWAddr == key && col.Dropped.MACAddress == mac
+	})
+}
+
+// deleteIface removes ei from the cache. If other interfaces have the same
+// hardware address, only the interface with the same MACAddress as ei is
+// removed, whether it was cached or dropped, and if it was cached the first
+// interface dropped in its favor takes its place. Otherwise, ei's hardware
+// address may be in any notation. Must be called with Mutex held for writing.
+func (c *Cache) deleteIface(ei smdclient.EthernetInterface) {
+	key, _ := ifaceKey(ei)
+	cached, ok := c.EthernetInterfaces[key]
+	if !ok {
+		return
+	}
+	if !slices.ContainsFunc(c.HWAddrCollisions, func(col HWAddrCollision) bool { return col.HWAddr == key }) {
+		delete(c.EthernetInterfaces, key)
+		delete(c.InvalidHWAddrs, key)
+		c.unindexGUID(key)
+		return
+	}
+
+	if i := c.collision(key, ei.MACAddress); i >= 0 {
+		c.HWAddrCollisions = slices.Delete(c.HWAddrCollisions, i, i+1)
+		return
+	}
+	if cached.MACAddress != ei.MACAddress {
+		return
+	}
+	var (
+		promoted smdclient.EthernetInterface
+		found    bool
+	)
+	c.HWAddrCollisions = slices.DeleteFunc(c.HWAddrCollisions, func(col HWAddrCollision) bool {
+		if col.HWAddr != key {
+			return false
+		}
+		if !found {
+			promoted, found = col.Dropped, true
+			return true
+		}
+		return false
+	})
+	c.EthernetInterfaces[key] = promoted
+	for i, col := range c.HWAddrCollisions {
+		if col.HWAddr == key {
+			c.HWAddrCollisions[i].Kept = promoted
+		}
+	}
+}
+
+// indexIfaces replaces EthernetInterfaces with ifaces, keyed by their
+// normalized hardware addresses. Earlier interfaces take precedence over later
+// colliding ones. Only problems not already recorded before are logged, so
+// that each is logged once rather than on every refresh. Must be called with
+// Mutex held for writing.
+func (c *Cache) indexIfaces(ifaces []smdclient.EthernetInterface) {
+	knownInvalid := c.InvalidHWAddrs
+	knownCollisions := make(map[[2]string]bool, len(c.HWAddrCollisions))
+	for _, col := range c.HWAddrCollisions {
+		knownCollisions[[2]string{col.Kept.MACAddress, col.Dropped.MACAddress}] = true
+	}
+
+	c.EthernetInterfaces = make(map[string]smdclient.EthernetInterface, len(ifaces))
+	c.InvalidHWAddrs, c.HWAddrCollisions, c.GUIDs = nil, nil, nil
+	for _, ei := range ifaces {
+		warn := true
+		if key, err := ifaceKey(ei); err != nil {
+			_, known := knownInvalid[key]
+			warn = !known
+		} else if cached, ok := c.EthernetInterfaces[key]; ok {
+			warn = !knownCollisions[[2]string{cached.MACAddress, ei.MACAddress}]
+		}
+		c.putIface(ei, warn)
+	}
+}
+
+// GUIDLen is the length of an InfiniBand port GUID.
+const GUIDLen = 8
+
+// guidOf returns the InfiniBand GUID in the normalized hardware address key:
+// the address itself if it is an EUI-64, or its last 8 bytes if it is an IPoIB
+// address.
+func guidOf(key string) (string, bool) {
+	hw, err := net.ParseMAC(key)
+	if err != nil {
+		return "", false
+	}
+	switch len(hw) {
+	case GUIDLen:
+		return key, true
+	case 20:
+		return hw[len(hw)-GUIDLen:].String(), true
+	}
+	return "", false
+}
+
+// indexGUID adds key to GUIDs if its hardware address contains a GUID not
+// already indexed. Must be called with Mutex held for writing.
+func (c *Cache) indexGUID(key string) {
+	guid, ok := guidOf(key)
+	if !ok {
+		return
+	}
+	if c.GUIDs == nil {
+		c.GUIDs = make(map[string]string)
+	}
+	if _, ok := c.GUIDs[guid]; !ok {
+		c.GUIDs[guid] = key
+	}
+}
+
+// unindexGUID removes key, which has been removed from EthernetInterfaces,
+// from GUIDs. If another cached interface has the same GUID, it is indexed
+// instead. Must be called with Mutex held for writing.
+func (c *Cache) unindexGUID(key string) {
+	guid, ok := guidOf(key)
+	if !ok || c.GUIDs[guid] != key {
+		return
+	}
+	delete(c.GUIDs, guid)
+	for other := range c.EthernetInterfaces {
+		if g, ok := guidOf(other); ok && g == guid {
+			c.GUIDs[guid] = other
+			return
+		}
+	}
+}
+
+// GUIDKey returns the key of the EthernetInterface whose hardware address is
+// or ends in the InfiniBand GUID guid, if one is cached. Must be called with
+// Mutex held for reading.
+func (c *Cache) GUIDKey(guid net.HardwareAddr) (string, bool) {
+	if len(guid) != GUIDLen {
+		return "", false
+	}
+	key, ok := c.GUIDs[guid.String()]
+	return key, ok
+}
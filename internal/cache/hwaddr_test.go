@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"net"
+	"testing"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+func TestNormalizeHWAddr(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"de:ca:fc:0f:fe:e1", "de:ca:fc:0f:fe:e1", false},
+		{"DE:CA:FC:0F:FE:E1", "de:ca:fc:0f:fe:e1", false},
+		{"de-ca-fc-0f-fe-e1", "de:ca:fc:0f:fe:e1", false},
+		{"deca.fc0f.fee1", "de:ca:fc:0f:fe:e1", false},
+		{"A4BF0112AB3C", "a4:bf:01:12:ab:3c", false},
+		{" a4bf0112ab3c ", "a4:bf:01:12:ab:3c", false},
+		{"98039B0300123456", "98:03:9b:03:00:12:34:56", false},
+		{"80:00:00:48:FE:80:00:00:00:00:00:00:98:03:9B:03:00:12:34:56", "80:00:00:48:fe:80:00:00:00:00:00:00:98:03:9b:03:00:12:34:56", false},
+		{"", "", true},
+		{"de:ca:fc:0f:fe", "", true},
+		{"de:ca:fc:0f:fe:zz", "", true},
+		{"d:ec:af:c0:ff:ee:1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizeHWAddr(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NormalizeHWAddr(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("NormalizeHWAddr(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheRefresh_NormalizesHWAddrs(t *testing.T) {
+	f := &fakeSMD{
+		ifaces: `[
+			{"MACAddress":"A4BF0112AB3C","ComponentID":"x3000c0s0b0n0"},
+			{"MACAddress":"de-ca-fc-0f-fe-e1","ComponentID":"x3000c0s1b0n0"},
+			{"MACAddress":"DE:CA:FC:0F:FE:E1","ComponentID":"x3000c0s2b0n0"},
+			{"MACAddress":"not-a-mac","ComponentID":"x3000c0s3b0n0"}
+		]`,
+		comps: `{"Components":[]}`,
+	}
+	c := newTestCache(t, f, 0)
+	for i := 0; i < 2; i++ {
+		if err := c.Refresh(); err != nil {
+			t.Fatalf("Refresh() unexpected error: %v", err)
+		}
+	}
+
+	if ei, ok := c.EthernetInterfaces["a4:bf:01:12:ab:3c"]; !ok || ei.MACAddress != "A4BF0112AB3C" {
+		t.Errorf("EthernetInterfaces[a4:bf:01:12:ab:3c] = %v, %v, want original MACAddress kept", ei, ok)
+	}
+	if ei := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; ei.ComponentID != "x3000c0s1b0n0" {
+		t.Errorf("EthernetInterfaces[de:ca:fc:0f:fe:e1] = %v, want the first of the colliding interfaces", ei)
+	}
+	if len(c.HWAddrCollisions) != 1 {
+		t.Fatalf("HWAddrCollisions = %v, want 1 (not accumulated across full refreshes)", c.HWAddrCollisions)
+	}
+	if col := c.HWAddrCollisions[0]; col.HWAddr != "de:ca:fc:0f:fe:e1" || col.Kept.ComponentID != "x3000c0s1b0n0" || col.Dropped.ComponentID != "x3000c0s2b0n0" {
+		t.Errorf("HWAddrCollisions[0] = %+v", col)
+	}
+	if _, ok := c.InvalidHWAddrs["not-a-mac"]; !ok || len(c.InvalidHWAddrs) != 1 {
+		t.Errorf("InvalidHWAddrs = %v, want not-a-mac", c.InvalidHWAddrs)
+	}
+	if _, ok := c.EthernetInterfaces["not-a-mac"]; !ok {
+		t.Errorf("EthernetInterfaces missing not-a-mac, want it kept under its MACAddress")
+	}
+	if len(c.EthernetInterfaces) != 3 {
+		t.Errorf("len(EthernetInterfaces) = %d, want 3", len(c.EthernetInterfaces))
+	}
+}
+
+func TestApplyEvent_NormalizesHWAddrs(t *testing.T) {
+	c := &Cache{}
+	c.Log = newTestCache(t, &fakeSMD{}, 0).Log
+
+	ei := smdclient.EthernetInterface{MACAddress: "DE-CA-FC-0F-FE-E1", ComponentID: "x3000c0s0b0n0"}
+	if err := c.ApplyEvent(Event{EthernetInterfaces: []smdclient.EthernetInterface{ei, {MACAddress: "bogus", ComponentID: "x3000c0s1b0n0"}}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+	if got := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; got.MACAddress != ei.MACAddress {
+		t.Fatalf("EthernetInterfaces[de:ca:fc:0f:fe:e1] = %v, want %v", got, ei)
+	}
+	if _, ok := c.InvalidHWAddrs["bogus"]; !ok {
+		t.Fatalf("InvalidHWAddrs = %v, want bogus", c.InvalidHWAddrs)
+	}
+
+	// Another interface with the same address in another notation is dropped
+	// in favor of the cached one, however often it is updated
+	other := smdclient.EthernetInterface{MACAddress: "de:ca:fc:0f:fe:e1", ComponentID: "x3000c0s2b0n0"}
+	for range 3 {
+		if err := c.ApplyEvent(Event{EthernetInterfaces: []smdclient.EthernetInterface{other}}); err != nil {
+			t.Fatalf("ApplyEvent() unexpected error: %v", err)
+		}
+	}
+	if got := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; got.ComponentID != ei.ComponentID {
+		t.Fatalf("EthernetInterfaces[de:ca:fc:0f:fe:e1] = %v, want %v", got, ei)
+	}
+	if len(c.HWAddrCollisions) != 1 || c.HWAddrCollisions[0].Dropped.ComponentID != other.ComponentID {
+		t.Fatalf("HWAddrCollisions = %+v, want only %s dropped", c.HWAddrCollisions, other.ComponentID)
+	}
+
+	// Deleting the dropped interface leaves the cached one alone
+	if err := c.ApplyEvent(Event{Action: EventActionDelete, EthernetInterfaces: []smdclient.EthernetInterface{other}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+	if got := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; got.ComponentID != ei.ComponentID {
+		t.Fatalf("EthernetInterfaces[de:ca:fc:0f:fe:e1] = %v, want %v", got, ei)
+	}
+	if len(c.HWAddrCollisions) != 0 {
+		t.Fatalf("HWAddrCollisions = %+v, want none", c.HWAddrCollisions)
+	}
+
+	// Deleting the cached interface puts the dropped one in its place
+	for _, ev := range []Event{
+		{EthernetInterfaces: []smdclient.EthernetInterface{other}},
+		{Action: EventActionDelete, EthernetInterfaces: []smdclient.EthernetInterface{ei}},
+	} {
+		if err := c.ApplyEvent(ev); err != nil {
+			t.Fatalf("ApplyEvent() unexpected error: %v", err)
+		}
+	}
+	if got := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; got.ComponentID != other.ComponentID {
+		t.Fatalf("EthernetInterfaces[de:ca:fc:0f:fe:e1] = %v, want %v", got, other)
+	}
+	if len(c.HWAddrCollisions) != 0 {
+		t.Fatalf("HWAddrCollisions = %+v, want none", c.HWAddrCollisions)
+	}
+
+	// Deletes match in any notation
+	if err := c.ApplyEvent(Event{Action: EventActionDelete, EthernetInterfaces: []smdclient.EthernetInterface{{MACAddress: "DECAFC0FFEE1"}, {MACAddress: "bogus"}}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+	if len(c.EthernetInterfaces) != 0 || len(c.InvalidHWAddrs) != 0 {
+		t.Fatalf("EthernetInterfaces = %v, InvalidHWAddrs = %v, want both empty", c.EthernetInterfaces, c.InvalidHWAddrs)
+	}
+}
+
+func TestGUIDKey(t *testing.T) {
+	c := &Cache{}
+	c.Log = newTestCache(t, &fakeSMD{}, 0).Log
+	guid := net.HardwareAddr{0x98, 0x03, 0x9b, 0x03, 0x00, 0x12, 0x34, 0x56}
+
+	ipoib := smdclient.EthernetInterface{MACAddress: "80000048FE8000000000000098039B0300123456", ComponentID: "x3000c0s0b0n0"}
+	if err := c.ApplyEvent(Event{EthernetInterfaces: []smdclient.EthernetInterface{ipoib, {MACAddress: "de:ca:fc:0f:fe:e1"}}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+	want := "80:00:00:48:fe:80:00:00:00:00:00:00:98:03:9b:03:00:12:34:56"
+	if key, ok := c.GUIDKey(guid); !ok || key != want {
+		t.Fatalf("GUIDKey() = %q, %v, want %q", key, ok, want)
+	}
+	if len(c.GUIDs) != 1 {
+		t.Fatalf("GUIDs = %v, want only the IPoIB interface", c.GUIDs)
+	}
+
+	// Another interface with the same GUID takes over when the indexed one
+	// is deleted
+	eui := smdclient.EthernetInterface{MACAddress: "98:03:9b:03:00:12:34:56", ComponentID: "x3000c0s0b0n0"}
+	for _, ev := range []Event{
+		{EthernetInterfaces: []smdclient.EthernetInterface{eui}},
+		{Action: EventActionDelete, EthernetInterfaces: []smdclient.EthernetInterface{ipoib}},
+	} {
+		if err := c.ApplyEvent(ev); err != nil {
+			t.Fatalf("ApplyEvent() unexpected error: %v", err)
+		}
+	}
+	if key, ok := c.GUIDKey(guid); !ok || key != eui.MACAddress {
+		t.Fatalf("GUIDKey() = %q, %v, want %q", key, ok, eui.MACAddress)
+	}
+
+	if err := c.ApplyEvent(Event{Action: EventActionDelete, EthernetInterfaces: []smdclient.EthernetInterface{eui}}); err != nil {
+		t.Fatalf("ApplyEvent() unexpected error: %v", err)
+	}
+	if key, ok := c.GUIDKey(guid); ok {
+		t.Fatalf("GUIDKey() = %q after deleting every interface, want none", key)
+	}
+}
@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+const (
+	groupsPath     = "/hsm/v2/groups"
+	partitionsPath = "/hsm/v2/partitions"
+)
+
+// Membership returns the labels of the groups the component with ID id is a
+// member of, in order, and the name of its partition, if any. The caller must
+// hold Mutex, as for the other contents of the cache.
+func (c *Cache) Membership(id string) (groups []string, partition string) {
+	return c.Groups[id], c.Partitions[id]
+}
+
+// membershipUpdate holds group and partition membership fetched from SMD.
+// Maps that were not modified since the last fetch are nil.
+type membershipUpdate struct {
+	groups          map[string][]string
+	partitions      map[string]string
+	groupValidators smdclient.Validators
+	partValidators  smdclient.Validators
+}
+
+// fetchMembership fetches SMD groups and partitions and indexes their members
+// by component ID. If conditional is true, they are requested conditionally
+// using the validators of the previous fetch.
+func (c *Cache) fetchMembership(conditional bool) (membershipUpdate, error) {
+	var (
+		u              membershipUpdate
+		groupV, partV  smdclient.Validators
+		groupsModified bool
+		partsModified  bool
+	)
+	if conditional {
+		groupV, partV = c.groupValidators, c.partValidators
+	}
+
+	c.Log.Debug("fetching groups")
+	data, v, modified, err := c.fetchOptional(groupsPath, groupV)
+	if err != nil {
+		return u, fmt.Errorf("failed to fetch groups from SMD: %w", err)
+	}
+	u.groupValidators, groupsModified = v, modified
+	if groupsModified {
+		var groups []smdclient.Group
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &groups); err != nil {
+				return u, fmt.Errorf("failed to unmarshal groups data: %w", err)
+			}
+		}
+		u.groups = make(map[string][]string)
+		for _, g := range groups {
+			for _, id := range g.Members.IDs {
+				u.groups[id] = append(u.groups[id], g.Label)
+			}
+		}
+		for _, labels := range u.groups {
+			sort.Strings(labels)
+		}
+	}
+
+	c.Log.Debug("fetching partitions")
+	data, v, modified, err = c.fetchOptional(partitionsPath, partV)
+	if err != nil {
+		return u, fmt.Errorf("failed to fetch partitions from SMD: %w", err)
+	}
+	u.partValidators, partsModified = v, modified
+	if partsModified {
+		var parts []smdclient.Partition
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &parts); err != nil {
+				return u, fmt.Errorf("failed to unmarshal partitions data: %w", err)
+			}
+		}
+		u.partitions = make(map[string]string)
+		for _, p := range parts {
+			for _, id := range p.Members.IDs {
+				if prev, ok := u.partitions[id]; ok {
+					c.Log.Warnf("component %s is a member of partitions %s and %s, using %s", id, prev, p.Name, prev)
+					continue
+				}
+				u.partitions[id] = p.Name
+			}
+		}
+	}
+
+	return u, nil
+}
+
+// fetchOptional fetches path conditionally. Resources SMD doesn't serve are
+// treated as empty and returned as nil data.
+func (c *Cache) fetchOptional(path string, v smdclient.Validators) (data []byte, newV smdclient.Validators, modified bool, err error) {
+	data, newV, notModified, err := c.Client.APIGetConditional(path, nil, v)
+	if errors.Is(err, smdclient.ErrNotFound) {
+		c.Log.Debugf("%s not found in SMD, assuming it is empty", path)
+		return nil, smdclient.Validators{}, true, nil
+	}
+	if err != nil {
+		return nil, v, false, err
+	}
+	if notModified {
+		return nil, v, false, nil
+	}
+	return data, newV, true, nil
+}
+
+// apply stores the modified membership in the cache and returns true if it
+// changed. The caller must hold Mutex for writing.
+func (u membershipUpdate) apply(c *Cache) bool {
+	changed := false
+	if u.groups != nil && !maps.EqualFunc(u.groups, c.Groups, slices.Equal) {
+		c.Groups = u.groups
+		changed = true
+	}
+	if u.partitions != nil && !maps.Equal(u.partitions, c.Partitions) {
+		c.Partitions = u.partitions
+		changed = true
+	}
+	c.groupValidators = u.groupValidators
+	c.partValidators = u.partValidators
+	return changed
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// snapshotVersion is the version of the snapshot file format. It is
+// incremented whenever an incompatible change is made to it.
+const snapshotVersion = 1
+
+// snapshot is the on-disk representation of the cache's contents.
+type snapshot struct {
+	Version            int                                    `json:"version"`
+	Timestamp          time.Time                              `json:"timestamp"`
+	EthernetInterfaces map[string]smdclient.EthernetInterface `json:"ethernet_interfaces"`
+	Components         map[string]smdclient.Component         `json:"components"`
+	Groups             map[string][]string                    `json:"groups,omitempty"`
+	Partitions         map[string]string                      `json:"partitions,omitempty"`
+}
+
+// WriteSnapshot writes the contents of the cache to SnapshotPath. The file is
+// written to a temporary file in the same directory and renamed into place so
+// that a crash never leaves a partially-written snapshot behind.
+func (c *Cache) WriteSnapshot() error {
+	if c.SnapshotPath == "" {
+		return fmt.Errorf("snapshot path is not set")
+	}
+
+	c.Mutex.RLock()
+	data, err := json.Marshal(snapshot{
+		Version:            snapshotVersion,
+		Timestamp:          c.LastUpdated,
+		EthernetInterfaces: c.EthernetInterfaces,
+		Components:         c.Components,
+		Groups:             c.Groups,
+		Partitions:         c.Partitions,
+	})
+	c.Mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache snapshot: %w", err)
+	}
+
+	dir, base := filepath.Split(c.SnapshotPath)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.SnapshotPath); err != nil {
+		return fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+
+	c.Log.Debugf("wrote cache snapshot to %s", c.SnapshotPath)
+
+	return nil
+}
+
+// LoadSnapshot replaces the contents of the cache with those of the snapshot
+// at SnapshotPath. LastUpdated is set to the time the snapshot's data was
+// fetched from SMD, so the age of the cache reflects the age of the data, and
+// LoadedFromSnapshot is set until the next successful refresh.
+func (c *Cache) LoadSnapshot() error {
+	if c.SnapshotPath == "" {
+		return fmt.Errorf("snapshot path is not set")
+	}
+
+	data, err := os.ReadFile(c.SnapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d (expected %d)", snap.Version, snapshotVersion)
+	}
+	if snap.Components == nil {
+		snap.Components = make(map[string]smdclient.Component)
+	}
+
+	// Key the interfaces anew, in case they were written with other keys
+	ifaces := make([]smdclient.EthernetInterface, 0, len(snap.EthernetInterfaces))
+	for _, ei := range snap.EthernetInterfaces {
+		ifaces = append(ifaces, ei)
+	}
+	slices.SortFunc(ifaces, func(a, b smdclient.EthernetInterface) int { return strings.Compare(a.MACAddress, b.MACAddress) })
+
+	c.Mutex.Lock()
+	c.indexIfaces(ifaces)
+	c.Components = snap.Components
+	c.Groups = snap.Groups
+	c.Partitions = snap.Partitions
+	c.LastUpdated = snap.Timestamp
+	c.LoadedFromSnapshot = true
+	c.Generation++
+	c.Mutex.Unlock()
+	c.notifyUpdate()
+
+	c.Log.Warnf("loaded cache snapshot from %s with %d EthernetInterfaces and %d Components, data is %s old and may be stale",
+		c.SnapshotPath, len(snap.EthernetInterfaces), len(snap.Components), time.Since(snap.Timestamp).Round(time.Second))
+
+	return nil
+}
+
+// Stale returns true and the age of the cached data if it was loaded from a
+// snapshot and has not been refreshed from SMD since.
+func (c *Cache) Stale() (bool, time.Duration) {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+
+	if !c.LoadedFromSnapshot {
+		return false, 0
+	}
+	return true, time.Since(c.LastUpdated)
+}
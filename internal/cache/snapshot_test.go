@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.json")
+
+	c := newEventTestCache(t, "http://smd.example.test")
+	c.SnapshotPath = path
+	c.LastUpdated = time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	c.Groups = map[string][]string{"x3000c0s0b0n0": {"compute"}}
+	c.Partitions = map[string]string{"x3000c0s0b0n0": "p1"}
+	if err := c.WriteSnapshot(); err != nil {
+		t.Fatalf("WriteSnapshot() unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read snapshot dir: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "cache.json" {
+		t.Fatalf("snapshot dir contains %v, want only cache.json", entries)
+	}
+
+	loaded, err := NewCache(nil, "30s", &smdclient.SmdClient{})
+	if err != nil {
+		t.Fatalf("NewCache() unexpected error: %v", err)
+	}
+	loaded.SnapshotPath = path
+	if err := loaded.LoadSnapshot(); err != nil {
+		t.Fatalf("LoadSnapshot() unexpected error: %v", err)
+	}
+
+	if !loaded.LastUpdated.Equal(c.LastUpdated) {
+		t.Errorf("LastUpdated = %v, want %v", loaded.LastUpdated, c.LastUpdated)
+	}
+	if _, ok := loaded.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; !ok {
+		t.Errorf("EthernetInterfaces = %v, missing de:ca:fc:0f:fe:e1", loaded.EthernetInterfaces)
+	}
+	if loaded.Components["x3000c0s0b0n0"].NID != 1 {
+		t.Errorf("Components = %v, missing x3000c0s0b0n0", loaded.Components)
+	}
+	if groups, partition := loaded.Membership("x3000c0s0b0n0"); len(groups) != 1 || groups[0] != "compute" || partition != "p1" {
+		t.Errorf("Membership() = %v, %q, want [compute] and p1", groups, partition)
+	}
+	stale, age := loaded.Stale()
+	if !stale || age < 10*time.Minute {
+		t.Errorf("Stale() = %v, %s, want true and at least 10m", stale, age)
+	}
+}
+
+func TestLoadSnapshot_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		contents *string
+	}{
+		{name: "missing_file"},
+		{name: "invalid_json", contents: ptr(`{"version":`)},
+		{name: "wrong_version", contents: ptr(`{"version":999,"timestamp":"2026-01-02T03:04:05Z"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".json")
+			if tt.contents != nil {
+				if err := os.WriteFile(path, []byte(*tt.contents), 0o600); err != nil {
+					t.Fatalf("failed to write snapshot: %v", err)
+				}
+			}
+
+			c, err := NewCache(nil, "30s", &smdclient.SmdClient{})
+			if err != nil {
+				t.Fatalf("NewCache() unexpected error: %v", err)
+			}
+			c.SnapshotPath = path
+			if err := c.LoadSnapshot(); err == nil {
+				t.Fatalf("LoadSnapshot() error = nil, want non-nil")
+			}
+			if c.LoadedFromSnapshot || c.EthernetInterfaces != nil {
+				t.Errorf("cache modified by failed LoadSnapshot()")
+			}
+		})
+	}
+}
+
+func TestRefreshLoop_FallsBackToSnapshot(t *testing.T) {
+	var smdUp atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !smdUp.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case ethIfacesPath:
+			_, _ = w.Write([]byte(`[{"MACAddress":"de:ca:fc:0f:fe:e2","ComponentID":"x3000c0s1b0n0"}]`))
+		case componentsPath:
+			_, _ = w.Write([]byte(`{"Components":[{"ID":"x3000c0s1b0n0","NID":2,"Type":"Node"}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cache.json")
+	old := newEventTestCache(t, srv.URL)
+	old.SnapshotPath = path
+	old.LastUpdated = time.Now().Add(-time.Hour)
+	if err := old.WriteSnapshot(); err != nil {
+		t.Fatalf("WriteSnapshot() unexpected error: %v", err)
+	}
+
+	baseURL, _ := url.Parse(srv.URL)
+	client := smdclient.NewSmdClient(baseURL)
+	client.Client = srv.Client()
+	client.Retry = smdclient.RetryPolicy{}
+	c, err := NewCache(nil, "1h", client)
+	if err != nil {
+		t.Fatalf("NewCache() unexpected error: %v", err)
+	}
+	c.SnapshotPath = path
+
+	// SMD is down, so the snapshot should be served
+	c.RefreshLoop()
+	if stale, _ := c.Stale(); !stale {
+		t.Fatalf("Stale() = false after initial refresh failed, want true")
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e1"]; !ok {
+		t.Fatalf("EthernetInterfaces = %v, want snapshot contents", c.EthernetInterfaces)
+	}
+
+	// Once SMD is back, a refresh replaces the data and rewrites the snapshot
+	smdUp.Store(true)
+	if err := c.Refresh(); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	if stale, _ := c.Stale(); stale {
+		t.Errorf("Stale() = true after successful refresh, want false")
+	}
+	c.EthernetInterfaces = nil
+	if err := c.LoadSnapshot(); err != nil {
+		t.Fatalf("LoadSnapshot() unexpected error: %v", err)
+	}
+	if _, ok := c.EthernetInterfaces["de:ca:fc:0f:fe:e2"]; !ok {
+		t.Errorf("snapshot not rewritten after refresh: %v", c.EthernetInterfaces)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
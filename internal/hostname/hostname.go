@@ -12,6 +12,9 @@ import (
 	"strings"
 )
 
+// nidPattern matches {Nd} placeholders in hostname patterns.
+var nidPattern = regexp.MustCompile(`\{0*(\d+)d\}`)
+
 // ExpandHostnamePattern replaces {Nd} with zero-padded NID and {id} with xname
 // Example patterns:
 //   - "nid{04d}" with NID=1 => "nid0001"
@@ -20,9 +23,8 @@ import (
 //   - "{id}" with xname="x3000c0s0b1" => "x3000c0s0b1"
 func ExpandHostnamePattern(pattern string, nid int64, id string) string {
 	out := strings.ReplaceAll(pattern, "{id}", id)
-	re := regexp.MustCompile(`\{0*(\d+)d\}`)
-	out = re.ReplaceAllStringFunc(out, func(m string) string {
-		nStr := re.FindStringSubmatch(m)[1]
+	out = nidPattern.ReplaceAllStringFunc(out, func(m string) string {
+		nStr := nidPattern.FindStringSubmatch(m)[1]
 		n, _ := strconv.Atoi(nStr)
 		return fmt.Sprintf("%0*d", n, nid)
 	})
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package iface
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/cache"
+)
+
+// ClientHWAddr returns the hardware address identifying the DHCPv4 client
+// sending req. This is chaddr, except for clients of other hardware types than
+// Ethernet presenting a client identifier (option 61). IPoIB clients (hardware
+// type 32) send an empty chaddr and a client identifier ending in the 8-byte
+// GUID of their port (RFC 4390), which is returned for them. Returns an empty
+// address if there is neither.
+func ClientHWAddr(req *dhcpv4.DHCPv4) net.HardwareAddr {
+	if req.HWType != iana.HWTypeEthernet {
+		if cid := req.Options.Get(dhcpv4.OptionClientIdentifier); len(cid) >= cache.GUIDLen {
+			return net.HardwareAddr(bytes.Clone(cid[len(cid)-cache.GUIDLen:]))
+		}
+	}
+	return req.ClientHWAddr
+}
+
+// ClientKey returns the key of c.EthernetInterfaces holding the interface of
+// the DHCPv4 client sending req, as identified by ClientHWAddr. InfiniBand
+// GUIDs also match interfaces recorded in SMD with the GUID in another notation
+// or with the 20-byte IPoIB hardware address ending in it. If no interface
+// matches, the address of the client is returned as a string.
+//
+// The caller must hold c.Mutex for reading.
+func ClientKey(c *cache.Cache, req *dhcpv4.DHCPv4) string {
+	hw := ClientHWAddr(req)
+	key := hw.String()
+	if c == nil || len(hw) != cache.GUIDLen {
+		return key
+	}
+	if _, ok := c.EthernetInterfaces[key]; ok {
+		return key
+	}
+	if guidKey, ok := c.GUIDKey(hw); ok {
+		return guidKey
+	}
+	return key
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package iface
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// ipoibClientID is an IPoIB client identifier (type 255, IAID, DUID-EN)
+// ending in the port GUID 98:03:9b:03:00:12:34:56.
+var ipoibClientID = []byte{
+	0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x02, 0xc9, 0x00,
+	0x98, 0x03, 0x9b, 0x03, 0x00, 0x12, 0x34, 0x56,
+}
+
+func mkIPoIBRequest(t *testing.T, cid []byte) *dhcpv4.DHCPv4 {
+	t.Helper()
+	req, err := dhcpv4.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.HWType = iana.HWTypeInfiniband
+	req.ClientHWAddr = nil
+	if cid != nil {
+		req.Options.Update(dhcpv4.OptClientIdentifier(cid))
+	}
+	return req
+}
+
+func TestClientHWAddr(t *testing.T) {
+	eth, err := dhcpv4.New(dhcpv4.WithHwAddr(net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth.Options.Update(dhcpv4.OptClientIdentifier([]byte{0x01, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff, 0x00, 0x01}))
+
+	tests := []struct {
+		name string
+		req  *dhcpv4.DHCPv4
+		want string
+	}{
+		{"ethernet_ignores_client_id", eth, "aa:bb:cc:dd:ee:ff"},
+		{"ipoib_guid", mkIPoIBRequest(t, ipoibClientID), "98:03:9b:03:00:12:34:56"},
+		{"ipoib_short_client_id", mkIPoIBRequest(t, []byte{0xff, 0x01}), ""},
+		{"ipoib_no_client_id", mkIPoIBRequest(t, nil), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientHWAddr(tt.req).String(); got != tt.want {
+				t.Fatalf("ClientHWAddr()=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	// mkCache caches interfaces as the cache package does: keyed by their
+	// normalized hardware addresses and indexed by GUID
+	mkCache := func(macs ...string) *cache.Cache {
+		c := &cache.Cache{
+			EthernetInterfaces: map[string]smdclient.EthernetInterface{},
+			GUIDs:              map[string]string{},
+		}
+		for _, mac := range macs {
+			key, err := cache.NormalizeHWAddr(mac)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.EthernetInterfaces[key] = smdclient.EthernetInterface{MACAddress: mac, ComponentID: "x0c0s0b0n0"}
+			if hw, _ := net.ParseMAC(key); len(hw) >= cache.GUIDLen {
+				c.GUIDs[hw[len(hw)-cache.GUIDLen:].String()] = key
+			}
+		}
+		return c
+	}
+	req := mkIPoIBRequest(t, ipoibClientID)
+
+	tests := []struct {
+		name string
+		c    *cache.Cache
+		want string
+	}{
+		{"guid", mkCache("aa:bb:cc:dd:ee:ff", "98:03:9b:03:00:12:34:56"), "98:03:9b:03:00:12:34:56"},
+		{"guid_other_notation", mkCache("98-03-9B-03-00-12-34-56"), "98:03:9b:03:00:12:34:56"},
+		{"ipoib_hwaddr", mkCache("80:00:00:48:fe:80:00:00:00:00:00:00:98:03:9b:03:00:12:34:56"), "80:00:00:48:fe:80:00:00:00:00:00:00:98:03:9b:03:00:12:34:56"},
+		{"other_guid", mkCache("98:03:9b:03:00:12:34:57"), "98:03:9b:03:00:12:34:56"},
+		{"nil_cache", nil, "98:03:9b:03:00:12:34:56"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientKey(tt.c, req); got != tt.want {
+				t.Fatalf("ClientKey()=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
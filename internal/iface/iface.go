@@ -11,6 +11,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/smdclient"
 	"github.com/openchami/coresmd/internal/subnet"
 )
 
@@ -22,6 +23,61 @@ type IfaceInfo struct {
 	Type    string
 	MAC     string
 	IPList  []net.IP
+
+	// State and inventory details of the component
+	State   string
+	Flag    string
+	Enabled bool
+	Role    string
+	SubRole string
+	Arch    string
+	Class   string
+
+	// Labels of the SMD groups the component is a member of and the name of
+	// its partition, if any
+	Groups    []string
+	Partition string
+}
+
+// ComponentInfo returns the IfaceInfo describing comp without any interface
+// details. As with LookupMAC, the NID is only set for components of type Node.
+func ComponentInfo(comp smdclient.Component) IfaceInfo {
+	ii := IfaceInfo{CompID: comp.ID}
+	ii.setComponent(comp)
+	return ii
+}
+
+// setComponent fills in the details of comp, the component the interface
+// belongs to.
+func (ii *IfaceInfo) setComponent(comp smdclient.Component) {
+	ii.Type = comp.Type
+	if ii.Type == "Node" {
+		ii.CompNID = comp.NID
+	}
+	ii.State = comp.State
+	ii.Flag = comp.Flag
+	ii.Enabled = comp.IsEnabled()
+	ii.Role = comp.Role
+	ii.SubRole = comp.SubRole
+	ii.Arch = comp.Arch
+	ii.Class = comp.Class
+}
+
+// NewIfaceInfo returns the IfaceInfo for ei, which belongs to comp, with all of
+// its parseable IP addresses. As with LookupMAC, the NID is only set for
+// components of type Node.
+func NewIfaceInfo(ei smdclient.EthernetInterface, comp smdclient.Component) IfaceInfo {
+	ii := IfaceInfo{
+		CompID: ei.ComponentID,
+		MAC:    ei.MACAddress,
+	}
+	ii.setComponent(comp)
+	for _, ipStr := range ei.IPAddresses {
+		if ip := net.ParseIP(ipStr.IPAddress); ip != nil {
+			ii.IPList = append(ii.IPList, ip)
+		}
+	}
+	return ii
 }
 
 // LookupMAC takes a MAC address and returns an IfaceInfo that corresponds to
@@ -50,11 +106,9 @@ func LookupMAC(log *logrus.Entry, mac string, c *cache.Cache) (IfaceInfo, error)
 	if !ok {
 		return ii, fmt.Errorf("no Component %s found in cache for EthernetInterface hardware address %s", ii.CompID, ii.MAC)
 	}
-	ii.Type = comp.Type
+	ii.setComponent(comp)
+	ii.Groups, ii.Partition = c.Membership(ii.CompID)
 	log.Debugf("matching Component of type %s with ID %s found in cache for hardware address %s", ii.Type, ii.CompID, ii.MAC)
-	if ii.Type == "Node" {
-		ii.CompNID = comp.NID
-	}
 	if len(ei.IPAddresses) == 0 {
 		return ii, fmt.Errorf("EthernetInterface for Component %s (type %s) contains no IP addresses for hardware address %s", ii.CompID, ii.Type, ii.MAC)
 	}
@@ -97,11 +151,9 @@ func LookupMACWithSubnet(log *logrus.Entry, mac string, giaddr net.IP, c *cache.
 	if !ok {
 		return ii, fmt.Errorf("no Component %s found in cache for EthernetInterface hardware address %s", ii.CompID, ii.MAC)
 	}
-	ii.Type = comp.Type
+	ii.setComponent(comp)
+	ii.Groups, ii.Partition = c.Membership(ii.CompID)
 	log.Debugf("matching Component of type %s with ID %s found in cache for hardware address %s", ii.Type, ii.CompID, ii.MAC)
-	if ii.Type == "Node" {
-		ii.CompNID = comp.NID
-	}
 	if len(ei.IPAddresses) == 0 {
 		return ii, fmt.Errorf("EthernetInterface for Component %s (type %s) contains no IP addresses for hardware address %s", ii.CompID, ii.Type, ii.MAC)
 	}
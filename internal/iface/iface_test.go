@@ -179,6 +179,7 @@ func TestLookupMACWithSubnet_Table(t *testing.T) {
 }
 
 func TestLookupMAC_Table(t *testing.T) {
+	disabled := false
 	mkCache := func() *cache.Cache {
 		c := &cache.Cache{}
 		c.EthernetInterfaces = map[string]smdclient.EthernetInterface{
@@ -186,9 +187,11 @@ func TestLookupMAC_Table(t *testing.T) {
 			"11:22:33:44:55:66": {MACAddress: "11:22:33:44:55:66", ComponentID: "x0c0s0b0b0", IPAddresses: []smdclient.IPAddress{{IPAddress: "172.16.10.20"}}},
 		}
 		c.Components = map[string]smdclient.Component{
-			"x0c0s0b0n0": {ID: "x0c0s0b0n0", NID: 7, Type: "Node"},
-			"x0c0s0b0b0": {ID: "x0c0s0b0b0", Type: "NodeBMC"},
+			"x0c0s0b0n0": {ID: "x0c0s0b0n0", NID: 7, Type: "Node", State: "Ready", Role: "Compute", Arch: "ARM"},
+			"x0c0s0b0b0": {ID: "x0c0s0b0b0", Type: "NodeBMC", Enabled: &disabled},
 		}
+		c.Groups = map[string][]string{"x0c0s0b0n0": {"compute", "gpu"}}
+		c.Partitions = map[string]string{"x0c0s0b0n0": "p1"}
 		return c
 	}
 
@@ -216,6 +219,12 @@ func TestLookupMAC_Table(t *testing.T) {
 				if len(ii.IPList) != 2 || !ii.IPList[0].Equal(net.ParseIP("172.16.0.10")) {
 					t.Fatalf("ips=%v", ii.IPList)
 				}
+				if ii.State != "Ready" || ii.Role != "Compute" || ii.Arch != "ARM" || !ii.Enabled {
+					t.Fatalf("state/role/arch/enabled=%s/%s/%s/%v", ii.State, ii.Role, ii.Arch, ii.Enabled)
+				}
+				if len(ii.Groups) != 2 || ii.Groups[1] != "gpu" || ii.Partition != "p1" {
+					t.Fatalf("groups/partition=%v/%s", ii.Groups, ii.Partition)
+				}
 			},
 		},
 		{
@@ -229,6 +238,9 @@ func TestLookupMAC_Table(t *testing.T) {
 				if ii.CompNID != 0 {
 					t.Fatalf("nid=%d", ii.CompNID)
 				}
+				if ii.Enabled {
+					t.Fatalf("enabled=%v", ii.Enabled)
+				}
 			},
 		},
 		{
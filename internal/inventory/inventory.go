@@ -0,0 +1,283 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+// Package inventory checks the SMD data in the cache for inconsistencies that
+// would otherwise only show up as failed lookups when a node boots.
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/rule"
+)
+
+// Kinds of findings
+const (
+	KindDuplicateIP       = "duplicate_ip"       // IP address of interfaces with different hardware addresses
+	KindOrphanInterface   = "orphan_interface"   // interface whose ComponentID has no Component
+	KindNoIPs             = "no_ips"             // interface without usable IP addresses
+	KindIPOutsideSubnets  = "ip_outside_subnets" // IP address outside every rule subnet
+	KindHostnameCollision = "hostname_collision" // hostname set by rules for different components
+	KindInvalidHWAddr     = "invalid_hwaddr"     // unparseable hardware address
+	KindHWAddrCollision   = "hwaddr_collision"   // hardware address of different interfaces
+)
+
+// Kinds lists all kinds of findings.
+var Kinds = []string{
+	KindDuplicateIP,
+	KindOrphanInterface,
+	KindNoIPs,
+	KindIPOutsideSubnets,
+	KindHostnameCollision,
+	KindInvalidHWAddr,
+	KindHWAddrCollision,
+}
+
+// Finding is an inconsistency in the SMD data.
+type Finding struct {
+	Kind         string   `json:"kind"`
+	Message      string   `json:"message"`
+	MACs         []string `json:"macs,omitempty"`          // hardware addresses of the interfaces concerned
+	ComponentIDs []string `json:"component_ids,omitempty"` // components concerned
+	IP           string   `json:"ip,omitempty"`
+	Hostname     string   `json:"hostname,omitempty"`
+}
+
+// Report holds the findings of a check of the SMD data.
+type Report struct {
+	Time               time.Time      `json:"time"`
+	EthernetInterfaces int            `json:"ethernet_interfaces"`
+	Components         int            `json:"components"`
+	Counts             map[string]int `json:"counts"` // number of findings by kind
+	Findings           []Finding      `json:"findings"`
+}
+
+// Check analyzes the EthernetInterfaces and Components in c. Hostnames are
+// generated as for DHCP from rules, with globalDomain as the domain, and IP
+// addresses are checked against the subnets rules match on. The caller must
+// hold c.Mutex for reading.
+func Check(c *cache.Cache, globalDomain string, rules []rule.Rule) Report {
+	r := Report{
+		Time:               time.Now(),
+		EthernetInterfaces: len(c.EthernetInterfaces),
+		Components:         len(c.Components),
+		Counts:             make(map[string]int, len(Kinds)),
+		Findings:           []Finding{},
+	}
+	for _, kind := range Kinds {
+		r.Counts[kind] = 0
+	}
+
+	var subnets []*net.IPNet
+	for _, rl := range rules {
+		subnets = append(subnets, rl.Match.Subnets...)
+	}
+
+	var (
+		ipMACs    = make(map[string][]string)            // MACs by IP address
+		hostComps = make(map[string]map[string][]string) // MACs by component ID by hostname
+	)
+	for _, mac := range slices.Sorted(maps.Keys(c.EthernetInterfaces)) {
+		ei := c.EthernetInterfaces[mac]
+		comp, compOK := c.Components[ei.ComponentID]
+		if !compOK {
+			r.add(Finding{
+				Kind:         KindOrphanInterface,
+				Message:      fmt.Sprintf("EthernetInterface %s belongs to component %q, which does not exist", mac, ei.ComponentID),
+				MACs:         []string{mac},
+				ComponentIDs: []string{ei.ComponentID},
+			})
+		}
+
+		var ips []net.IP
+		for _, ipAddr := range ei.IPAddresses {
+			if ip := net.ParseIP(strings.TrimSpace(ipAddr.IPAddress)); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		if len(ips) == 0 {
+			r.add(Finding{
+				Kind:         KindNoIPs,
+				Message:      fmt.Sprintf("EthernetInterface %s of component %s has no usable IP addresses", mac, ei.ComponentID),
+				MACs:         []string{mac},
+				ComponentIDs: []string{ei.ComponentID},
+			})
+		}
+
+		for _, ip := range ips {
+			if !slices.Contains(ipMACs[ip.String()], mac) {
+				ipMACs[ip.String()] = append(ipMACs[ip.String()], mac)
+			}
+			if outsideSubnets(ip, subnets) {
+				r.add(Finding{
+					Kind:         KindIPOutsideSubnets,
+					Message:      fmt.Sprintf("IP address %s of EthernetInterface %s (component %s) is outside every rule subnet", ip, mac, ei.ComponentID),
+					MACs:         []string{mac},
+					ComponentIDs: []string{ei.ComponentID},
+					IP:           ip.String(),
+				})
+			}
+		}
+
+		// Rules can only be evaluated with the component, and name each
+		// address as it would be named when served over DHCP
+		if !compOK || len(rules) == 0 {
+			continue
+		}
+		ii := iface.NewIfaceInfo(ei, comp)
+		ii.MAC = mac
+		ii.Groups, ii.Partition = c.Membership(comp.ID)
+		for _, ip := range ips {
+			ii.IPList = []net.IP{ip}
+			if _, fqdn, ok := rule.Hostname(ii, globalDomain, rules); ok && fqdn != "" {
+				if hostComps[fqdn] == nil {
+					hostComps[fqdn] = make(map[string][]string)
+				}
+				if !slices.Contains(hostComps[fqdn][comp.ID], mac) {
+					hostComps[fqdn][comp.ID] = append(hostComps[fqdn][comp.ID], mac)
+				}
+			}
+		}
+	}
+
+	for _, ip := range slices.Sorted(maps.Keys(ipMACs)) {
+		ms := ipMACs[ip]
+		if len(ms) < 2 {
+			continue
+		}
+		var compIDs []string
+		for _, mac := range ms {
+			if id := c.EthernetInterfaces[mac].ComponentID; !slices.Contains(compIDs, id) {
+				compIDs = append(compIDs, id)
+			}
+		}
+		r.add(Finding{
+			Kind:         KindDuplicateIP,
+			Message:      fmt.Sprintf("IP address %s is assigned to %d EthernetInterfaces: %s", ip, len(ms), strings.Join(ms, ", ")),
+			MACs:         ms,
+			ComponentIDs: compIDs,
+			IP:           ip,
+		})
+	}
+
+	for _, fqdn := range slices.Sorted(maps.Keys(hostComps)) {
+		comps := hostComps[fqdn]
+		if len(comps) < 2 {
+			continue
+		}
+		compIDs := slices.Sorted(maps.Keys(comps))
+		var ms []string
+		for _, id := range compIDs {
+			ms = append(ms, comps[id]...)
+		}
+		r.add(Finding{
+			Kind:         KindHostnameCollision,
+			Message:      fmt.Sprintf("hostname %s is generated for %d components: %s", fqdn, len(compIDs), strings.Join(compIDs, ", ")),
+			MACs:         ms,
+			ComponentIDs: compIDs,
+			Hostname:     fqdn,
+		})
+	}
+
+	for _, mac := range slices.Sorted(maps.Keys(c.InvalidHWAddrs)) {
+		ei := c.InvalidHWAddrs[mac]
+		r.add(Finding{
+			Kind:         KindInvalidHWAddr,
+			Message:      fmt.Sprintf("EthernetInterface %q of component %s has an unparseable hardware address", mac, ei.ComponentID),
+			MACs:         []string{mac},
+			ComponentIDs: []string{ei.ComponentID},
+		})
+	}
+
+	for _, col := range c.HWAddrCollisions {
+		r.add(Finding{
+			Kind: KindHWAddrCollision,
+			Message: fmt.Sprintf("EthernetInterfaces %q (component %s) and %q (component %s) have the same hardware address %s, the latter is ignored",
+				col.Kept.MACAddress, col.Kept.ComponentID, col.Dropped.MACAddress, col.Dropped.ComponentID, col.HWAddr),
+			MACs:         []string{col.Kept.MACAddress, col.Dropped.MACAddress},
+			ComponentIDs: []string{col.Kept.ComponentID, col.Dropped.ComponentID},
+		})
+	}
+
+	return r
+}
+
+// add adds f to the findings of r.
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+	r.Counts[f.Kind]++
+}
+
+// NewSince returns the findings of r that are not in prev.
+func (r Report) NewSince(prev Report) []Finding {
+	seen := make(map[string]bool, len(prev.Findings))
+	for _, f := range prev.Findings {
+		seen[f.Kind+" "+f.Message] = true
+	}
+	var found []Finding
+	for _, f := range r.Findings {
+		if !seen[f.Kind+" "+f.Message] {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// WriteFile writes r as JSON to path. The file is written to a temporary file
+// in the same directory and renamed into place so that readers never see a
+// partially-written report.
+func (r Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory report: %w", err)
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary report file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move report into place: %w", err)
+	}
+	return nil
+}
+
+// outsideSubnets returns true if subnets contains subnets of the address
+// family of ip but none of them contains ip.
+func outsideSubnets(ip net.IP, subnets []*net.IPNet) bool {
+	familyFound := false
+	for _, sn := range subnets {
+		if (sn.IP.To4() != nil) != (ip.To4() != nil) {
+			continue
+		}
+		familyFound = true
+		if sn.Contains(ip) {
+			return false
+		}
+	}
+	return familyFound
+}
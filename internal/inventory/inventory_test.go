@@ -0,0 +1,135 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package inventory
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/rule"
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+func mkIface(mac, compID string, ips ...string) smdclient.EthernetInterface {
+	ei := smdclient.EthernetInterface{MACAddress: mac, ComponentID: compID}
+	for _, ip := range ips {
+		ei.IPAddresses = append(ei.IPAddresses, smdclient.IPAddress{IPAddress: ip})
+	}
+	return ei
+}
+
+func testCache() *cache.Cache {
+	c := &cache.Cache{
+		EthernetInterfaces: map[string]smdclient.EthernetInterface{},
+		Components: map[string]smdclient.Component{
+			"x3000c0s0b0n0": {ID: "x3000c0s0b0n0", NID: 1, Type: "Node"},
+			"x3000c0s1b0n0": {ID: "x3000c0s1b0n0", NID: 2, Type: "Node"},
+			"x3000c0s2b0n0": {ID: "x3000c0s2b0n0", NID: 1, Type: "Node"}, // same NID as x3000c0s0b0n0
+			"x3000c0s3b0n0": {ID: "x3000c0s3b0n0", NID: 3, Type: "Node"},
+		},
+		InvalidHWAddrs: map[string]smdclient.EthernetInterface{
+			"bogus": mkIface("bogus", "x3000c0s3b0n0", "172.16.0.4"),
+		},
+	}
+	for _, ei := range []smdclient.EthernetInterface{
+		mkIface("de:ca:fc:0f:fe:e1", "x3000c0s0b0n0", "172.16.0.1"),
+		mkIface("de:ca:fc:0f:fe:e2", "x3000c0s1b0n0", "172.16.0.1", "fd00::2"),
+		mkIface("de:ca:fc:0f:fe:e3", "x3000c0s2b0n0", "172.16.0.3"),
+		mkIface("de:ca:fc:0f:fe:e4", "x9999c0s0b0n0", "172.16.0.5"),
+		mkIface("de:ca:fc:0f:fe:e5", "x3000c0s3b0n0", "10.0.0.5"),
+		mkIface("de:ca:fc:0f:fe:e6", "x3000c0s3b0n0", "not-an-ip"),
+		mkIface("bogus", "x3000c0s3b0n0", "172.16.0.4"),
+	} {
+		c.EthernetInterfaces[ei.MACAddress] = ei
+	}
+	return c
+}
+
+func TestCheck(t *testing.T) {
+	r, err := rule.ParseRule("subnet:172.16.0.0/24,type:Node,hostname:nid{04d}")
+	if err != nil {
+		t.Fatalf("ParseRule: %v", err)
+	}
+	report := Check(testCache(), "example.test", []rule.Rule{r})
+
+	want := map[string]int{
+		KindDuplicateIP:       1, // 172.16.0.1
+		KindOrphanInterface:   1, // x9999c0s0b0n0
+		KindNoIPs:             1, // de:ca:fc:0f:fe:e6
+		KindIPOutsideSubnets:  1, // 10.0.0.5, but not fd00::2
+		KindHostnameCollision: 1, // nid0001 for x3000c0s0b0n0 and x3000c0s2b0n0
+		KindInvalidHWAddr:     1,
+		KindHWAddrCollision:   0,
+	}
+	for kind, n := range want {
+		if report.Counts[kind] != n {
+			t.Errorf("Counts[%s] = %d, want %d (findings: %+v)", kind, report.Counts[kind], n, report.Findings)
+		}
+	}
+	if report.EthernetInterfaces != 7 || report.Components != 4 {
+		t.Errorf("EthernetInterfaces=%d Components=%d, want 7 and 4", report.EthernetInterfaces, report.Components)
+	}
+
+	for _, f := range report.Findings {
+		switch f.Kind {
+		case KindDuplicateIP:
+			if f.IP != "172.16.0.1" || !slices.Equal(f.ComponentIDs, []string{"x3000c0s0b0n0", "x3000c0s1b0n0"}) {
+				t.Errorf("duplicate IP finding = %+v", f)
+			}
+		case KindHostnameCollision:
+			if f.Hostname != "nid0001.example.test" || !slices.Equal(f.ComponentIDs, []string{"x3000c0s0b0n0", "x3000c0s2b0n0"}) {
+				t.Errorf("hostname collision finding = %+v", f)
+			}
+		}
+	}
+}
+
+func TestCheck_NoRules(t *testing.T) {
+	report := Check(testCache(), "", nil)
+	if report.Counts[KindIPOutsideSubnets] != 0 || report.Counts[KindHostnameCollision] != 0 {
+		t.Errorf("Counts = %v, want no subnet or hostname findings without rules", report.Counts)
+	}
+	if report.Counts[KindDuplicateIP] != 1 {
+		t.Errorf("Counts[%s] = %d, want 1", KindDuplicateIP, report.Counts[KindDuplicateIP])
+	}
+}
+
+func TestReportNewSince(t *testing.T) {
+	c := testCache()
+	prev := Check(c, "", nil)
+	if got := prev.NewSince(prev); len(got) != 0 {
+		t.Fatalf("NewSince(self) = %v, want none", got)
+	}
+
+	c.EthernetInterfaces["de:ca:fc:0f:fe:e7"] = mkIface("de:ca:fc:0f:fe:e7", "x3000c0s3b0n0")
+	got := Check(c, "", nil).NewSince(prev)
+	if len(got) != 1 || got[0].Kind != KindNoIPs || !slices.Equal(got[0].MACs, []string{"de:ca:fc:0f:fe:e7"}) {
+		t.Fatalf("NewSince() = %+v, want only the new interface without IPs", got)
+	}
+}
+
+func TestReportWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	report := Check(testCache(), "", nil)
+	if err := report.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile() unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(got.Findings) != len(report.Findings) || got.Counts[KindNoIPs] != report.Counts[KindNoIPs] {
+		t.Fatalf("report read back = %+v, want %+v", got, report)
+	}
+}
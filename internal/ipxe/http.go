@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package ipxe
+
+import (
+	"bytes"
+	"net/url"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/sirupsen/logrus"
+)
+
+// HTTPClientClass is the vendor class presented by UEFI HTTP Boot clients
+// (e.g. "HTTPClient:Arch:00016:UNDI:003001"). Responses to them must echo it
+// for the client to accept the bootfile URL.
+const HTTPClientClass = "HTTPClient"
+
+// enterpriseIANA is the enterprise number HTTP Boot clients use for the
+// DHCPv6 vendor class option.
+const enterpriseIANA = 343
+
+// IsHTTPClient returns true if req comes from a UEFI HTTP Boot client.
+func IsHTTPClient(req *dhcpv4.DHCPv4) bool {
+	return bytes.HasPrefix(req.Options.Get(dhcpv4.OptionClassIdentifier), []byte(HTTPClientClass))
+}
+
+// IsHTTPClient6 returns true if req comes from a UEFI HTTP Boot client.
+func IsHTTPClient6(req *dhcpv6.Message) bool {
+	for _, vc := range req.Options.VendorClasses() {
+		for _, data := range vc.Data {
+			if bytes.HasPrefix(data, []byte(HTTPClientClass)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClientArch6 returns the first client system architecture presented in req
+// (option 61), and false if there is none.
+func ClientArch6(req *dhcpv6.Message) (iana.Arch, bool) {
+	if archs := req.Options.ArchTypes(); len(archs) > 0 {
+		return archs[0], true
+	}
+	return 0, false
+}
+
+// HTTPBootURL returns the URL of the bootloader file: file itself if it is an
+// absolute URL, otherwise file resolved against base. Returns an empty string
+// if file is relative and base is nil.
+func HTTPBootURL(base *url.URL, file string) string {
+	if u, err := url.Parse(file); err == nil && u.IsAbs() {
+		return file
+	}
+	if base == nil {
+		return ""
+	}
+	return base.JoinPath(file).String()
+}
+
+// bootloaderURL returns the URL of the bootloader for a client of
+// architecture arch, using file (resolved against base) if set and the
+// bootloader looked up with lookup otherwise. Returns an empty string, after
+// logging why, if there is none.
+func bootloaderURL(l *logrus.Entry, mac string, arch iana.Arch, archOK bool, lookup func(iana.Arch) (string, bool), file string, base *url.URL) string {
+	if file == "" {
+		if !archOK {
+			l.WithField("mac", mac).Info("client did not present an architecture, unable to provide correct iPXE bootloader")
+			return ""
+		}
+		var ok bool
+		if file, ok = lookup(arch); !ok {
+			l.WithFields(logrus.Fields{
+				"mac":     mac,
+				"arch_id": arch,
+				"arch":    arch.String(),
+			}).Info("no iPXE bootloader available for unknown architecture")
+			return ""
+		}
+	}
+	u := HTTPBootURL(base, file)
+	if u == "" {
+		l.WithField("mac", mac).Infof("no base URI to serve %s to client from, not sending bootloader", file)
+	}
+	return u
+}
+
+// ServeHTTP sets the bootfile URL for the UEFI HTTP Boot client req in resp
+// and echoes its vendor class. The bootloader is file if set, or the one from t
+// for the client otherwise, resolved against base if not an absolute URL.
+// Returns false if no bootloader could be set.
+func (t Bootloaders) ServeHTTP(l *logrus.Entry, req, resp *dhcpv4.DHCPv4, file string, base *url.URL) (*dhcpv4.DHCPv4, bool) {
+	if l == nil {
+		l = logrus.NewEntry(logrus.New())
+	}
+	arch, archOK := ClientArch(req)
+	lookup := func(arch iana.Arch) (string, bool) { return t.Lookup4(req, arch) }
+	u := bootloaderURL(l, req.ClientHWAddr.String(), arch, archOK, lookup, file, base)
+	if u == "" {
+		return resp, false
+	}
+	resp.Options.Update(dhcpv4.OptClassIdentifier(HTTPClientClass))
+	resp.Options.Update(dhcpv4.OptBootFileName(u))
+	return resp, true
+}
+
+// ServeHTTP6 is the DHCPv6 version of ServeHTTP, setting the Bootfile URL
+// option (59) and the vendor class in resp.
+func (t Bootloaders) ServeHTTP6(l *logrus.Entry, mac string, req, resp *dhcpv6.Message, file string, base *url.URL) (*dhcpv6.Message, bool) {
+	resp, ok := t.Serve6(l, mac, req, resp, file, base)
+	if !ok {
+		return resp, false
+	}
+	resp.UpdateOption(&dhcpv6.OptVendorClass{
+		EnterpriseNumber: enterpriseIANA,
+		Data:             [][]byte{[]byte(HTTPClientClass)},
+	})
+	return resp, true
+}
+
+// Serve6 sets the Bootfile URL option (59) for the DHCPv6 client req with
+// hardware address mac in resp. The bootloader is file if set, or the one from
+// t for the client architecture (option 61) otherwise, resolved against base
+// (e.g. tftp://[fd00::1]:69 or http://boot.example.com/boot) if not an
+// absolute URL. Returns false if no bootloader could be set.
+func (t Bootloaders) Serve6(l *logrus.Entry, mac string, req, resp *dhcpv6.Message, file string, base *url.URL) (*dhcpv6.Message, bool) {
+	if l == nil {
+		l = logrus.NewEntry(logrus.New())
+	}
+	arch, archOK := ClientArch6(req)
+	lookup := func(arch iana.Arch) (string, bool) { return t.Lookup6(req, arch) }
+	u := bootloaderURL(l, mac, arch, archOK, lookup, file, base)
+	if u == "" {
+		return resp, false
+	}
+	resp.UpdateOption(dhcpv6.OptBootFileURL(u))
+	return resp, true
+}
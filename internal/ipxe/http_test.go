@@ -0,0 +1,142 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package ipxe
+
+import (
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+func TestBootloadersServeHTTP(t *testing.T) {
+	base, _ := url.Parse("https://boot.example.test/ipxe")
+	mkReq := func(arch iana.Arch) *dhcpv4.DHCPv4 {
+		req := &dhcpv4.DHCPv4{ClientHWAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}, Options: dhcpv4.Options{}}
+		req.Options.Update(dhcpv4.OptClassIdentifier("HTTPClient:Arch:00016:UNDI:003001"))
+		req.Options.Update(dhcpv4.OptClientArch(arch))
+		return req
+	}
+
+	tests := []struct {
+		name        string
+		req         *dhcpv4.DHCPv4
+		file        string
+		base        *url.URL
+		wantHandled bool
+		wantBoot    string
+	}{
+		{"x86_64", mkReq(iana.EFI_X86_64_HTTP), "", base, true, "https://boot.example.test/ipxe/ipxe-x86_64.efi"},
+		{"arm64", mkReq(iana.EFI_ARM64_HTTP), "", base, true, "https://boot.example.test/ipxe/ipxe-arm64.efi"},
+		{"file", mkReq(iana.EFI_X86_64_HTTP), "uki/vmlinuz.efi", base, true, "https://boot.example.test/ipxe/uki/vmlinuz.efi"},
+		{"absolute_file", mkReq(iana.EFI_X86_64_HTTP), "http://172.16.0.254/ipxe.efi", nil, true, "http://172.16.0.254/ipxe.efi"},
+		{"no_base", mkReq(iana.EFI_X86_64_HTTP), "", nil, false, ""},
+		{"efi_arch", mkReq(iana.EFI_X86_64), "", base, true, "https://boot.example.test/ipxe/ipxe-x86_64.efi"},
+		{"unknown_arch", mkReq(iana.EFI_RISCV64_HTTP), "", base, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsHTTPClient(tt.req) {
+				t.Fatalf("IsHTTPClient=false")
+			}
+			resp, handled := DefaultBootloaders.ServeHTTP(nil, tt.req, &dhcpv4.DHCPv4{Options: dhcpv4.Options{}}, tt.file, tt.base)
+			if handled != tt.wantHandled {
+				t.Fatalf("handled=%v want %v", handled, tt.wantHandled)
+			}
+			if got := string(resp.Options.Get(dhcpv4.OptionBootfileName)); got != tt.wantBoot {
+				t.Fatalf("bootfile=%q want %q", got, tt.wantBoot)
+			}
+			wantClass := ""
+			if tt.wantHandled {
+				wantClass = HTTPClientClass
+			}
+			if got := string(resp.Options.Get(dhcpv4.OptionClassIdentifier)); got != wantClass {
+				t.Fatalf("vendor class=%q want %q", got, wantClass)
+			}
+		})
+	}
+
+	if IsHTTPClient(&dhcpv4.DHCPv4{Options: dhcpv4.Options{}}) {
+		t.Fatalf("IsHTTPClient=true for request without vendor class")
+	}
+}
+
+func TestBootloadersServeHTTP6(t *testing.T) {
+	base, _ := url.Parse("http://[fd00::1]:8080")
+	req, err := dhcpv6.NewMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte("HTTPClient:Arch:00016:UNDI:003001")}})
+	req.AddOption(dhcpv6.OptClientArchType(iana.EFI_X86_64_HTTP))
+	if !IsHTTPClient6(req) {
+		t.Fatalf("IsHTTPClient6=false")
+	}
+
+	resp, err := dhcpv6.NewMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, handled := DefaultBootloaders.ServeHTTP6(nil, "00:01:02:03:04:05", req, resp, "", base)
+	if !handled {
+		t.Fatalf("handled=false")
+	}
+	if got, want := resp.Options.BootFileURL(), "http://[fd00::1]:8080/ipxe-x86_64.efi"; got != want {
+		t.Fatalf("bootfile URL=%q want %q", got, want)
+	}
+	if got := resp.Options.VendorClass(343); len(got) != 1 || string(got[0]) != HTTPClientClass {
+		t.Fatalf("vendor class=%q want %q", got, HTTPClientClass)
+	}
+}
+
+func TestBootloadersServe6(t *testing.T) {
+	tftpBase, _ := url.Parse("tftp://[fd00::1]:69")
+	httpBase, _ := url.Parse("http://boot.example.test/boot")
+	mkReq := func(archs ...iana.Arch) *dhcpv6.Message {
+		req, err := dhcpv6.NewMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(archs) > 0 {
+			req.AddOption(dhcpv6.OptClientArchType(archs...))
+		}
+		return req
+	}
+
+	tests := []struct {
+		name    string
+		req     *dhcpv6.Message
+		file    string
+		base    *url.URL
+		want    string
+		handled bool
+	}{
+		{"tftp", mkReq(iana.EFI_X86_64), "", tftpBase, "tftp://[fd00::1]:69/ipxe-x86_64.efi", true},
+		{"http", mkReq(iana.EFI_ARM64), "", httpBase, "http://boot.example.test/boot/ipxe-arm64.efi", true},
+		{"rule_file", mkReq(iana.EFI_X86_64), "custom.efi", tftpBase, "tftp://[fd00::1]:69/custom.efi", true},
+		{"rule_url", mkReq(iana.EFI_X86_64), "http://[fd00::2]/uki.efi", nil, "http://[fd00::2]/uki.efi", true},
+		{"no_arch", mkReq(), "", tftpBase, "", false},
+		{"unknown_arch", mkReq(iana.EFI_RISCV64), "", tftpBase, "", false},
+		{"no_base", mkReq(iana.EFI_X86_64), "", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := dhcpv6.NewMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, handled := DefaultBootloaders.Serve6(nil, "00:01:02:03:04:05", tt.req, resp, tt.file, tt.base)
+			if handled != tt.handled {
+				t.Fatalf("handled=%v want %v", handled, tt.handled)
+			}
+			if got := resp.Options.BootFileURL(); got != tt.want {
+				t.Fatalf("bootfile URL=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,52 +7,96 @@ package ipxe
 
 import (
 	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/sirupsen/logrus"
 )
 
+// archNames maps the architecture names accepted in configuration to client
+// system architecture types (RFC 4578).
+var archNames = map[string]iana.Arch{
+	"bios":         iana.INTEL_X86PC,
+	"ia32":         iana.EFI_IA32,
+	"x86_64":       iana.EFI_X86_64,
+	"arm32":        iana.EFI_ARM32,
+	"arm64":        iana.EFI_ARM64,
+	"riscv32":      iana.EFI_RISCV32,
+	"riscv64":      iana.EFI_RISCV64,
+	"ia32_http":    iana.EFI_X86_HTTP,
+	"x86_64_http":  iana.EFI_X86_64_HTTP,
+	"arm32_http":   iana.EFI_ARM32_HTTP,
+	"arm64_http":   iana.EFI_ARM64_HTTP,
+	"riscv32_http": iana.EFI_RISCV32_HTTP,
+	"riscv64_http": iana.EFI_RISCV64_HTTP,
+}
+
+// ParseArch parses a client system architecture given either by name (bios,
+// ia32, x86_64, arm32, arm64, riscv32, riscv64, or one of the EFI names
+// followed by _http for UEFI HTTP Boot) or by its numeric type.
+func ParseArch(s string) (iana.Arch, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if arch, ok := archNames[s]; ok {
+		return arch, nil
+	}
+	n, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown architecture %q", s)
+	}
+	return iana.Arch(n), nil
+}
+
+// ArchName returns the configuration name of arch, or its numeric type if it
+// has none.
+func ArchName(arch iana.Arch) string {
+	for name, a := range archNames {
+		if a == arch {
+			return name
+		}
+	}
+	return strconv.Itoa(int(arch))
+}
+
+// ClientArch returns the first client system architecture presented in req
+// (option 93), and false if there is none.
+func ClientArch(req *dhcpv4.DHCPv4) (iana.Arch, bool) {
+	b := req.Options.Get(dhcpv4.OptionClientSystemArchitectureType)
+	if len(b) < 2 {
+		return 0, false
+	}
+	return iana.Arch(binary.BigEndian.Uint16(b)), true
+}
+
+// ServeIPXEBootloader sets the built-in iPXE bootloader for the client
+// architecture of req in resp. Returns false if there is none.
 func ServeIPXEBootloader(l *logrus.Entry, req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool) {
+	return DefaultBootloaders.Serve(l, req, resp)
+}
+
+// Serve sets the bootloader from t for the client sending req in resp, to be
+// fetched over TFTP unless it is a URL. Returns false if none matches.
+func (t Bootloaders) Serve(l *logrus.Entry, req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool) {
 	if l == nil {
 		l = logrus.NewEntry(logrus.New())
 	}
-	if req.Options.Has(dhcpv4.OptionClientSystemArchitectureType) {
-		var carch iana.Arch
-		carchBytes := req.Options.Get(dhcpv4.OptionClientSystemArchitectureType)
-		l.WithField("mac", req.ClientHWAddr).Debugf("client architecture is %v (%q)", carchBytes, string(carchBytes))
-		carch = iana.Arch(binary.BigEndian.Uint16(carchBytes))
-		switch carch {
-		case iana.INTEL_X86PC:
-			// iPXE legacy 32-bit x86 bootloader
-			resp.Options.Update(dhcpv4.OptBootFileName("undionly.kpxe"))
-			return resp, true
-		case iana.EFI_IA32:
-			// iPXE EFI 32-bit bootloader
-			resp.Options.Update(dhcpv4.OptBootFileName("ipxe-i386.efi"))
-			return resp, true
-		case iana.EFI_X86_64:
-			// iPXE 64-bit x86 bootloader
-			resp.Options.Update(dhcpv4.OptBootFileName("ipxe-x86_64.efi"))
-			return resp, true
-		case iana.EFI_ARM32:
-			// iPXE EFI 32-bit ARM bootloader
-			resp.Options.Update(dhcpv4.OptBootFileName("ipxe-arm32.efi"))
-			return resp, true
-		case iana.EFI_ARM64:
-			// iPXE EFI 64-bit ARM bootloader
-			resp.Options.Update(dhcpv4.OptBootFileName("ipxe-arm64.efi"))
-			return resp, true
-		default:
-			l.WithFields(logrus.Fields{
-				"mac":     req.ClientHWAddr,
-				"arch_id": carch,
-				"arch":    carch.String(),
-			}).Info("no iPXE bootloader available for unknown architecture)")
-			return resp, false
-		}
-	} else {
+	carch, ok := ClientArch(req)
+	if !ok {
 		l.WithField("mac", req.ClientHWAddr).Infof("client did not present an architecture, unable to provide correct iPXE bootloader")
 		return resp, false
 	}
+	l.WithField("mac", req.ClientHWAddr).Debugf("client architecture is %d (%s)", uint16(carch), carch)
+	file, ok := t.Lookup4(req, carch)
+	if !ok {
+		l.WithFields(logrus.Fields{
+			"mac":     req.ClientHWAddr,
+			"arch_id": carch,
+			"arch":    carch.String(),
+		}).Info("no iPXE bootloader available for unknown architecture")
+		return resp, false
+	}
+	resp.Options.Update(dhcpv4.OptBootFileName(file))
+	return resp, true
 }
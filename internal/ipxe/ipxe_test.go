@@ -53,3 +53,30 @@ func TestServeIPXEBootloader_Table(t *testing.T) {
 		})
 	}
 }
+
+func TestParseArch(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    iana.Arch
+		wantErr bool
+	}{
+		{"bios", iana.INTEL_X86PC, false},
+		{"X86_64", iana.EFI_X86_64, false},
+		{"arm64", iana.EFI_ARM64, false},
+		{"16", iana.EFI_X86_64_HTTP, false},
+		{"0x13", iana.EFI_ARM64_HTTP, false},
+		{"sparc", 0, true},
+		{"65536", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseArch(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v wantErr=%v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
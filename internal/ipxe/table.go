@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package ipxe
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/parse"
+)
+
+// Bootloader maps DHCP clients of an architecture, optionally presenting a
+// given user class (DHCPv4 option 77, DHCPv6 option 15) or vendor class
+// (DHCPv4 option 60, DHCPv6 option 16), to the bootloader file served to them.
+type Bootloader struct {
+	Arch        iana.Arch // client system architecture
+	UserClass   string    // user class to match exactly, any if empty
+	VendorClass string    // prefix of the vendor class to match, any if empty
+	File        string    // bootloader file name or URL
+}
+
+func (b Bootloader) String() string {
+	s := "arch:" + ArchName(b.Arch)
+	if b.UserClass != "" {
+		s += ",user_class:" + b.UserClass
+	}
+	if b.VendorClass != "" {
+		s += ",vendor_class:" + b.VendorClass
+	}
+	return s + ",file:" + b.File
+}
+
+// matches returns true if b applies to a client of architecture arch
+// presenting the user class userClass and the vendor class vendorClass.
+func (b Bootloader) matches(arch iana.Arch, userClass, vendorClass []byte) bool {
+	return b.Arch == arch &&
+		(b.UserClass == "" || string(userClass) == b.UserClass) &&
+		(b.VendorClass == "" || bytes.HasPrefix(vendorClass, []byte(b.VendorClass)))
+}
+
+// ParseBootloader parses a bootloader table entry given as comma-separated
+// key:value pairs, e.g. "arch:riscv64,file:ipxe-riscv64.efi". The keys are
+// arch and file (required), and user_class and vendor_class (optional).
+func ParseBootloader(s string) (Bootloader, error) {
+	var b Bootloader
+	parts, err := parse.SplitCSV(s)
+	if err != nil {
+		return b, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(p, ":")
+		if !ok {
+			return b, fmt.Errorf("invalid format %q, should be 'key:val'", p)
+		}
+		key = strings.TrimSpace(key)
+		if seen[key] {
+			return b, fmt.Errorf("duplicate key %q", key)
+		}
+		seen[key] = true
+		if val, err = parse.Unquote(strings.TrimSpace(val)); err != nil {
+			return b, fmt.Errorf("%s: %w", key, err)
+		}
+		switch key {
+		case "arch":
+			if b.Arch, err = ParseArch(val); err != nil {
+				return b, err
+			}
+		case "user_class":
+			b.UserClass = val
+		case "vendor_class":
+			b.VendorClass = val
+		case "file":
+			b.File = val
+		default:
+			return b, fmt.Errorf("unknown key %q, expected 'arch', 'user_class', 'vendor_class', or 'file'", key)
+		}
+	}
+	if !seen["arch"] {
+		return b, fmt.Errorf("arch is required")
+	}
+	if b.File == "" {
+		return b, fmt.Errorf("file is required")
+	}
+	return b, nil
+}
+
+// Bootloaders is a table of bootloaders. The first matching entry is used.
+type Bootloaders []Bootloader
+
+// DefaultBootloaders are the built-in iPXE bootloaders.
+var DefaultBootloaders = Bootloaders{
+	{Arch: iana.INTEL_X86PC, File: "undionly.kpxe"},  // iPXE legacy 32-bit x86 bootloader
+	{Arch: iana.EFI_IA32, File: "ipxe-i386.efi"},     // iPXE EFI 32-bit bootloader
+	{Arch: iana.EFI_X86_64, File: "ipxe-x86_64.efi"}, // iPXE 64-bit x86 bootloader
+	{Arch: iana.EFI_ARM32, File: "ipxe-arm32.efi"},   // iPXE EFI 32-bit ARM bootloader
+	{Arch: iana.EFI_ARM64, File: "ipxe-arm64.efi"},   // iPXE EFI 64-bit ARM bootloader
+
+	// The same bootloaders for UEFI HTTP Boot clients
+	{Arch: iana.EFI_X86_HTTP, VendorClass: HTTPClientClass, File: "ipxe-i386.efi"},
+	{Arch: iana.EFI_X86_64_HTTP, VendorClass: HTTPClientClass, File: "ipxe-x86_64.efi"},
+	{Arch: iana.EFI_ARM32_HTTP, VendorClass: HTTPClientClass, File: "ipxe-arm32.efi"},
+	{Arch: iana.EFI_ARM64_HTTP, VendorClass: HTTPClientClass, File: "ipxe-arm64.efi"},
+}
+
+// WithDefaults returns t followed by DefaultBootloaders, so that the entries
+// of t take precedence.
+func (t Bootloaders) WithDefaults() Bootloaders {
+	return append(slices.Clone(t), DefaultBootloaders...)
+}
+
+// Lookup returns the file of the first entry of t matching a client of
+// architecture arch presenting userClass and vendorClass, and false if none
+// matches.
+func (t Bootloaders) Lookup(arch iana.Arch, userClass, vendorClass []byte) (string, bool) {
+	for _, b := range t {
+		if b.matches(arch, userClass, vendorClass) {
+			return b.File, true
+		}
+	}
+	return "", false
+}
+
+// Lookup4 looks up the bootloader for the DHCPv4 client of architecture arch
+// sending req.
+func (t Bootloaders) Lookup4(req *dhcpv4.DHCPv4, arch iana.Arch) (string, bool) {
+	return t.Lookup(arch,
+		req.Options.Get(dhcpv4.OptionUserClassInformation),
+		req.Options.Get(dhcpv4.OptionClassIdentifier))
+}
+
+// Lookup6 looks up the bootloader for the DHCPv6 client of architecture arch
+// sending req. The first user class and vendor class data are matched.
+func (t Bootloaders) Lookup6(req *dhcpv6.Message, arch iana.Arch) (string, bool) {
+	var userClass, vendorClass []byte
+	if ucs := req.Options.UserClasses(); len(ucs) > 0 {
+		userClass = ucs[0]
+	}
+	for _, vc := range req.Options.VendorClasses() {
+		if len(vc.Data) > 0 {
+			vendorClass = vc.Data[0]
+			break
+		}
+	}
+	return t.Lookup(arch, userClass, vendorClass)
+}
@@ -0,0 +1,217 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/ipxe"
+	"github.com/openchami/coresmd/internal/parse"
+)
+
+// Boot holds the network boot settings of a rule. Unset fields leave the
+// defaults of the plugin (or of earlier matching rules) in place.
+type Boot struct {
+	Bootloaders map[iana.Arch]string // bootloader file or URL per client architecture
+	Bootloader  string               // bootloader file or URL for any other architecture
+	NextServer  net.IP               // next server (siaddr and option 66)
+	ScriptURL   *url.URL             // static boot script URL for iPXE
+	BSSURL      *url.URL             // base URI of the BSS serving boot scripts for iPXE
+	Netboot     *bool                // whether network boot is enabled, unchanged if nil
+}
+
+// IsZero returns true if b sets nothing.
+func (b Boot) IsZero() bool {
+	return len(b.Bootloaders) == 0 && b.Bootloader == "" && b.NextServer == nil &&
+		b.ScriptURL == nil && b.BSSURL == nil && b.Netboot == nil
+}
+
+// NetbootEnabled returns true unless network boot was disabled.
+func (b Boot) NetbootEnabled() bool {
+	return b.Netboot == nil || *b.Netboot
+}
+
+// BootloaderFor returns the bootloader set for the client architecture arch,
+// falling back to the bootloader for any architecture. ok is false if the
+// client presented no architecture. Returns an empty string if none is set.
+func (b Boot) BootloaderFor(arch iana.Arch, ok bool) string {
+	if ok {
+		if file, found := b.Bootloaders[arch]; found {
+			return file
+		}
+	}
+	return b.Bootloader
+}
+
+// Script returns the URL of the boot script for the interface with MAC address
+// mac: ScriptURL if set, otherwise the bootscript endpoint of BSSURL, or of
+// base if BSSURL is unset.
+func (b Boot) Script(base *url.URL, mac string) string {
+	if b.ScriptURL != nil {
+		return b.ScriptURL.String()
+	}
+	if b.BSSURL != nil {
+		base = b.BSSURL
+	}
+	bssURL := base.JoinPath("/boot/v1/bootscript")
+	bssURL.RawQuery = fmt.Sprintf("mac=%s", mac)
+	return bssURL.String()
+}
+
+// merge overrides the settings of b with those set in o. ScriptURL and BSSURL
+// replace each other.
+func (b *Boot) merge(o Boot) {
+	for arch, file := range o.Bootloaders {
+		if b.Bootloaders == nil {
+			b.Bootloaders = make(map[iana.Arch]string)
+		}
+		b.Bootloaders[arch] = file
+	}
+	if o.Bootloader != "" {
+		b.Bootloader = o.Bootloader
+	}
+	if o.NextServer != nil {
+		b.NextServer = o.NextServer
+	}
+	if o.ScriptURL != nil {
+		b.ScriptURL, b.BSSURL = o.ScriptURL, nil
+	}
+	if o.BSSURL != nil {
+		b.ScriptURL, b.BSSURL = nil, o.BSSURL
+	}
+	if o.Netboot != nil {
+		b.Netboot = o.Netboot
+	}
+}
+
+func (b Boot) String() string {
+	var bootStr string
+
+	if len(b.Bootloaders) > 0 || b.Bootloader != "" {
+		archs := make([]iana.Arch, 0, len(b.Bootloaders))
+		for arch := range b.Bootloaders {
+			archs = append(archs, arch)
+		}
+		slices.Sort(archs)
+		parts := make([]string, 0, len(archs)+1)
+		for _, arch := range archs {
+			parts = append(parts, fmt.Sprintf("%s=%s", ipxe.ArchName(arch), b.Bootloaders[arch]))
+		}
+		if b.Bootloader != "" {
+			parts = append(parts, b.Bootloader)
+		}
+		bootStr += ",bootloader:" + strings.Join(parts, "|")
+	}
+	if b.NextServer != nil {
+		bootStr += ",next_server:" + b.NextServer.String()
+	}
+	if b.ScriptURL != nil {
+		bootStr += ",boot_script:" + b.ScriptURL.String()
+	}
+	if b.BSSURL != nil {
+		bootStr += ",bss_uri:" + b.BSSURL.String()
+	}
+	if b.Netboot != nil {
+		bootStr += fmt.Sprintf(",netboot:%v", *b.Netboot)
+	}
+
+	return strings.TrimLeft(bootStr, ",")
+}
+
+// parseBoot parses the boot action keys in comps.
+func parseBoot(comps map[string]string) (Boot, error) {
+	var b Boot
+
+	if v, ok := comps["bootloader"]; ok {
+		for _, e := range strings.Split(v, "|") {
+			e = strings.TrimSpace(e)
+			// An entry is ARCH=FILE unless the part before '=' can only be
+			// part of a path or URL.
+			archStr, file, found := strings.Cut(e, "=")
+			if found && !strings.ContainsAny(archStr, "/:.") {
+				arch, err := ipxe.ParseArch(archStr)
+				if err != nil || strings.TrimSpace(file) == "" {
+					return Boot{}, NewErrInvalidValue("bootloader", e, "ARCH=FILE with ARCH an architecture name (e.g. x86_64) or number")
+				}
+				if b.Bootloaders == nil {
+					b.Bootloaders = make(map[iana.Arch]string)
+				}
+				b.Bootloaders[arch] = strings.TrimSpace(file)
+				continue
+			}
+			if e == "" || b.Bootloader != "" {
+				return Boot{}, NewErrInvalidValue("bootloader", v, "bootloader files as ARCH=FILE separated by '|', and at most one FILE for other architectures")
+			}
+			b.Bootloader = e
+		}
+	}
+
+	if v, ok := comps["next_server"]; ok {
+		ip := net.ParseIP(strings.TrimSpace(v)).To4()
+		if ip == nil {
+			return Boot{}, NewErrInvalidValue("next_server", v, "valid IPv4 address")
+		}
+		b.NextServer = ip
+	}
+
+	scriptStr, scriptFound := comps["boot_script"]
+	bssStr, bssFound := comps["bss_uri"]
+	if scriptFound && bssFound {
+		return Boot{}, NewErrMutualExclusion("boot_script", "bss_uri")
+	}
+	if scriptFound {
+		u, err := url.Parse(strings.TrimSpace(scriptStr))
+		if err != nil || !u.IsAbs() {
+			return Boot{}, NewErrInvalidValue("boot_script", scriptStr, "absolute URL (e.g. http://172.16.0.254/boot.ipxe)")
+		}
+		b.ScriptURL = u
+	}
+	if bssFound {
+		u, err := url.Parse(strings.TrimSpace(bssStr))
+		if err != nil || !u.IsAbs() {
+			return Boot{}, NewErrInvalidValue("bss_uri", bssStr, "absolute URL (e.g. http://172.16.0.254:8081)")
+		}
+		b.BSSURL = u
+	}
+
+	if v, ok := comps["netboot"]; ok {
+		netboot, err := parse.ParseBoolLoose(v)
+		if err != nil {
+			return Boot{}, NewErrInvalidValue("netboot", v, "boolean")
+		}
+		b.Netboot = &netboot
+	}
+
+	return b, nil
+}
+
+// EvaluateBoot returns the boot settings for the interface described by ii,
+// merged from the actions of the rules matching it in the same way as
+// Evaluate4 and Evaluate6: rules are evaluated in order until one matches
+// without continue, and later matches override settings of earlier ones.
+//
+// The caller is expected to only call EvaluateBoot if Evaluate4 or Evaluate6
+// returned true, i.e. the request was not ignored.
+func EvaluateBoot(ii iface.IfaceInfo, rules []Rule) Boot {
+	var b Boot
+	for _, rule := range rules {
+		matches, cont := rule.MatchIface(ii)
+		if !matches {
+			continue
+		}
+		b.merge(rule.Action.Boot)
+		if !cont {
+			break
+		}
+	}
+	return b
+}
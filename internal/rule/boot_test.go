@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/iface"
+)
+
+func TestParseRule_Boot(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string // canonical action string
+		wantErr bool
+	}{
+		{name: "bootloader_per_arch", in: "bootloader:arm64=arm.efi|X86_64=x86.efi|17=http://172.16.0.254/ipxe.efi", want: "continue:false,ignore:false,bootloader:x86_64=x86.efi|arm64=arm.efi|17=http://172.16.0.254/ipxe.efi"},
+		{name: "bootloader_any_arch", in: "bootloader:bios=undionly.kpxe|http://172.16.0.254/ipxe.efi?a=b", want: "continue:false,ignore:false,bootloader:bios=undionly.kpxe|http://172.16.0.254/ipxe.efi?a=b"},
+		{name: "next_server", in: "next_server:172.16.0.254", want: "continue:false,ignore:false,next_server:172.16.0.254"},
+		{name: "boot_script", in: "boot_script:http://172.16.0.254/boot.ipxe", want: "continue:false,ignore:false,boot_script:http://172.16.0.254/boot.ipxe"},
+		{name: "bss_uri", in: "bss_uri:http://172.16.0.254:8081", want: "continue:false,ignore:false,bss_uri:http://172.16.0.254:8081"},
+		{name: "netboot", in: "group:maint,netboot:false", want: "continue:false,ignore:false,netboot:false"},
+		{name: "bootloader_bad_arch", in: "bootloader:sparc=boot.efi", wantErr: true},
+		{name: "bootloader_two_defaults", in: "bootloader:a.efi|b.efi", wantErr: true},
+		{name: "next_server_ipv6", in: "next_server:2001:db8::1", wantErr: true},
+		{name: "boot_script_relative", in: "boot_script:boot.ipxe", wantErr: true},
+		{name: "boot_script_and_bss_uri", in: "boot_script:http://a/boot.ipxe,bss_uri:http://b", wantErr: true},
+		{name: "netboot_bad_bool", in: "netboot:maybe", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := r.Action.String(); got != tt.want {
+				t.Fatalf("expected %q got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateBoot(t *testing.T) {
+	mustParse := func(s string) Rule {
+		r, err := ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		return r
+	}
+	rules := []Rule{
+		mustParse("type:Node,bootloader:x86_64=custom.efi,next_server:172.16.0.254,continue:true"),
+		mustParse("role:Compute,bss_uri:http://bss.example.test:8081,continue:true"),
+		mustParse("group:maint,netboot:false"),
+		mustParse("type:Node,boot_script:http://172.16.0.1/boot.ipxe"),
+	}
+	base, _ := url.Parse("http://ipxe.example.test")
+	mac := "de:ca:fc:0f:fe:e1"
+
+	t.Run("merged", func(t *testing.T) {
+		b := EvaluateBoot(iface.IfaceInfo{Type: "Node", Role: "Compute"}, rules)
+		if !b.NetbootEnabled() {
+			t.Fatalf("expected netboot enabled")
+		}
+		if got := b.BootloaderFor(iana.EFI_X86_64, true); got != "custom.efi" {
+			t.Fatalf("bootloader for x86_64=%q want custom.efi", got)
+		}
+		if got := b.BootloaderFor(iana.EFI_ARM64, true); got != "" {
+			t.Fatalf("bootloader for arm64=%q want empty", got)
+		}
+		if got := b.NextServer.String(); got != "172.16.0.254" {
+			t.Fatalf("next server=%s", got)
+		}
+		// boot_script of the last matching rule replaces bss_uri
+		if got := b.Script(base, mac); got != "http://172.16.0.1/boot.ipxe" {
+			t.Fatalf("script=%q", got)
+		}
+	})
+
+	t.Run("bss_uri", func(t *testing.T) {
+		b := EvaluateBoot(iface.IfaceInfo{Type: "NodeBMC", Role: "Compute"}, rules)
+		if got, want := b.Script(base, mac), "http://bss.example.test:8081/boot/v1/bootscript?mac="+mac; got != want {
+			t.Fatalf("script=%q want %q", got, want)
+		}
+	})
+
+	t.Run("netboot_disabled", func(t *testing.T) {
+		b := EvaluateBoot(iface.IfaceInfo{Type: "Node", Groups: []string{"maint"}}, rules)
+		if b.NetbootEnabled() {
+			t.Fatalf("expected netboot disabled")
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		b := EvaluateBoot(iface.IfaceInfo{Type: "HSNSwitch"}, rules)
+		if !b.IsZero() {
+			t.Fatalf("expected no boot settings, got %s", b)
+		}
+		if got, want := b.Script(base, mac), "http://ipxe.example.test/boot/v1/bootscript?mac="+mac; got != want {
+			t.Fatalf("script=%q want %q", got, want)
+		}
+	})
+}
@@ -70,3 +70,31 @@ func lookupHostname(pattern, domain string, ii iface.IfaceInfo, rule Rule) (hnam
 	}
 	return strings.Join(labels, ".")
 }
+
+// Hostname evaluates rules against ii in the same order and with the same
+// continue and ignore semantics as Evaluate4 and Evaluate6, and returns the
+// hostname set by the last matching rule with a hostname action, both without
+// (host) and with (fqdn) its domain. If no matching rule sets a hostname, host
+// and fqdn are empty; the default pattern is not applied. ok is false if a
+// matching rule ignores ii.
+//
+// This allows hostnames handed out over DHCP to be published in DNS.
+func Hostname(ii iface.IfaceInfo, globalDomain string, rules []Rule) (host, fqdn string, ok bool) {
+	for _, rule := range rules {
+		matches, cont := rule.MatchIface(ii)
+		if !matches {
+			continue
+		}
+		if rule.Action.Ignore {
+			return "", "", false
+		}
+		if hn := strings.TrimSpace(rule.Action.Hostname); hn != "" {
+			host = hostname.ExpandHostnamePattern(hn, ii.CompNID, ii.CompID)
+			fqdn = lookupHostname(hn, globalDomain, ii, rule)
+		}
+		if !cont {
+			break
+		}
+	}
+	return host, fqdn, true
+}
@@ -85,3 +85,64 @@ func TestLookupHostname_DomainAppendModes(t *testing.T) {
 		})
 	}
 }
+
+func TestHostname(t *testing.T) {
+	node := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "Node", MAC: "aa", IPList: []net.IP{net.ParseIP("172.16.0.10")}}
+	bmc := iface.IfaceInfo{CompID: "x1000s0c0b0", Type: "NodeBMC", MAC: "bb", IPList: []net.IP{net.ParseIP("172.16.1.10")}}
+
+	mustParse := func(s string) Rule {
+		t.Helper()
+		r, err := ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		ii       iface.IfaceInfo
+		rules    []Rule
+		wantHost string
+		wantFQDN string
+		wantOK   bool
+	}{
+		{
+			name:     "first_match_wins",
+			ii:       node,
+			rules:    []Rule{mustParse("type:Node,hostname:nid{04d}"), mustParse("type:Node,hostname:other")},
+			wantHost: "nid0007",
+			wantFQDN: "nid0007.cluster.local",
+			wantOK:   true,
+		},
+		{
+			name:     "continue_lets_later_rule_override",
+			ii:       node,
+			rules:    []Rule{mustParse("type:Node,hostname:nid{04d},continue:true"), mustParse("subnet:172.16.0.0/24,hostname:{id},domain:hsn.local")},
+			wantHost: "x1000s0c0b0n0",
+			wantFQDN: "x1000s0c0b0n0.hsn.local",
+			wantOK:   true,
+		},
+		{
+			name:   "no_hostname_no_default",
+			ii:     bmc,
+			rules:  []Rule{mustParse("type:Node,hostname:nid{04d}")},
+			wantOK: true,
+		},
+		{
+			name:   "ignored",
+			ii:     bmc,
+			rules:  []Rule{mustParse("type:NodeBMC,ignore:true"), mustParse("type:NodeBMC,hostname:{id}")},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, fqdn, ok := Hostname(tt.ii, "cluster.local", tt.rules)
+			if host != tt.wantHost || fqdn != tt.wantFQDN || ok != tt.wantOK {
+				t.Fatalf("Hostname() = (%q, %q, %v), want (%q, %q, %v)", host, fqdn, ok, tt.wantHost, tt.wantFQDN, tt.wantOK)
+			}
+		})
+	}
+}
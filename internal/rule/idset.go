@@ -0,0 +1,371 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CompileIDSet creates an IDSetMatcher from a string expression representing
+// the set of IDs.
+//
+// An expression is a list of terms separated by '|' (or ',' outside of
+// brackets). Each term is an ID pattern that may contain:
+//
+//   - literal characters, matched case-insensitively (e.g. x3000c0s0b0n0)
+//   - numeric ranges/lists in brackets (e.g. [1-16], [0,2,4-7], [01-16])
+//   - '*', which matches any (possibly empty) sequence of characters
+//
+// A term prefixed with '!' is a negation: IDs it matches are excluded from the
+// set, regardless of term order. If an expression consists only of negated
+// terms, the set is all IDs minus the negated ones.
+//
+// Examples:
+//   - x3000c0s[1-16]b0n0             # nodes in slots 1 through 16
+//   - x3000c0s[1-4]b0n[0-1]|x3001*   # union of two patterns
+//   - x3000*|!x3000c0s5b0n0          # cabinet x3000 except one node
+func CompileIDSet(expr string) (IDSetMatcher, error) {
+	terms, err := splitIDSetTerms(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &idSet{
+		expr:     strings.TrimSpace(expr),
+		include:  make(map[string]bool),
+		exclude:  make(map[string]bool),
+		startAll: true,
+	}
+	for _, term := range terms {
+		negate := false
+		if strings.HasPrefix(term, "!") {
+			negate = true
+			term = strings.TrimSpace(term[1:])
+		}
+		if term == "" {
+			return nil, fmt.Errorf("empty term in ID set %q", expr)
+		}
+		if !negate {
+			s.startAll = false
+		}
+		p, err := compileIDPattern(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid term %q in ID set %q: %w", term, expr, err)
+		}
+
+		// Patterns consisting only of a literal are looked up in a map instead
+		// of being walked.
+		if lit, ok := p.literal(); ok {
+			if negate {
+				s.exclude[lit] = true
+			} else {
+				s.include[lit] = true
+			}
+			continue
+		}
+		if negate {
+			s.excludePatterns = append(s.excludePatterns, p)
+		} else {
+			s.includePatterns = append(s.includePatterns, p)
+		}
+	}
+
+	return s, nil
+}
+
+// idSet is the IDSetMatcher returned by CompileIDSet.
+type idSet struct {
+	expr            string
+	include         map[string]bool // literal IDs in the set
+	exclude         map[string]bool // literal IDs removed from the set
+	includePatterns []idPattern
+	excludePatterns []idPattern
+	startAll        bool // true if every term is negated (set starts as "all")
+}
+
+// Match returns true if id is a member of the set.
+func (s *idSet) Match(id string) bool {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if id == "" {
+		return false
+	}
+
+	// Exclusions take precedence over inclusions
+	if s.exclude[id] {
+		return false
+	}
+	for _, p := range s.excludePatterns {
+		if p.match(id) {
+			return false
+		}
+	}
+
+	if s.startAll {
+		return true
+	}
+	if s.include[id] {
+		return true
+	}
+	for _, p := range s.includePatterns {
+		if p.match(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *idSet) String() string {
+	return s.expr
+}
+
+// splitIDSetTerms splits an ID set expression into its terms on '|' and on ','
+// when not inside brackets.
+func splitIDSetTerms(expr string) ([]string, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("empty ID set")
+	}
+
+	var (
+		terms []string
+		cur   strings.Builder
+		depth int
+	)
+	for _, r := range expr {
+		switch r {
+		case '[':
+			if depth > 0 {
+				return nil, fmt.Errorf("nested '[' in ID set %q", expr)
+			}
+			depth++
+			cur.WriteRune(r)
+		case ']':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced ']' in ID set %q", expr)
+			}
+			depth--
+			cur.WriteRune(r)
+		case '|', ',':
+			if depth > 0 {
+				cur.WriteRune(r)
+				continue
+			}
+			terms = append(terms, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unterminated '[' in ID set %q", expr)
+	}
+	terms = append(terms, strings.TrimSpace(cur.String()))
+
+	return terms, nil
+}
+
+// idSegmentKind identifies what an idSegment matches.
+type idSegmentKind int
+
+const (
+	segLiteral idSegmentKind = iota // exact (lowercase) text
+	segRange                        // run of digits within numeric ranges
+	segStar                         // any sequence of characters
+)
+
+// numRange is an inclusive range of integers. If width is nonzero, the number
+// must be written with exactly that many digits (zero-padded).
+type numRange struct {
+	lo, hi uint64
+	width  int
+}
+
+// idSegment is a single compiled piece of an ID pattern.
+type idSegment struct {
+	kind   idSegmentKind
+	lit    string
+	ranges []numRange
+}
+
+// idPattern is a compiled ID set term.
+type idPattern []idSegment
+
+// compileIDPattern compiles a single (non-negated) term into an idPattern.
+func compileIDPattern(term string) (idPattern, error) {
+	var (
+		p   idPattern
+		lit strings.Builder
+	)
+	flushLiteral := func() {
+		if lit.Len() > 0 {
+			p = append(p, idSegment{kind: segLiteral, lit: strings.ToLower(lit.String())})
+			lit.Reset()
+		}
+	}
+
+	for i := 0; i < len(term); i++ {
+		switch c := term[i]; c {
+		case '*':
+			flushLiteral()
+			// Consecutive stars are equivalent to one
+			if len(p) == 0 || p[len(p)-1].kind != segStar {
+				p = append(p, idSegment{kind: segStar})
+			}
+		case '[':
+			flushLiteral()
+			end := strings.IndexByte(term[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '['")
+			}
+			ranges, err := parseNumRanges(term[i+1 : i+end])
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, idSegment{kind: segRange, ranges: ranges})
+			i += end
+		case ']':
+			return nil, fmt.Errorf("unbalanced ']'")
+		case '!':
+			return nil, fmt.Errorf("'!' is only allowed at the start of a term")
+		default:
+			if c == ' ' || c == '\t' {
+				return nil, fmt.Errorf("whitespace is not allowed within a term")
+			}
+			lit.WriteByte(c)
+		}
+	}
+	flushLiteral()
+
+	return p, nil
+}
+
+// parseNumRanges parses the contents of a bracket expression, e.g. "1-4,7",
+// into a list of numeric ranges.
+func parseNumRanges(s string) ([]numRange, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("empty range '[]'")
+	}
+
+	var ranges []numRange
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '|' }) {
+		part = strings.TrimSpace(part)
+		loStr, hiStr, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hiStr = loStr
+		}
+		loStr = strings.TrimSpace(loStr)
+		hiStr = strings.TrimSpace(hiStr)
+		lo, err := parseRangeBound(loStr)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := parseRangeBound(hiStr)
+		if err != nil {
+			return nil, err
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("range start %d is greater than range end %d", lo, hi)
+		}
+
+		// Zero-padded bounds (e.g. [01-16]) require a fixed width
+		var width int
+		if (len(loStr) > 1 && loStr[0] == '0') || (len(hiStr) > 1 && hiStr[0] == '0') {
+			if isRange && len(loStr) != len(hiStr) {
+				return nil, fmt.Errorf("zero-padded range bounds %q and %q must be the same width", loStr, hiStr)
+			}
+			width = len(loStr)
+		}
+
+		ranges = append(ranges, numRange{lo: lo, hi: hi, width: width})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("empty range %q", s)
+	}
+
+	return ranges, nil
+}
+
+func parseRangeBound(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing range bound")
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("range bound %q is not a non-negative integer", s)
+	}
+	return n, nil
+}
+
+// literal returns the pattern's text and true if the pattern consists of a
+// single literal segment.
+func (p idPattern) literal() (string, bool) {
+	if len(p) == 1 && p[0].kind == segLiteral {
+		return p[0].lit, true
+	}
+	return "", false
+}
+
+// match reports whether the (already lowercased) id matches the pattern in its
+// entirety.
+func (p idPattern) match(id string) bool {
+	if len(p) == 0 {
+		return id == ""
+	}
+
+	seg := p[0]
+	switch seg.kind {
+	case segLiteral:
+		if !strings.HasPrefix(id, seg.lit) {
+			return false
+		}
+		return p[1:].match(id[len(seg.lit):])
+	case segRange:
+		// Try each leading run of digits, shortest first, so that a range
+		// followed by more digits (e.g. "[1-3]000") can still match.
+		for n := 1; n <= len(id) && id[n-1] >= '0' && id[n-1] <= '9'; n++ {
+			if seg.containsDigits(id[:n]) && p[1:].match(id[n:]) {
+				return true
+			}
+		}
+		return false
+	case segStar:
+		if len(p) == 1 {
+			return true
+		}
+		for i := 0; i <= len(id); i++ {
+			if p[1:].match(id[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
+
+// containsDigits reports whether the digit string ds falls within any of the
+// segment's numeric ranges.
+func (seg idSegment) containsDigits(ds string) bool {
+	n, err := strconv.ParseUint(ds, 10, 64)
+	if err != nil {
+		return false
+	}
+	for _, r := range seg.ranges {
+		if r.width > 0 {
+			if len(ds) != r.width {
+				continue
+			}
+		} else if len(ds) > 1 && ds[0] == '0' {
+			// Unpadded ranges only match canonical numbers (no leading zeros)
+			continue
+		}
+		if n >= r.lo && n <= r.hi {
+			return true
+		}
+	}
+	return false
+}
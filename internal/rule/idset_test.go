@@ -0,0 +1,180 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"net"
+	"testing"
+
+	"github.com/openchami/coresmd/internal/iface"
+)
+
+func TestCompileIDSet_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", "  "},
+		{"empty_term", "x1000c0s0b0n0||x1000c0s1b0n0"},
+		{"negation_only_bang", "!"},
+		{"unterminated_bracket", "x1000c0s[1-4b0n0"},
+		{"unbalanced_bracket", "x1000c0s1-4]b0n0"},
+		{"nested_bracket", "x1000c0s[[1-4]]b0n0"},
+		{"empty_brackets", "x1000c0s[]b0n0"},
+		{"reversed_range", "x1000c0s[4-1]b0n0"},
+		{"non_numeric_range", "x1000c0s[a-c]b0n0"},
+		{"missing_bound", "x1000c0s[1-]b0n0"},
+		{"padding_mismatch", "x1000c0s[01-100]b0n0"},
+		{"bang_mid_term", "x1000c0s!1b0n0"},
+		{"whitespace_in_term", "x1000c0s1 b0n0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m, err := CompileIDSet(tt.expr); err == nil {
+				t.Fatalf("CompileIDSet(%q): expected error got matcher=%v", tt.expr, m)
+			}
+		})
+	}
+}
+
+func TestCompileIDSet_Match(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		match []string
+		miss  []string
+	}{
+		{
+			name:  "literal",
+			expr:  "x3000c0s0b0n0",
+			match: []string{"x3000c0s0b0n0", "X3000C0S0B0N0"},
+			miss:  []string{"x3000c0s0b0n1", "x3000c0s0b0", ""},
+		},
+		{
+			name:  "single_range",
+			expr:  "x3000c0s[1-16]b0n0",
+			match: []string{"x3000c0s1b0n0", "x3000c0s9b0n0", "x3000c0s16b0n0"},
+			miss:  []string{"x3000c0s0b0n0", "x3000c0s17b0n0", "x3000c0s01b0n0", "x3000c0s1b0n1"},
+		},
+		{
+			name:  "multiple_ranges",
+			expr:  "x1000s[0-3]c0b0n[0-7]",
+			match: []string{"x1000s0c0b0n0", "x1000s3c0b0n7"},
+			miss:  []string{"x1000s4c0b0n0", "x1000s0c0b0n8"},
+		},
+		{
+			name:  "range_list",
+			expr:  "x3000c0s[1,3,5-6]b0n0",
+			match: []string{"x3000c0s1b0n0", "x3000c0s3b0n0", "x3000c0s5b0n0", "x3000c0s6b0n0"},
+			miss:  []string{"x3000c0s2b0n0", "x3000c0s4b0n0", "x3000c0s7b0n0"},
+		},
+		{
+			name:  "padded_range",
+			expr:  "node[01-12]",
+			match: []string{"node01", "node12"},
+			miss:  []string{"node1", "node13", "node001"},
+		},
+		{
+			name:  "range_followed_by_digits",
+			expr:  "x[1-3]000c0",
+			match: []string{"x1000c0", "x3000c0"},
+			miss:  []string{"x4000c0", "x30000c0"},
+		},
+		{
+			name:  "pipe_union",
+			expr:  "x3000c0s[1-2]b0n0|x3001c0s1b0n0",
+			match: []string{"x3000c0s1b0n0", "x3000c0s2b0n0", "x3001c0s1b0n0"},
+			miss:  []string{"x3001c0s2b0n0"},
+		},
+		{
+			name:  "comma_union",
+			expr:  "x3000c0s[1-2]b0n0,x3001c0s1b0n0",
+			match: []string{"x3000c0s2b0n0", "x3001c0s1b0n0"},
+			miss:  []string{"x3001c0s2b0n0"},
+		},
+		{
+			name:  "wildcard_suffix",
+			expr:  "x3000*",
+			match: []string{"x3000c0s0b0n0", "x3000c0s0b1", "x3000"},
+			miss:  []string{"x3001c0s0b0n0"},
+		},
+		{
+			name:  "wildcard_segment",
+			expr:  "x3000c0s*b0n0",
+			match: []string{"x3000c0s0b0n0", "x3000c0s42b0n0"},
+			miss:  []string{"x3000c0s0b0n1", "x3000c0s0b1"},
+		},
+		{
+			name:  "negation_with_inclusion",
+			expr:  "x3000c0s[1-4]b0n0|!x3000c0s3b0n0",
+			match: []string{"x3000c0s1b0n0", "x3000c0s4b0n0"},
+			miss:  []string{"x3000c0s3b0n0", "x3000c0s5b0n0"},
+		},
+		{
+			name:  "negation_order_independent",
+			expr:  "!x3000c0s3b0n0|x3000c0s[1-4]b0n0",
+			match: []string{"x3000c0s1b0n0"},
+			miss:  []string{"x3000c0s3b0n0"},
+		},
+		{
+			name:  "negation_only",
+			expr:  "!x3000c0s[1-2]b*",
+			match: []string{"x3000c0s3b0n0", "x1000c0s0b0"},
+			miss:  []string{"x3000c0s1b0n0", "x3000c0s2b1"},
+		},
+		{
+			name:  "negated_pattern",
+			expr:  "x3000*|!x3000c0s*b1",
+			match: []string{"x3000c0s1b0n0"},
+			miss:  []string{"x3000c0s1b1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := CompileIDSet(tt.expr)
+			if err != nil {
+				t.Fatalf("CompileIDSet(%q): unexpected error: %v", tt.expr, err)
+			}
+			if m.String() != tt.expr {
+				t.Fatalf("String()=%q want %q", m.String(), tt.expr)
+			}
+			for _, id := range tt.match {
+				if !m.Match(id) {
+					t.Errorf("expected %q to match %q", tt.expr, id)
+				}
+			}
+			for _, id := range tt.miss {
+				if m.Match(id) {
+					t.Errorf("expected %q not to match %q", tt.expr, id)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRule_IDSetMatchIface(t *testing.T) {
+	r, err := ParseRule("type:Node,id_set:x3000c0s[1-16]b0n0,hostname:nid{04d}")
+	if err != nil {
+		t.Fatalf("ParseRule: unexpected error: %v", err)
+	}
+	in := iface.IfaceInfo{CompID: "x3000c0s7b0n0", CompNID: 7, Type: "Node", IPList: []net.IP{net.ParseIP("172.16.0.7")}}
+	out := iface.IfaceInfo{CompID: "x3000c0s17b0n0", CompNID: 17, Type: "Node", IPList: []net.IP{net.ParseIP("172.16.0.17")}}
+	if m, _ := r.MatchIface(in); !m {
+		t.Fatalf("expected rule to match %s", in.CompID)
+	}
+	if m, _ := r.MatchIface(out); m {
+		t.Fatalf("expected rule not to match %s", out.CompID)
+	}
+}
+
+func BenchmarkIDSetMatch(b *testing.B) {
+	m, err := CompileIDSet("x[1000-1009]c[0-7]s[0-31]b[0-1]n[0-3]|!x1005*")
+	if err != nil {
+		b.Fatalf("CompileIDSet: %v", err)
+	}
+	for i := 0; i < b.N; i++ {
+		m.Match("x1003c5s17b1n2")
+	}
+}
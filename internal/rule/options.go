@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// minMTU is the smallest MTU an IPv4 host must accept (RFC 791), which is also
+// the smallest value allowed for DHCPv4 option 26.
+const minMTU = 68
+
+// reservedOptions are the DHCPv4 options that raw options may not set: the pad
+// and end options, and options owned by the server, other plugins, or the
+// client, which would be overridden or corrupted.
+var reservedOptions = map[uint8]bool{
+	dhcpv4.OptionPad.Code():                   true, // 0
+	dhcpv4.OptionRequestedIPAddress.Code():    true, // 50
+	dhcpv4.OptionIPAddressLeaseTime.Code():    true, // 51
+	dhcpv4.OptionDHCPMessageType.Code():       true, // 53
+	dhcpv4.OptionServerIdentifier.Code():      true, // 54
+	dhcpv4.OptionParameterRequestList.Code():  true, // 55
+	dhcpv4.OptionClientIdentifier.Code():      true, // 61
+	dhcpv4.OptionRelayAgentInformation.Code(): true, // 82
+	dhcpv4.OptionEnd.Code():                   true, // 255
+}
+
+// RawOption is an arbitrary DHCPv4 option set by the "option" action key.
+type RawOption struct {
+	Code uint8
+	Data []byte
+}
+
+func (o RawOption) String() string {
+	return fmt.Sprintf("%d:hex:%x", o.Code, o.Data)
+}
+
+// Option returns o as a DHCPv4 option.
+func (o RawOption) Option() dhcpv4.Option {
+	return dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(o.Code), o.Data)
+}
+
+// parseIPv4List parses IPv4 addresses separated by '|' for key.
+func parseIPv4List(key, s string) ([]net.IP, error) {
+	var ips []net.IP
+	for _, v := range strings.Split(s, "|") {
+		ip := net.ParseIP(strings.TrimSpace(v)).To4()
+		if ip == nil {
+			return nil, NewErrInvalidValue(key, v, "valid IPv4 address")
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// parseMTU parses an interface MTU for DHCPv4 option 26.
+func parseMTU(s string) (uint16, error) {
+	mtu, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16)
+	if err != nil || mtu < minMTU {
+		return 0, NewErrInvalidValue("mtu", s, fmt.Sprintf("integer between %d and 65535", minMTU))
+	}
+	return uint16(mtu), nil
+}
+
+// parseSearch parses domain names separated by '|' for the domain search list
+// (DHCPv4 option 119).
+func parseSearch(s string) ([]string, error) {
+	var domains []string
+	for _, d := range strings.Split(s, "|") {
+		d = strings.TrimSuffix(strings.TrimSpace(d), ".")
+		if d == "" {
+			return nil, NewErrInvalidValue("search", s, "domain names separated by '|'")
+		}
+		for _, label := range strings.Split(d, ".") {
+			if len(label) == 0 || len(label) > 63 {
+				return nil, NewErrInvalidValue("search", d, "valid domain name")
+			}
+		}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+// parseStaticRoutes parses classless static routes (DHCPv4 option 121)
+// separated by '|', each given as CIDR@ROUTER.
+func parseStaticRoutes(s string) (dhcpv4.Routes, error) {
+	var routes dhcpv4.Routes
+	for _, r := range strings.Split(s, "|") {
+		dest, router, ok := strings.Cut(strings.TrimSpace(r), "@")
+		if !ok {
+			return nil, NewErrInvalidValue("static_routes", r, "route as CIDR@ROUTER (e.g. 10.0.0.0/8@172.16.0.1)")
+		}
+		_, ipnet, err := net.ParseCIDR(strings.TrimSpace(dest))
+		if err != nil || ipnet.IP.To4() == nil {
+			return nil, NewErrInvalidValue("static_routes", r, "IPv4 destination network (e.g. 10.0.0.0/8)")
+		}
+		ip := net.ParseIP(strings.TrimSpace(router)).To4()
+		if ip == nil {
+			return nil, NewErrInvalidValue("static_routes", r, "IPv4 router address")
+		}
+		routes = append(routes, &dhcpv4.Route{Dest: ipnet, Router: ip})
+	}
+	return routes, nil
+}
+
+// parseRawOptions parses DHCPv4 options separated by '|', each given as
+// CODE:TYPE:VALUE. TYPE is one of:
+//
+//   - hex: bytes in hexadecimal, optionally prefixed by 0x and separated by ':'
+//     (e.g. 0a0b0c or 0a:0b:0c)
+//   - ip: IPv4 addresses separated by ',' (the value must then be quoted)
+//   - string: the value as is
+func parseRawOptions(s string) ([]RawOption, error) {
+	var opts []RawOption
+	for _, o := range strings.Split(s, "|") {
+		parts := strings.SplitN(strings.TrimSpace(o), ":", 3)
+		if len(parts) != 3 {
+			return nil, NewErrInvalidValue("option", o, "option as CODE:TYPE:VALUE (e.g. 43:hex:0a0b)")
+		}
+		code, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil || reservedOptions[uint8(code)] {
+			return nil, NewErrInvalidValue("option", o, "option code between 1 and 254, except 50, 51, 53, 54, 55, 61, and 82")
+		}
+
+		opt := RawOption{Code: uint8(code)}
+		switch typ, val := parts[1], parts[2]; typ {
+		case "hex":
+			val = strings.ReplaceAll(strings.TrimPrefix(val, "0x"), ":", "")
+			if opt.Data, err = hex.DecodeString(val); err != nil || len(opt.Data) == 0 {
+				return nil, NewErrInvalidValue("option", o, "hexadecimal bytes")
+			}
+		case "ip":
+			for _, v := range strings.Split(val, ",") {
+				ip := net.ParseIP(strings.TrimSpace(v)).To4()
+				if ip == nil {
+					return nil, NewErrInvalidValue("option", o, "IPv4 addresses separated by ','")
+				}
+				opt.Data = append(opt.Data, ip...)
+			}
+		case "string":
+			if val == "" {
+				return nil, NewErrInvalidValue("option", o, "non-empty string")
+			}
+			opt.Data = []byte(val)
+		default:
+			return nil, NewErrInvalidValue("option", o, "type 'hex', 'ip', or 'string'")
+		}
+		if len(opt.Data) > 255 {
+			return nil, NewErrInvalidValue("option", o, "value of at most 255 bytes")
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
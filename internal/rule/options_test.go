@@ -0,0 +1,109 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package rule
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+
+	"github.com/openchami/coresmd/internal/iface"
+)
+
+func TestParseRule_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string // canonical action string
+		wantErr bool
+	}{
+		{name: "dns", in: "dns:172.16.0.253|172.16.0.254", want: "continue:false,ignore:false,dns:172.16.0.253|172.16.0.254"},
+		{name: "ntp", in: "ntp:172.16.0.1", want: "continue:false,ignore:false,ntp:172.16.0.1"},
+		{name: "mtu", in: "mtu:9000", want: "continue:false,ignore:false,mtu:9000"},
+		{name: "search", in: "search:cluster.local.|hsn.cluster.local", want: "continue:false,ignore:false,search:cluster.local|hsn.cluster.local"},
+		{name: "static_routes", in: "static_routes:10.100.0.0/16@172.16.0.1|0.0.0.0/0@172.16.0.254", want: "continue:false,ignore:false,static_routes:10.100.0.0/16@172.16.0.1|0.0.0.0/0@172.16.0.254"},
+		{name: "option_hex", in: "option:150:hex:0xAC:10:00:01", want: "continue:false,ignore:false,option:150:hex:ac100001"},
+		{name: "option_ip_quoted", in: "option:'43:ip:172.16.0.1,172.16.0.2|60:string:PXEClient'", want: "continue:false,ignore:false,option:43:hex:ac100001ac100002|60:hex:505845436c69656e74"},
+		{name: "dns_ipv6", in: "dns:2001:db8::1", wantErr: true},
+		{name: "mtu_too_small", in: "mtu:67", wantErr: true},
+		{name: "mtu_too_large", in: "mtu:65536", wantErr: true},
+		{name: "search_empty_label", in: "search:cluster..local", wantErr: true},
+		{name: "static_routes_no_router", in: "static_routes:10.0.0.0/8", wantErr: true},
+		{name: "static_routes_ipv6", in: "static_routes:2001:db8::/32@172.16.0.1", wantErr: true},
+		{name: "option_missing_type", in: "option:43:0a0b", wantErr: true},
+		{name: "option_code_zero", in: "option:0:hex:00", wantErr: true},
+		{name: "option_code_message_type", in: "option:53:hex:05", wantErr: true},
+		{name: "option_code_end", in: "option:255:hex:00", wantErr: true},
+		{name: "option_code_requested_ip", in: "option:50:ip:172.16.0.1", wantErr: true},
+		{name: "option_code_lease_time", in: "option:51:hex:00000e10", wantErr: true},
+		{name: "option_code_server_id", in: "option:54:ip:172.16.0.1", wantErr: true},
+		{name: "option_code_parameter_list", in: "option:55:hex:0103", wantErr: true},
+		{name: "option_code_client_id", in: "option:61:hex:01decafc0ffee1", wantErr: true},
+		{name: "option_code_relay_agent", in: "option:82:hex:0104", wantErr: true},
+		{name: "option_code_too_large", in: "option:256:hex:00", wantErr: true},
+		{name: "option_bad_hex", in: "option:43:hex:xyz", wantErr: true},
+		{name: "option_bad_type", in: "option:43:uint8:1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRule(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := r.Action.String(); got != tt.want {
+				t.Fatalf("expected %q got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluate4_Options(t *testing.T) {
+	ii := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "Node", MAC: "aa", IPList: []net.IP{net.ParseIP("172.16.0.10")}}
+	mustParse := func(s string) Rule {
+		r, err := ParseRule(s)
+		if err != nil {
+			t.Fatalf("failed to parse rule %q: %v", s, err)
+		}
+		return r
+	}
+	rules := []Rule{
+		mustParse("subnet:172.16.0.0/24,dns:172.16.0.253,ntp:172.16.0.1|172.16.0.2,mtu:9000,search:cluster.local,static_routes:10.100.0.0/16@172.16.0.1,continue:true"),
+		mustParse("subnet:172.16.99.0/24,mtu:1500"),
+		mustParse("type:Node,option:'26:hex:05dc|150:ip:172.16.0.5'"),
+	}
+
+	resp, err := dhcpv4.New()
+	if err != nil {
+		t.Fatalf("unexpected error creating dhcpv4 message: %v", err)
+	}
+	if !Evaluate4(nil, ii, "", "none", resp, rules) {
+		t.Fatalf("expected response to be sent")
+	}
+
+	if got := resp.DNS(); len(got) != 1 || !got[0].Equal(net.ParseIP("172.16.0.253")) {
+		t.Errorf("DNS=%v", got)
+	}
+	if got := resp.NTPServers(); len(got) != 2 || !got[1].Equal(net.ParseIP("172.16.0.2")) {
+		t.Errorf("NTP=%v", got)
+	}
+	// The raw option set by the later rule overrides the MTU
+	if got := resp.Options.Get(dhcpv4.OptionInterfaceMTU); !bytes.Equal(got, []byte{0x05, 0xdc}) {
+		t.Errorf("MTU=%x, want 05dc", got)
+	}
+	if got := resp.DomainSearch(); got == nil || len(got.Labels) != 1 || got.Labels[0] != "cluster.local" {
+		t.Errorf("DomainSearch=%v", got)
+	}
+	if got := resp.ClasslessStaticRoute(); len(got) != 1 || got[0].Dest.String() != "10.100.0.0/16" || !got[0].Router.Equal(net.ParseIP("172.16.0.1")) {
+		t.Errorf("ClasslessStaticRoute=%v", got)
+	}
+	if got := resp.Options.Get(dhcpv4.GenericOptionCode(150)); !bytes.Equal(got, []byte{172, 16, 0, 5}) {
+		t.Errorf("option 150=%v", got)
+	}
+}
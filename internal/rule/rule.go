@@ -9,6 +9,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"net"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -25,19 +26,56 @@ import (
 const DefaultPattern = "unknown-{04d}"
 
 var AllowedKeys = []string{
+	"arch",
+	"boot_script",
+	"bootloader",
+	"bss_uri",
 	"cidr",
+	"class",
 	"continue",
+	"dns",
 	"domain",
 	"domain_append",
+	"enabled",
+	"flag",
+	"group",
 	"hostname",
 	"id",
 	"id_set",
 	"ignore",
 	"log",
+	"mtu",
 	"name",
+	"netboot",
 	"netmask",
+	"next_server",
+	"ntp",
+	"option",
+	"partition",
+	"role",
 	"routers",
+	"search",
+	"state",
+	"static_routes",
 	"subnet",
+	"subrole",
+	"type",
+}
+
+// MatchKeys are the rule keys that select components rather than act on them.
+var MatchKeys = []string{
+	"arch",
+	"class",
+	"enabled",
+	"flag",
+	"group",
+	"id",
+	"id_set",
+	"partition",
+	"role",
+	"state",
+	"subnet",
+	"subrole",
 	"type",
 }
 
@@ -87,6 +125,18 @@ func (r Rule) MatchIface(ii iface.IfaceInfo) (matches, cont bool) {
 	} else if r.Match.IDSet != nil {
 		matchSet["id_set"] = true
 	}
+	attrs := r.Match.attributes(ii)
+	for _, attr := range attrs {
+		if attr.set != nil {
+			matchSet[attr.key] = true
+		}
+	}
+	if r.Match.Groups != nil {
+		matchSet["group"] = true
+	}
+	if r.Match.Enabled != nil {
+		matchSet["enabled"] = true
+	}
 
 	matchCounter := 0
 
@@ -123,6 +173,24 @@ func (r Rule) MatchIface(ii iface.IfaceInfo) (matches, cont bool) {
 		}
 	}
 
+	// Match component state and inventory details
+	for _, attr := range attrs {
+		if attr.set != nil && attr.set[strings.ToLower(attr.value)] {
+			matchCounter++
+		}
+	}
+	if r.Match.Groups != nil {
+		for _, g := range ii.Groups {
+			if r.Match.Groups[strings.ToLower(g)] {
+				matchCounter++
+				break
+			}
+		}
+	}
+	if r.Match.Enabled != nil && *r.Match.Enabled == ii.Enabled {
+		matchCounter++
+	}
+
 	// Tally up the matches, returning true if everything matches
 	if matchCounter == len(matchSet) {
 		matches = true
@@ -137,6 +205,60 @@ type Match struct {
 	Subnets []*net.IPNet    // any subnet in slice matches, all subnets if empty
 	ID      string          // xname to match, any if empty
 	IDSet   IDSetMatcher    // set of xnames to match, any if nil
+
+	// Component state and inventory details. Values are matched
+	// case-insensitively and stored in lowercase.
+	Roles    map[string]bool // any role in map matches, all roles if nil
+	SubRoles map[string]bool // any subrole in map matches, all subroles if nil
+	States   map[string]bool // any state in map matches, all states if nil
+	Flags    map[string]bool // any flag in map matches, all flags if nil
+	Archs    map[string]bool // any architecture in map matches, all architectures if nil
+	Classes  map[string]bool // any class in map matches, all classes if nil
+	Enabled  *bool           // whether the component must be enabled, either if nil
+
+	// SMD group and partition membership, matched case-insensitively and
+	// stored in lowercase
+	Groups     map[string]bool // membership in any group in map matches, any membership if nil
+	Partitions map[string]bool // any partition in map matches, any partition if nil
+}
+
+// matchAttribute pairs a set of values to match with the rule key it was
+// given by and the corresponding value of an interface's component.
+type matchAttribute struct {
+	key   string
+	set   map[string]bool
+	value string
+}
+
+// attributes returns the component attributes matched by m, in rule key
+// order, along with their values for ii.
+func (m Match) attributes(ii iface.IfaceInfo) []matchAttribute {
+	return []matchAttribute{
+		{"arch", m.Archs, ii.Arch},
+		{"class", m.Classes, ii.Class},
+		{"flag", m.Flags, ii.Flag},
+		{"partition", m.Partitions, ii.Partition},
+		{"role", m.Roles, ii.Role},
+		{"state", m.States, ii.State},
+		{"subrole", m.SubRoles, ii.SubRole},
+	}
+}
+
+// Matches returns true if the interface meets all of the criteria of m. The
+// zero Match matches every interface.
+func (m Match) Matches(ii iface.IfaceInfo) bool {
+	if m.IsZero() {
+		return true
+	}
+	matches, _ := Rule{Match: m}.MatchIface(ii)
+	return matches
+}
+
+// IsZero returns true if m has no criteria.
+func (m Match) IsZero() bool {
+	return len(m.Types) == 0 && len(m.Subnets) == 0 && strings.TrimSpace(m.ID) == "" && m.IDSet == nil &&
+		m.Roles == nil && m.SubRoles == nil && m.States == nil && m.Flags == nil &&
+		m.Archs == nil && m.Classes == nil && m.Enabled == nil && m.Groups == nil && m.Partitions == nil
 }
 
 func (m Match) String() string {
@@ -174,6 +296,31 @@ func (m Match) String() string {
 		matchStr += fmt.Sprintf(",id_set:%s", m.IDSet)
 	}
 
+	for _, attr := range m.attributes(iface.IfaceInfo{}) {
+		if attr.set == nil {
+			continue
+		}
+		keys := make([]string, 0, len(attr.set))
+		for v := range attr.set {
+			keys = append(keys, v)
+		}
+		sort.Strings(keys)
+		matchStr += fmt.Sprintf(",%s:%s", attr.key, strings.Join(keys, "|"))
+	}
+
+	if m.Groups != nil {
+		keys := make([]string, 0, len(m.Groups))
+		for g := range m.Groups {
+			keys = append(keys, g)
+		}
+		sort.Strings(keys)
+		matchStr += ",group:" + strings.Join(keys, "|")
+	}
+
+	if m.Enabled != nil {
+		matchStr += fmt.Sprintf(",enabled:%v", *m.Enabled)
+	}
+
 	return strings.TrimLeft(matchStr, ",")
 }
 
@@ -186,6 +333,17 @@ type Action struct {
 	Routers      []net.IP   // router IPs for selected component(s)
 	Continue     bool       // whether to continue parsing subsequent rules if this matches
 	Ignore       bool       // if true, drop DHCP request without responding (takes precedence over all other actions)
+
+	// DHCPv4 options for selected component(s)
+	DNS          []net.IP      // DNS servers (option 6)
+	NTP          []net.IP      // NTP servers (option 42)
+	MTU          uint16        // interface MTU (option 26), unset if zero
+	Search       []string      // domain search list (option 119)
+	StaticRoutes dhcpv4.Routes // classless static routes (option 121)
+	Options      []RawOption   // arbitrary options, applied after all others
+
+	// Network boot settings for selected component(s)
+	Boot Boot
 }
 
 func (a Action) String() string {
@@ -220,9 +378,53 @@ func (a Action) String() string {
 		actionStr += fmt.Sprintf(",routers:%s", strings.Join(parts, "|"))
 	}
 
+	ipList := func(ips []net.IP) string {
+		parts := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			parts = append(parts, ip.String())
+		}
+		return strings.Join(parts, "|")
+	}
+	if len(a.DNS) > 0 {
+		actionStr += ",dns:" + ipList(a.DNS)
+	}
+	if len(a.NTP) > 0 {
+		actionStr += ",ntp:" + ipList(a.NTP)
+	}
+	if a.MTU != 0 {
+		actionStr += fmt.Sprintf(",mtu:%d", a.MTU)
+	}
+	if len(a.Search) > 0 {
+		actionStr += ",search:" + strings.Join(a.Search, "|")
+	}
+	if len(a.StaticRoutes) > 0 {
+		parts := make([]string, 0, len(a.StaticRoutes))
+		for _, r := range a.StaticRoutes {
+			parts = append(parts, fmt.Sprintf("%s@%s", r.Dest, r.Router))
+		}
+		actionStr += ",static_routes:" + strings.Join(parts, "|")
+	}
+	if len(a.Options) > 0 {
+		parts := make([]string, 0, len(a.Options))
+		for _, o := range a.Options {
+			parts = append(parts, o.String())
+		}
+		actionStr += ",option:" + strings.Join(parts, "|")
+	}
+	if bootStr := a.Boot.String(); bootStr != "" {
+		actionStr += "," + bootStr
+	}
+
 	return strings.TrimLeft(actionStr, ",")
 }
 
+// hasOptions returns true if a sets any of the DHCPv4 options that have their
+// own action keys or any raw options.
+func (a Action) hasOptions() bool {
+	return len(a.DNS) > 0 || len(a.NTP) > 0 || a.MTU != 0 || len(a.Search) > 0 ||
+		len(a.StaticRoutes) > 0 || len(a.Options) > 0
+}
+
 // IDSetMatcher provides a function for matching a component ID to a defined set
 // of IDs.
 type IDSetMatcher interface {
@@ -230,13 +432,6 @@ type IDSetMatcher interface {
 	String() string // for debugging purposes
 }
 
-// CompileIDSet creates an IDSetMatcher from a string expression representing
-// the set of IDs.
-func CompileIDSet(expr string) (IDSetMatcher, error) {
-	// TODO: implement
-	return nil, fmt.Errorf("CompileIDSet() is not implemented: %q", expr)
-}
-
 // ParseRule parses a string representing a hostname rule (everything to the
 // right of 'hostname_rule=') and returns a representative Rule. Within the
 // rule string, key/value delimiters are ':' (for example: type:Node).
@@ -291,6 +486,59 @@ func ParseRule(rule string) (Rule, error) {
 		}
 	}
 
+	// DHCPv4 options (actions)
+	//
+	// Examples:
+	//  - dns:172.16.0.253|172.16.0.254
+	//  - ntp:172.16.0.1
+	//  - mtu:9000
+	//  - search:cluster.local|hsn.cluster.local
+	//  - static_routes:10.100.0.0/16@172.16.0.1|0.0.0.0/0@172.16.0.254
+	//  - option:'43:ip:172.16.0.1,172.16.0.2|150:hex:ac100001'
+	if v, ok := comps["dns"]; ok {
+		if a.DNS, err = parseIPv4List("dns", v); err != nil {
+			return Rule{}, err
+		}
+	}
+	if v, ok := comps["ntp"]; ok {
+		if a.NTP, err = parseIPv4List("ntp", v); err != nil {
+			return Rule{}, err
+		}
+	}
+	if v, ok := comps["mtu"]; ok {
+		if a.MTU, err = parseMTU(v); err != nil {
+			return Rule{}, err
+		}
+	}
+	if v, ok := comps["search"]; ok {
+		if a.Search, err = parseSearch(v); err != nil {
+			return Rule{}, err
+		}
+	}
+	if v, ok := comps["static_routes"]; ok {
+		if a.StaticRoutes, err = parseStaticRoutes(v); err != nil {
+			return Rule{}, err
+		}
+	}
+	if v, ok := comps["option"]; ok {
+		if a.Options, err = parseRawOptions(v); err != nil {
+			return Rule{}, err
+		}
+	}
+
+	// network boot settings (actions)
+	//
+	// Examples:
+	//  - bootloader:x86_64=ipxe-custom.efi|arm64=ipxe-custom-arm64.efi
+	//  - bootloader:http://172.16.0.254/ipxe.efi
+	//  - next_server:172.16.0.254
+	//  - boot_script:http://172.16.0.254/boot.ipxe
+	//  - bss_uri:http://172.16.0.254:8081
+	//  - netboot:false
+	if a.Boot, err = parseBoot(comps); err != nil {
+		return Rule{}, err
+	}
+
 	// netmask/cidr (action)
 	netmaskstr, netmaskFound := comps["netmask"]
 	cidrstr, cidrFound := comps["cidr"]
@@ -448,9 +696,12 @@ func ParseRule(rule string) (Rule, error) {
 	// The ignore action is also valid as a standalone action.
 	if strings.TrimSpace(a.Hostname) == "" &&
 		len(a.Routers) == 0 &&
-		!a.Ignore {
+		!a.Ignore &&
+		!a.hasOptions() &&
+		a.Boot.IsZero() {
 		if ones, size := a.Netmask.Size(); ones == 0 || size == 0 {
-			return Rule{}, NewErrRequiredKeys("hostname", "routers", "netmask", "ignore")
+			return Rule{}, NewErrRequiredKeys("hostname", "routers", "netmask", "ignore", "dns", "ntp", "mtu", "search", "static_routes", "option",
+				"bootloader", "next_server", "boot_script", "bss_uri", "netboot")
 		}
 	}
 
@@ -475,6 +726,19 @@ func ParseRule(rule string) (Rule, error) {
 		return Rule{}, NewErrMutualExclusion("id", "id_set")
 	}
 
+	// match by component state, inventory details, and membership (optional;
+	// multivalue)
+	//
+	// Examples:
+	//  - role:Compute
+	//  - state:Ready|On
+	//  - enabled:true
+	//  - group:compute|login
+	//  - partition:p1
+	if err := parseComponentMatch(comps, &m); err != nil {
+		return Rule{}, err
+	}
+
 	r.Match = m
 	r.Action = a
 
@@ -487,6 +751,102 @@ func ParseRule(rule string) (Rule, error) {
 	return r, nil
 }
 
+// ParseMatch parses a string of rule match keys, such as
+// "type:Node,role:Compute,enabled:true", and returns the criteria. Action keys
+// are not allowed.
+func ParseMatch(match string) (Match, error) {
+	comps, err := createRuleCompDict(match)
+	if err != nil {
+		return Match{}, err
+	}
+	for key := range comps {
+		if !slices.Contains(MatchKeys, key) {
+			return Match{}, NewErrInvalidValue(key, comps[key], "only match keys ("+strings.Join(MatchKeys, ", ")+")")
+		}
+	}
+
+	var m Match
+	if typ, ok := comps["type"]; ok {
+		if m.Types = parseValueSet(typ, false); len(m.Types) == 0 {
+			return Match{}, NewErrInvalidValue("type", typ, "at least one type")
+		}
+	}
+	if subnets, ok := comps["subnet"]; ok {
+		for _, s := range strings.Split(subnets, "|") {
+			_, ipnet, err := net.ParseCIDR(strings.TrimSpace(s))
+			if err != nil {
+				return Match{}, NewErrInvalidValue("subnet", s, "network subnet (e.g. 172.16.0.0/21)")
+			}
+			m.Subnets = append(m.Subnets, ipnet)
+		}
+	}
+	m.ID = comps["id"]
+	if idset, ok := comps["id_set"]; ok && idset != "" {
+		matcher, err := CompileIDSet(idset)
+		if err != nil {
+			return Match{}, NewErrInvalidValue("id_set", idset, "valid ID set (e.g. x1000s0c0b[0-3]n0)")
+		}
+		m.IDSet = matcher
+	}
+	if m.ID != "" && m.IDSet != nil {
+		return Match{}, NewErrMutualExclusion("id", "id_set")
+	}
+	if err := parseComponentMatch(comps, &m); err != nil {
+		return Match{}, err
+	}
+	return m, nil
+}
+
+// parseComponentMatch sets the component state, inventory, and membership
+// criteria given in comps in m.
+func parseComponentMatch(comps map[string]string, m *Match) error {
+	for _, attr := range []struct {
+		key string
+		set *map[string]bool
+	}{
+		{"arch", &m.Archs},
+		{"class", &m.Classes},
+		{"flag", &m.Flags},
+		{"group", &m.Groups},
+		{"partition", &m.Partitions},
+		{"role", &m.Roles},
+		{"state", &m.States},
+		{"subrole", &m.SubRoles},
+	} {
+		val, ok := comps[attr.key]
+		if !ok {
+			continue
+		}
+		if *attr.set = parseValueSet(val, true); len(*attr.set) == 0 {
+			return NewErrInvalidValue(attr.key, val, "at least one "+attr.key)
+		}
+	}
+	if enabled, ok := comps["enabled"]; ok {
+		b, err := parse.ParseBoolLoose(enabled)
+		if err != nil {
+			return NewErrInvalidValue("enabled", enabled, "boolean")
+		}
+		m.Enabled = &b
+	}
+	return nil
+}
+
+// parseValueSet parses values separated by '|' into a set, lowercasing them if
+// fold is true.
+func parseValueSet(s string, fold bool) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range strings.Split(s, "|") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		if fold {
+			v = strings.ToLower(v)
+		}
+		set[v] = true
+	}
+	return set
+}
+
 // normalizeDomainAppend validates and normalizes the domain_append value.
 //
 // Accepted inputs (case-insensitive, whitespace-tolerant):
@@ -699,6 +1059,9 @@ func Evaluate4(logger *logrus.Entry, ii iface.IfaceInfo, globalDomain, ruleLog s
 				resp.Options.Update(dhcpv4.OptSubnetMask(mask))
 			}
 
+			// Set other options
+			applyOptions4(resp, rule.Action)
+
 			if !cont {
 				// Continue not specified for match, so stop here
 				break
@@ -720,6 +1083,29 @@ func Evaluate4(logger *logrus.Entry, ii iface.IfaceInfo, globalDomain, ruleLog s
 	return true // Send DHCP response to client
 }
 
+// applyOptions4 sets the DHCPv4 options of action in resp. Raw options are set
+// last, so they take precedence over the other keys.
+func applyOptions4(resp *dhcpv4.DHCPv4, action Action) {
+	if len(action.DNS) > 0 {
+		resp.Options.Update(dhcpv4.OptDNS(action.DNS...))
+	}
+	if len(action.NTP) > 0 {
+		resp.Options.Update(dhcpv4.OptNTPServers(action.NTP...))
+	}
+	if action.MTU != 0 {
+		resp.Options.Update(dhcpv4.Option{Code: dhcpv4.OptionInterfaceMTU, Value: dhcpv4.Uint16(action.MTU)})
+	}
+	if len(action.Search) > 0 {
+		resp.Options.Update(dhcpv4.OptDomainSearch(&rfc1035label.Labels{Labels: action.Search}))
+	}
+	if len(action.StaticRoutes) > 0 {
+		resp.Options.Update(dhcpv4.OptClasslessStaticRoute(action.StaticRoutes...))
+	}
+	for _, o := range action.Options {
+		resp.Options.Update(o.Option())
+	}
+}
+
 // Evaluate6 takes interface information from a DHCPv6 request and a list of
 // rules to evaluate and modifies the passed DHCPv6 response according to the
 // rules.
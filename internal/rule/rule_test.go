@@ -85,7 +85,14 @@ func TestParseRule_Table(t *testing.T) {
 		{"ok_minimal", "hostname:nid{04d}", false},
 		{"ok_multi", "name:r1,log:debug,hostname:x,continue:yes,domain_append:global|rule,type:Node| NodeBMC ,subnet:172.16.0.0/24|172.16.1.0/24", false},
 		{"ok_domain_append_rule_global", "hostname:x,domain:override.local,domain_append:rule|global", false},
-		{"id_set_unimplemented", "hostname:x,id_set:x1000s[0-3]c0b0n[0-7]", true},
+		{"ok_id_set", "hostname:x,id_set:x1000s[0-3]c0b0n[0-7]", false},
+		{"ok_id_set_quoted_union", "hostname:x,id_set:'x1000s[0-3]c0b0n[0,2],!x1000s1c0b0n0'", false},
+		{"id_set_invalid", "hostname:x,id_set:x1000s[3-0]c0b0n0", true},
+		{"ok_component_state", "hostname:x,role:Compute,subrole:Worker,state:Ready|On,flag:OK,enabled:true,arch:ARM,class:River", false},
+		{"role_empty", "hostname:x,role:|", true},
+		{"enabled_invalid", "hostname:x,enabled:sometimes", true},
+		{"ok_membership", "hostname:x,group:compute|login,partition:p1", false},
+		{"group_empty", "ignore:true,group:", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -113,6 +120,12 @@ func TestParseRule_Table(t *testing.T) {
 					t.Fatalf("expected domain_append=%q got=%q", "global|rule", r.Action.DomainAppend)
 				}
 			}
+			if tt.name == "ok_component_state" {
+				if !r.Match.Roles["compute"] || !r.Match.States["ready"] || !r.Match.States["on"] || !r.Match.Archs["arm"] ||
+					r.Match.Enabled == nil || !*r.Match.Enabled {
+					t.Fatalf("unexpected match=%+v", r.Match)
+				}
+			}
 			if tt.name == "ok_domain_append_rule_global" {
 				if r.Action.DomainAppend != "rule|global" {
 					t.Fatalf("expected domain_append=%q got=%q", "rule|global", r.Action.DomainAppend)
@@ -126,6 +139,10 @@ func TestRuleMatchIface_Combinations(t *testing.T) {
 	iiNode := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "Node", MAC: "aa", IPList: []net.IP{net.ParseIP("172.16.0.10")}}
 	iiBMC := iface.IfaceInfo{CompID: "x1000s0c0b0n0", Type: "NodeBMC", MAC: "bb", IPList: []net.IP{net.ParseIP("172.16.10.10")}}
 	iiEmptyType := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "", MAC: "cc", IPList: []net.IP{net.ParseIP("172.16.0.10")}}
+	iiCompute := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "Node", MAC: "dd", State: "Ready", Role: "Compute", Arch: "ARM", Enabled: true,
+		Groups: []string{"compute", "gpu"}, Partition: "p1"}
+	iiDisabled := iface.IfaceInfo{CompID: "x1000s0c0b0n1", CompNID: 8, Type: "Node", MAC: "ee", State: "Off", Role: "Management", Arch: "X86"}
+	yes := true
 
 	mset := staticSet{"x1000s0c0b0n0": true, "x1000s0c0b0n1": true}
 
@@ -144,6 +161,18 @@ func TestRuleMatchIface_Combinations(t *testing.T) {
 		{"idset_match", Rule{Match: Match{IDSet: mset}, Action: Action{Hostname: "x"}}, iiNode, true},
 		{"compound_all_required", Rule{Match: Match{Types: map[string]bool{"Node": true}, Subnets: []*net.IPNet{mustCIDR(t, "172.16.0.0/24")}, IDSet: mset}, Action: Action{Hostname: "x"}}, iiNode, true},
 		{"compound_missing_one", Rule{Match: Match{Types: map[string]bool{"Node": true}, Subnets: []*net.IPNet{mustCIDR(t, "172.16.99.0/24")}, IDSet: mset}, Action: Action{Hostname: "x"}}, iiNode, false},
+		{"role_match_case_insensitive", Rule{Match: Match{Roles: map[string]bool{"compute": true}}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"role_mismatch", Rule{Match: Match{Roles: map[string]bool{"compute": true}}, Action: Action{Hostname: "x"}}, iiDisabled, false},
+		{"state_any_of", Rule{Match: Match{States: map[string]bool{"ready": true, "on": true}}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"enabled_match", Rule{Match: Match{Enabled: &yes}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"enabled_mismatch", Rule{Match: Match{Enabled: &yes}, Action: Action{Hostname: "x"}}, iiDisabled, false},
+		{"arch_and_type", Rule{Match: Match{Types: map[string]bool{"Node": true}, Archs: map[string]bool{"arm": true}}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"group_any_member", Rule{Match: Match{Groups: map[string]bool{"gpu": true, "login": true}}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"group_not_member", Rule{Match: Match{Groups: map[string]bool{"login": true}}, Action: Action{Hostname: "x"}}, iiCompute, false},
+		{"group_no_groups", Rule{Match: Match{Groups: map[string]bool{"compute": true}}, Action: Action{Hostname: "x"}}, iiDisabled, false},
+		{"partition_match", Rule{Match: Match{Partitions: map[string]bool{"p1": true}}, Action: Action{Hostname: "x"}}, iiCompute, true},
+		{"partition_none", Rule{Match: Match{Partitions: map[string]bool{"p1": true}}, Action: Action{Hostname: "x"}}, iiDisabled, false},
+		{"arch_mismatch", Rule{Match: Match{Types: map[string]bool{"Node": true}, Archs: map[string]bool{"arm": true}}, Action: Action{Hostname: "x"}}, iiDisabled, false},
 	}
 
 	for _, tt := range tests {
@@ -156,6 +185,38 @@ func TestRuleMatchIface_Combinations(t *testing.T) {
 	}
 }
 
+func TestParseMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{name: "component_state", in: "role:Compute|Service,enabled:false", want: "role:compute|service,enabled:false"},
+		{name: "type_and_state", in: "type:Node,state:Ready", want: "types:Node,state:ready"},
+		{name: "id_set", in: "id_set:x1000s0c0b0n[0-3]", want: "id_set:x1000s0c0b0n[0-3]"},
+		{name: "membership", in: "group:Login|compute,partition:p1", want: "partition:p1,group:compute|login"},
+		{name: "empty", in: "", wantErr: true},
+		{name: "action_key", in: "role:Compute,hostname:x", wantErr: true},
+		{name: "enabled_invalid", in: "enabled:maybe", wantErr: true},
+		{name: "subnet_invalid", in: "subnet:notacidr", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMatch(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err=%v wantErr=%v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := m.String(); got != tt.want {
+				t.Fatalf("expected %q got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestEvaluate4_HostnameRoutersAndDefault(t *testing.T) {
 	ii := iface.IfaceInfo{CompID: "x1000s0c0b0n0", CompNID: 7, Type: "Node", MAC: "aa", IPList: []net.IP{net.ParseIP("172.16.0.10")}}
 
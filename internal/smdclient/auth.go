@@ -0,0 +1,382 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package smdclient
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxTokenRefreshMargin is the most time before a token's expiry that it
+	// will be refreshed.
+	maxTokenRefreshMargin = 1 * time.Minute
+
+	// defaultTokenLifetime is how long a token whose response has no
+	// expires_in is used before a new one is requested.
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// TokenSource supplies bearer tokens that are sent to SMD in the Authorization
+// header.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenInvalidator is implemented by TokenSources that cache tokens. When SMD
+// rejects a token with 401 Unauthorized, Invalidate is called with it so that
+// the next call to Token does not return it again.
+type TokenInvalidator interface {
+	Invalidate(token string)
+}
+
+// FileTokenSource reads a static bearer token from a file. The file is re-read
+// whenever its modification time changes so that tokens can be rotated
+// without a restart.
+type FileTokenSource struct {
+	Path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+}
+
+// NewFileTokenSource returns a FileTokenSource for path after checking that a
+// token can be read from it.
+func NewFileTokenSource(path string) (*FileTokenSource, error) {
+	ts := &FileTokenSource{Path: path}
+	if _, err := ts.Token(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Token returns the token in the file, with surrounding whitespace removed.
+func (ts *FileTokenSource) Token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	fi, err := os.Stat(ts.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat token file: %w", err)
+	}
+	if ts.token != "" && fi.ModTime().Equal(ts.modTime) {
+		return ts.token, nil
+	}
+
+	data, err := os.ReadFile(ts.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", ts.Path)
+	}
+	ts.token = token
+	ts.modTime = fi.ModTime()
+
+	return ts.token, nil
+}
+
+// Invalidate causes the next call to Token to re-read the file if token is
+// the cached token.
+func (ts *FileTokenSource) Invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == token {
+		ts.token = ""
+	}
+}
+
+// ClientCredentialsTokenSource fetches tokens from an OAuth2 token endpoint
+// using the client credentials grant (RFC 6749 section 4.4). Tokens are cached
+// and refreshed shortly before they expire.
+type ClientCredentialsTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	// HTTPClient is used to request tokens. If nil, http.DefaultClient is
+	// used.
+	HTTPClient *http.Client
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time // when the cached token should be refreshed
+}
+
+// tokenResponse is a successful response from an OAuth2 token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns the cached access token, requesting a new one if there is no
+// cached token or it is about to expire.
+func (ts *ClientCredentialsTokenSource) Token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.refresh) {
+		return ts.token, nil
+	}
+
+	form := url.Values{"grant_type": []string{"client_credentials"}}
+	if len(ts.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", ts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(ts.ClientID), url.QueryEscape(ts.ClientSecret))
+
+	client := ts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	requested := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", fmt.Errorf("failed to unmarshal token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("token response did not contain an access_token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", fmt.Errorf("unsupported token type %q", tr.TokenType)
+	}
+
+	ts.token = tr.AccessToken
+	lifetime := defaultTokenLifetime
+	if tr.ExpiresIn > 0 {
+		lifetime = time.Duration(tr.ExpiresIn) * time.Second
+	}
+	// Refresh a tenth of the lifetime early, but no more than
+	// maxTokenRefreshMargin
+	ts.refresh = requested.Add(lifetime - min(lifetime/10, maxTokenRefreshMargin))
+
+	return ts.token, nil
+}
+
+// Invalidate causes the next call to Token to request a new token if token is
+// the cached token.
+func (ts *ClientCredentialsTokenSource) Invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.token == token {
+		ts.token = ""
+	}
+}
+
+// UseTokenSource causes requests to SMD to be sent with a bearer token from ts.
+func (sc *SmdClient) UseTokenSource(ts TokenSource) error {
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	sc.TokenSource = ts
+	return nil
+}
+
+// Authorize sets the Authorization header of req if the client has a
+// TokenSource.
+func (sc *SmdClient) Authorize(req *http.Request) error {
+	if sc == nil || sc.TokenSource == nil {
+		return nil
+	}
+	token, err := sc.TokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get SMD access token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// invalidateToken invalidates the token req was sent with if err is a 401
+// Unauthorized response to it and the client's TokenSource caches tokens.
+// Returns true if the token was invalidated, in which case the request should
+// be retried once with a new token.
+func (sc *SmdClient) invalidateToken(req *http.Request, err error) bool {
+	inv, ok := sc.TokenSource.(TokenInvalidator)
+	if !ok || !errors.Is(err, ErrUnauthorized) {
+		return false
+	}
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	inv.Invalidate(token)
+	return true
+}
+
+// tokenClient returns an HTTP client for requesting tokens with the same
+// transport and timeout as requests to SMD.
+func (sc *SmdClient) tokenClient() *http.Client {
+	return &http.Client{Transport: sc.Transport, Timeout: sc.Timeout}
+}
+
+// syncTokenClient updates the HTTP client of the client's
+// ClientCredentialsTokenSource, if any, after the transport or timeout of the
+// client changed.
+func (sc *SmdClient) syncTokenClient() {
+	ts, ok := sc.TokenSource.(*ClientCredentialsTokenSource)
+	if !ok {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.HTTPClient = sc.tokenClient()
+}
+
+// UseClientCert configures the client to present the certificate and key at
+// certPath and keyPath (PEM) to SMD for mutual TLS. It may be used together
+// with UseCACert in either order.
+func (sc *SmdClient) UseClientCert(certPath, keyPath string) error {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+
+	tr := sc.httpTransport()
+	tr.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	sc.Transport = tr
+	sc.syncTokenClient()
+
+	return nil
+}
+
+// httpTransport returns a copy of the client's *http.Transport with a non-nil
+// TLS config, or a new transport with the default settings if the client does
+// not have one.
+func (sc *SmdClient) httpTransport() *http.Transport {
+	var tr *http.Transport
+	if cur, ok := sc.Transport.(*http.Transport); ok && cur != nil {
+		tr = cur.Clone()
+	} else {
+		tr = newTransport()
+	}
+	if tr.TLSClientConfig == nil {
+		tr.TLSClientConfig = &tls.Config{}
+	}
+	return tr
+}
+
+// AuthConfig holds the settings for authenticating to SMD, as configured in
+// the plugins. At most one of TokenFile and TokenURL may be set. ClientCert
+// and ClientKey may be combined with either.
+type AuthConfig struct {
+	TokenFile        string   // static bearer token file
+	TokenURL         string   // OAuth2 token endpoint for client credentials
+	ClientID         string   // OAuth2 client ID
+	ClientSecretFile string   // file containing the OAuth2 client secret
+	Scopes           []string // OAuth2 scopes to request
+	ClientCert       string   // PEM client certificate for mutual TLS
+	ClientKey        string   // PEM client key for mutual TLS
+}
+
+// Validate checks that the combination of settings in a is usable.
+func (a AuthConfig) Validate() []error {
+	var errs []error
+	if a.TokenFile != "" && a.TokenURL != "" {
+		errs = append(errs, fmt.Errorf("token_file and token_url are mutually exclusive"))
+	}
+	if a.TokenURL != "" {
+		if a.ClientID == "" {
+			errs = append(errs, fmt.Errorf("token_url requires client_id"))
+		}
+		if a.ClientSecretFile == "" {
+			errs = append(errs, fmt.Errorf("token_url requires client_secret_file"))
+		}
+	} else if a.ClientID != "" || a.ClientSecretFile != "" || len(a.Scopes) > 0 {
+		errs = append(errs, fmt.Errorf("client_id, client_secret_file, and token_scopes require token_url"))
+	}
+	if (a.ClientCert == "") != (a.ClientKey == "") {
+		errs = append(errs, fmt.Errorf("client_cert and client_key must be set together"))
+	}
+	return errs
+}
+
+// String returns a description of a suitable for logging, without secrets.
+func (a AuthConfig) String() string {
+	return fmt.Sprintf("token_file=%s token_url=%s client_id=%s client_secret_file=%s token_scopes=%s client_cert=%s client_key=%s",
+		a.TokenFile, a.TokenURL, a.ClientID, a.ClientSecretFile, strings.Join(a.Scopes, ","), a.ClientCert, a.ClientKey)
+}
+
+// UseAuth configures the client according to a. The token endpoint is
+// contacted with the same TLS settings, timeout, and connection reuse as SMD,
+// also if they are changed later.
+func (sc *SmdClient) UseAuth(a AuthConfig) error {
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+	if errs := a.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	if a.ClientCert != "" {
+		if err := sc.UseClientCert(a.ClientCert, a.ClientKey); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case a.TokenFile != "":
+		ts, err := NewFileTokenSource(a.TokenFile)
+		if err != nil {
+			return err
+		}
+		return sc.UseTokenSource(ts)
+	case a.TokenURL != "":
+		secret, err := os.ReadFile(a.ClientSecretFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client secret file: %w", err)
+		}
+		return sc.UseTokenSource(&ClientCredentialsTokenSource{
+			TokenURL:     a.TokenURL,
+			ClientID:     a.ClientID,
+			ClientSecret: strings.TrimSpace(string(secret)),
+			Scopes:       a.Scopes,
+			HTTPClient:   sc.tokenClient(),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,640 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package smdclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//==============================================================================
+// Helpers
+//==============================================================================
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// writeClientCert generates a self-signed client certificate and writes it
+// and its key to dir as PEM, returning their paths and the certificate.
+func writeClientCert(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "coresmd"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPath = writeFile(t, dir, "client.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPath = writeFile(t, dir, "client.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	return certPath, keyPath, cert
+}
+
+type staticTokenSource struct {
+	token string
+	err   error
+}
+
+func (s staticTokenSource) Token() (string, error) {
+	return s.token, s.err
+}
+
+//==============================================================================
+// FileTokenSource
+//==============================================================================
+
+func TestFileTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	path := writeFile(t, dir, "token", []byte("  token-1\n"))
+
+	ts, err := NewFileTokenSource(path)
+	if err != nil {
+		t.Fatalf("NewFileTokenSource() unexpected error: %v", err)
+	}
+	if tok, err := ts.Token(); err != nil || tok != "token-1" {
+		t.Fatalf("Token() = %q, %v, want %q", tok, err, "token-1")
+	}
+
+	// Rotate the token; a new modification time causes a re-read
+	writeFile(t, dir, "token", []byte("token-2"))
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to set token file times: %v", err)
+	}
+	if tok, err := ts.Token(); err != nil || tok != "token-2" {
+		t.Fatalf("Token() after rotation = %q, %v, want %q", tok, err, "token-2")
+	}
+}
+
+func TestNewFileTokenSource_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "missing_file",
+			path: filepath.Join(dir, "missing"),
+		},
+		{
+			name: "empty_file",
+			path: writeFile(t, dir, "empty", []byte(" \n")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewFileTokenSource(tt.path); err == nil {
+				t.Fatalf("NewFileTokenSource() error = nil, want non-nil")
+			}
+		})
+	}
+}
+
+//==============================================================================
+// ClientCredentialsTokenSource
+//==============================================================================
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "coresmd" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+			t.Errorf("grant_type = %q, want client_credentials", got)
+		}
+		if got := r.PostForm.Get("scope"); got != "smd.read smd.events" {
+			t.Errorf("scope = %q, want %q", got, "smd.read smd.events")
+		}
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer srv.Close()
+
+	ts := &ClientCredentialsTokenSource{
+		TokenURL:     srv.URL,
+		ClientID:     "coresmd",
+		ClientSecret: "s3cr3t",
+		Scopes:       []string{"smd.read", "smd.events"},
+		HTTPClient:   srv.Client(),
+	}
+
+	if tok, err := ts.Token(); err != nil || tok != "token-1" {
+		t.Fatalf("Token() = %q, %v, want %q", tok, err, "token-1")
+	}
+	// Cached until shortly before expiry
+	if tok, err := ts.Token(); err != nil || tok != "token-1" {
+		t.Fatalf("second Token() = %q, %v, want cached %q", tok, err, "token-1")
+	}
+	if remaining := time.Until(ts.refresh); remaining < 58*time.Minute || remaining > time.Hour {
+		t.Errorf("token refreshes in %s, want one minute before expiry", remaining)
+	}
+
+	// Once the refresh time passes, a new token is requested
+	ts.refresh = time.Now().Add(-time.Second)
+	if tok, err := ts.Token(); err != nil || tok != "token-2" {
+		t.Fatalf("Token() after refresh time = %q, %v, want %q", tok, err, "token-2")
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("token requests = %d, want 2", n)
+	}
+}
+
+func TestClientCredentialsTokenSource_NoExpiry(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer"}`, requests.Add(1))
+	}))
+	defer srv.Close()
+
+	ts := &ClientCredentialsTokenSource{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret", HTTPClient: srv.Client()}
+	for range 3 {
+		if tok, err := ts.Token(); err != nil || tok != "token-1" {
+			t.Fatalf("Token() = %q, %v, want cached %q", tok, err, "token-1")
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("token requests = %d, want 1", n)
+	}
+	if remaining := time.Until(ts.refresh); remaining < 4*time.Minute || remaining > defaultTokenLifetime {
+		t.Errorf("token refreshes in %s, want shortly before the default lifetime of %s", remaining, defaultTokenLifetime)
+	}
+
+	// An invalidated token is not returned again
+	ts.Invalidate("token-1")
+	if tok, err := ts.Token(); err != nil || tok != "token-2" {
+		t.Fatalf("Token() after Invalidate() = %q, %v, want %q", tok, err, "token-2")
+	}
+}
+
+func TestClientCredentialsTokenSource_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{
+			name:   "unauthorized",
+			status: http.StatusUnauthorized,
+			body:   `{"error":"invalid_client"}`,
+		},
+		{
+			name:   "invalid_json",
+			status: http.StatusOK,
+			body:   `{"access_token":`,
+		},
+		{
+			name:   "missing_access_token",
+			status: http.StatusOK,
+			body:   `{"token_type":"Bearer","expires_in":60}`,
+		},
+		{
+			name:   "unsupported_token_type",
+			status: http.StatusOK,
+			body:   `{"access_token":"abc","token_type":"mac","expires_in":60}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			ts := &ClientCredentialsTokenSource{TokenURL: srv.URL, ClientID: "id", ClientSecret: "secret", HTTPClient: srv.Client()}
+			if tok, err := ts.Token(); err == nil {
+				t.Fatalf("Token() = %q, want error", tok)
+			}
+		})
+	}
+}
+
+//==============================================================================
+// SmdClient authentication
+//==============================================================================
+
+func TestSmdClientAPIGet_BearerToken(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	client := NewSmdClient(baseURL)
+	client.Client = srv.Client()
+	client.Retry = RetryPolicy{}
+
+	// No token source, no header
+	if _, err := client.APIGet("/path"); err != nil {
+		t.Fatalf("APIGet() unexpected error: %v", err)
+	}
+	if gotAuth != "" {
+		t.Errorf("Authorization = %q, want none", gotAuth)
+	}
+
+	if err := client.UseTokenSource(staticTokenSource{token: "abc"}); err != nil {
+		t.Fatalf("UseTokenSource() unexpected error: %v", err)
+	}
+	if _, err := client.APIGet("/path"); err != nil {
+		t.Fatalf("APIGet() unexpected error: %v", err)
+	}
+	if gotAuth != "Bearer abc" {
+		t.Errorf("APIGet() Authorization = %q, want %q", gotAuth, "Bearer abc")
+	}
+	gotAuth = ""
+	if _, _, _, err := client.APIGetConditional("/path", nil, Validators{}); err != nil {
+		t.Fatalf("APIGetConditional() unexpected error: %v", err)
+	}
+	if gotAuth != "Bearer abc" {
+		t.Errorf("APIGetConditional() Authorization = %q, want %q", gotAuth, "Bearer abc")
+	}
+
+	// A token that can't be obtained fails the request without sending it
+	gotAuth = ""
+	client.TokenSource = staticTokenSource{err: fmt.Errorf("token endpoint down")}
+	if _, err := client.APIGet("/path"); err == nil {
+		t.Fatalf("APIGet() error = nil, want non-nil when token source fails")
+	}
+	if gotAuth != "" {
+		t.Errorf("request sent despite token source failure")
+	}
+}
+
+func TestSmdClientAPIGet_RevokedToken(t *testing.T) {
+	var tokens atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":3600}`, tokens.Add(1))
+	}))
+	defer tokenSrv.Close()
+
+	// token-1 has been revoked, and every token once revokeAll is set
+	var (
+		requests  atomic.Int32
+		revokeAll atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if revokeAll.Load() || r.Header.Get("Authorization") == "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	client := NewSmdClient(baseURL)
+	client.Client = srv.Client()
+	client.Retry = RetryPolicy{}
+	client.TokenSource = &ClientCredentialsTokenSource{TokenURL: tokenSrv.URL, ClientID: "id", ClientSecret: "secret", HTTPClient: tokenSrv.Client()}
+
+	if _, err := client.APIGet("/path"); err != nil {
+		t.Fatalf("APIGet() unexpected error: %v", err)
+	}
+	if n, tok := requests.Load(), tokens.Load(); n != 2 || tok != 2 {
+		t.Fatalf("%d requests with %d tokens, want 2 requests with 2 tokens", n, tok)
+	}
+
+	// A request is only retried once
+	revokeAll.Store(true)
+	requests.Store(0)
+	if _, err := client.APIGet("/path"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("APIGet() error = %v, want ErrUnauthorized", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("%d requests, want 2", n)
+	}
+}
+
+func TestSmdClientUseAuth_HTTPConfig(t *testing.T) {
+	// The token endpoint fails once, and records whether the request asked
+	// for the connection to be closed
+	var (
+		tokens    atomic.Int32
+		keepAlive atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			keepAlive.Store(!r.Close)
+			if tokens.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"from-endpoint","token_type":"bearer","expires_in":300}`))
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+
+	// The HTTP settings are applied after the token source is created
+	client := NewSmdClient(baseURL)
+	err = client.UseAuth(AuthConfig{
+		TokenURL:         srv.URL + "/token",
+		ClientID:         "coresmd",
+		ClientSecretFile: writeFile(t, t.TempDir(), "secret", []byte("s3cr3t\n")),
+	})
+	if err != nil {
+		t.Fatalf("UseAuth() error = %v", err)
+	}
+	err = client.UseHTTPConfig(HTTPConfig{
+		Timeout:      ptr(5 * time.Second),
+		Retries:      ptr(1),
+		RetryBackoff: ptr(time.Millisecond),
+		KeepAlive:    ptr(false),
+	})
+	if err != nil {
+		t.Fatalf("UseHTTPConfig() error = %v", err)
+	}
+
+	ts := client.TokenSource.(*ClientCredentialsTokenSource)
+	if ts.HTTPClient.Timeout != 5*time.Second {
+		t.Errorf("token client Timeout = %v, want 5s", ts.HTTPClient.Timeout)
+	}
+	if ts.HTTPClient.Transport != client.Transport {
+		t.Errorf("token client does not use the SMD client's transport")
+	}
+
+	if _, err := client.APIGet("/path"); err != nil {
+		t.Fatalf("APIGet() unexpected error: %v", err)
+	}
+	if n := tokens.Load(); n != 2 {
+		t.Errorf("token requests = %d, want 2", n)
+	}
+	if keepAlive.Load() {
+		t.Errorf("token request kept the connection alive, want it closed")
+	}
+}
+
+func TestSmdClientUseClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := writeClientCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "coresmd" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caPath := writeFile(t, dir, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+
+	// Without a client certificate, the handshake fails
+	client := NewSmdClient(baseURL)
+	if err := client.UseCACert(caPath); err != nil {
+		t.Fatalf("UseCACert() unexpected error: %v", err)
+	}
+	if _, err := client.APIGet("/path"); err == nil {
+		t.Fatalf("APIGet() error = nil, want non-nil without client certificate")
+	}
+
+	// The client certificate and CA certificate can be set in either order
+	for _, order := range []string{"cert_then_ca", "ca_then_cert"} {
+		t.Run(order, func(t *testing.T) {
+			client := NewSmdClient(baseURL)
+			steps := []func() error{
+				func() error { return client.UseClientCert(certPath, keyPath) },
+				func() error { return client.UseCACert(caPath) },
+			}
+			if order == "ca_then_cert" {
+				steps[0], steps[1] = steps[1], steps[0]
+			}
+			for _, step := range steps {
+				if err := step(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			data, err := client.APIGet("/path")
+			if err != nil {
+				t.Fatalf("APIGet() unexpected error: %v", err)
+			}
+			if string(data) != "ok" {
+				t.Errorf("APIGet() body = %q, want %q", string(data), "ok")
+			}
+		})
+	}
+}
+
+func TestSmdClientUseClientCert_Errors(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, _ := writeClientCert(t, dir)
+
+	if err := NewSmdClient(nil).UseClientCert(filepath.Join(dir, "missing.crt"), keyPath); err == nil {
+		t.Errorf("UseClientCert() error = nil, want non-nil for missing certificate")
+	}
+	if err := NewSmdClient(nil).UseClientCert(certPath, certPath); err == nil {
+		t.Errorf("UseClientCert() error = nil, want non-nil for invalid key")
+	}
+	var nilClient *SmdClient
+	if err := nilClient.UseClientCert(certPath, keyPath); err == nil {
+		t.Errorf("UseClientCert() error = nil, want non-nil for nil receiver")
+	}
+}
+
+//==============================================================================
+// AuthConfig
+//==============================================================================
+
+func TestAuthConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      AuthConfig
+		wantErrs int
+	}{
+		{name: "empty", cfg: AuthConfig{}},
+		{name: "token_file", cfg: AuthConfig{TokenFile: "/run/secrets/token"}},
+		{
+			name: "client_credentials_and_mtls",
+			cfg: AuthConfig{
+				TokenURL:         "https://auth.example.test/token",
+				ClientID:         "coresmd",
+				ClientSecretFile: "/run/secrets/secret",
+				Scopes:           []string{"smd.read"},
+				ClientCert:       "/etc/coresmd/client.crt",
+				ClientKey:        "/etc/coresmd/client.key",
+			},
+		},
+		{
+			name:     "token_file_and_token_url",
+			cfg:      AuthConfig{TokenFile: "/t", TokenURL: "https://a/t", ClientID: "id", ClientSecretFile: "/s"},
+			wantErrs: 1,
+		},
+		{
+			name:     "token_url_missing_client",
+			cfg:      AuthConfig{TokenURL: "https://a/t"},
+			wantErrs: 2,
+		},
+		{
+			name:     "client_id_without_token_url",
+			cfg:      AuthConfig{ClientID: "id"},
+			wantErrs: 1,
+		},
+		{
+			name:     "client_cert_without_key",
+			cfg:      AuthConfig{ClientCert: "/c"},
+			wantErrs: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.cfg.Validate(); len(errs) != tt.wantErrs {
+				t.Fatalf("Validate() = %v, want %d errors", errs, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestSmdClientUseAuth(t *testing.T) {
+	dir := t.TempDir()
+
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if _, secret, _ := r.BasicAuth(); secret != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"from-endpoint","token_type":"bearer","expires_in":300}`))
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		cfg      AuthConfig
+		wantAuth string
+		wantErr  bool
+	}{
+		{
+			name:     "token_file",
+			cfg:      AuthConfig{TokenFile: writeFile(t, dir, "token", []byte("from-file\n"))},
+			wantAuth: "Bearer from-file",
+		},
+		{
+			name: "client_credentials",
+			cfg: AuthConfig{
+				TokenURL:         srv.URL + "/token",
+				ClientID:         "coresmd",
+				ClientSecretFile: writeFile(t, dir, "secret", []byte("s3cr3t\n")),
+			},
+			wantAuth: "Bearer from-endpoint",
+		},
+		{
+			name:    "missing_secret_file",
+			cfg:     AuthConfig{TokenURL: srv.URL + "/token", ClientID: "coresmd", ClientSecretFile: filepath.Join(dir, "missing")},
+			wantErr: true,
+		},
+		{
+			name:    "invalid_combination",
+			cfg:     AuthConfig{ClientKey: "/k"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotAuth = ""
+			client := NewSmdClient(baseURL)
+			err := client.UseAuth(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UseAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err := client.APIGet("/hsm/v2/State/Components"); err != nil {
+				t.Fatalf("APIGet() unexpected error: %v", err)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+		})
+	}
+}
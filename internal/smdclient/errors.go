@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package smdclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// maxErrorBodyLen is the maximum number of bytes of a response body included
+// in an HTTPError.
+const maxErrorBodyLen = 512
+
+// Classes of non-2xx responses. An HTTPError matches (via errors.Is) the class
+// its status code belongs to.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrClient       = errors.New("client error")
+	ErrServer       = errors.New("server error")
+	ErrUnexpected   = errors.New("unexpected status")
+)
+
+// HTTPError is returned when SMD responds with an unexpected (usually non-2xx)
+// status code.
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string // truncated to maxErrorBodyLen bytes
+}
+
+// NewHTTPError creates an HTTPError for resp, whose body has already been read
+// into body.
+func NewHTTPError(resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			e.URL = resp.Request.URL.Redacted()
+		}
+	}
+	if len(body) > maxErrorBodyLen {
+		body = body[:maxErrorBodyLen]
+	}
+	e.Body = string(body)
+	return e
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s %s: unexpected HTTP status %s", e.Method, e.URL, e.Status)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Unwrap returns the class of the error (e.g. ErrUnauthorized) so that it can
+// be checked with errors.Is.
+func (e *HTTPError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= 400 && e.StatusCode < 500:
+		return ErrClient
+	case e.StatusCode >= 500 && e.StatusCode < 600:
+		return ErrServer
+	}
+	return ErrUnexpected
+}
+
+// Temporary returns true if the request may succeed if retried.
+func (e *HTTPError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryable returns true if err, returned from an attempt to make a request,
+// is likely to be transient.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Temporary()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// Certificate problems and handshakes rejected by SMD won't fix
+	// themselves
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		// TLS alert sent by SMD, e.g. because a client certificate is
+		// required
+		return false
+	}
+	// Remaining errors are from obtaining a token, the transport (connection
+	// refused/reset, timeouts, etc.), or reading the body
+	return true
+}
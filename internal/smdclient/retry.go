@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package smdclient
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests to SMD that fail transiently (transport
+// errors and 408, 429, 500, 502, 503, and 504 responses) are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made, including the first.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the base delay before the first retry. It doubles
+	// with each subsequent retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts, including delays requested
+	// by SMD with Retry-After.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by clients created with
+// NewSmdClient.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// backoff returns how long to wait before the retry following the given
+// (1-based) attempt. The exponential delay is jittered by up to half of its
+// value so that clients don't retry in lockstep. If SMD sent a Retry-After
+// header in resp, it is honored instead (up to MaxBackoff).
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if d, ok := retryAfter(resp); ok {
+		if p.MaxBackoff > 0 && d > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+		return d
+	}
+
+	d := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	// Full delay minus up to 50% jitter
+	return d - rand.N(d/2+1)
+}
+
+// retryAfter parses the Retry-After header of resp, if any, in either its
+// delay-seconds or HTTP-date form.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
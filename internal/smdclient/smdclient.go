@@ -6,13 +6,14 @@
 package smdclient
 
 import (
-	"crypto/tls"
+	"context"
 	"crypto/x509"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"time"
 )
 
@@ -21,9 +22,26 @@ var (
 	defaultResponseHeaderTimeout = 120 * time.Second
 )
 
+const (
+	// DefaultTimeout is the overall timeout of each request attempt made by
+	// clients created with NewSmdClient, including reading the response body.
+	DefaultTimeout = 3 * time.Minute
+
+	// DefaultMaxIdleConnsPerHost is the number of idle keep-alive connections
+	// to SMD kept open by clients created with NewSmdClient.
+	DefaultMaxIdleConnsPerHost = 4
+)
+
 type SmdClient struct {
 	*http.Client
 	BaseURL *url.URL
+
+	// TokenSource, if set, supplies bearer tokens sent with each request
+	TokenSource TokenSource
+
+	// Retry controls how transient failures are retried. The zero value
+	// makes a single attempt.
+	Retry RetryPolicy
 }
 
 type EthernetInterface struct {
@@ -31,6 +49,7 @@ type EthernetInterface struct {
 	ComponentID string      `json:"ComponentID"`
 	Type        string      `json:"Type"`
 	Description string      `json:"Description"`
+	LastUpdate  string      `json:"LastUpdate,omitempty"`
 	IPAddresses []IPAddress `json:"IPAddresses"`
 }
 
@@ -40,20 +59,123 @@ type IPAddress struct {
 }
 
 type Component struct {
-	ID   string `json:"ID"`
-	NID  int64  `json:"NID"`
-	Type string `json:"Type"`
+	ID      string `json:"ID"`
+	NID     int64  `json:"NID"`
+	Type    string `json:"Type"`
+	State   string `json:"State,omitempty"`   // e.g. "Ready", "On", "Off", "Empty"
+	Flag    string `json:"Flag,omitempty"`    // e.g. "OK", "Warning", "Alert", "Locked"
+	Enabled *bool  `json:"Enabled,omitempty"` // nil if SMD didn't say, which it treats as enabled
+	Role    string `json:"Role,omitempty"`    // e.g. "Compute", "Service", "Management"
+	SubRole string `json:"SubRole,omitempty"` // e.g. "Master", "Worker", "Storage"
+	Arch    string `json:"Arch,omitempty"`    // e.g. "X86", "ARM", "UNKNOWN"
+	Class   string `json:"Class,omitempty"`   // e.g. "River", "Mountain", "Hill"
+}
+
+// IsEnabled returns true unless SMD reports the component as disabled.
+func (c Component) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Group is an SMD component group, e.g. "compute" or "login". A component can
+// be a member of any number of groups, except that it can only belong to one
+// of the groups sharing an ExclusiveGroup.
+type Group struct {
+	Label          string   `json:"label"`
+	Description    string   `json:"description,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	ExclusiveGroup string   `json:"exclusiveGroup,omitempty"`
+	Members        Members  `json:"members"`
+}
+
+// Partition is an SMD partition. A component can be a member of at most one
+// partition.
+type Partition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Members     Members  `json:"members"`
+}
+
+// Members lists the IDs of the components in a group or partition.
+type Members struct {
+	IDs []string `json:"ids"`
 }
 
+// Validators hold the HTTP cache validators returned by SMD for a resource so
+// that later requests for it can be made conditional.
+type Validators struct {
+	ETag         string
+	LastModified string
+}
+
+// IsZero returns true if no validators are set.
+func (v Validators) IsZero() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// NewSmdClient returns a client for the SMD at baseURL that reuses
+// connections, times out requests after DefaultTimeout, and retries transient
+// failures according to DefaultRetryPolicy.
 func NewSmdClient(baseURL *url.URL) *SmdClient {
 	s := &SmdClient{
 		BaseURL: baseURL,
-		Client:  &http.Client{},
+		Client: &http.Client{
+			Transport: newTransport(),
+			Timeout:   DefaultTimeout,
+		},
+		Retry: DefaultRetryPolicy,
 	}
 
 	return s
 }
 
+// newTransport returns a transport with the default settings: keep-alive
+// connections are pooled, and the TLS handshake and response headers time out
+// after defaultTlsHandshakeTimeout and defaultResponseHeaderTimeout.
+func newTransport() *http.Transport {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	tr.TLSHandshakeTimeout = defaultTlsHandshakeTimeout
+	tr.ResponseHeaderTimeout = defaultResponseHeaderTimeout
+	return tr
+}
+
+// SetTimeout sets the overall timeout of each request attempt, including
+// reading the response body. Zero means no timeout.
+func (sc *SmdClient) SetTimeout(d time.Duration) error {
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+	sc.Timeout = d
+	sc.syncTokenClient()
+	return nil
+}
+
+// SetKeepAlive enables or disables reuse of connections to SMD. When enabled,
+// up to maxIdleConnsPerHost idle connections are kept open (if
+// maxIdleConnsPerHost is positive; otherwise the current limit is kept).
+func (sc *SmdClient) SetKeepAlive(enabled bool, maxIdleConnsPerHost int) error {
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+
+	tr := sc.httpTransport()
+	tr.DisableKeepAlives = !enabled
+	if maxIdleConnsPerHost > 0 {
+		tr.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	}
+	sc.Transport = tr
+	sc.syncTokenClient()
+
+	return nil
+}
+
 func (sc *SmdClient) UseCACert(path string) error {
 	cacert, err := os.ReadFile(path)
 	if err != nil {
@@ -70,43 +192,225 @@ func (sc *SmdClient) UseCACert(path string) error {
 		return fmt.Errorf("SmdClient's HTTP client is nil")
 	}
 
-	(*sc).Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			RootCAs:            certPool,
-			InsecureSkipVerify: false,
-		},
-		DisableKeepAlives:     true,
-		TLSHandshakeTimeout:   defaultTlsHandshakeTimeout,
-		ResponseHeaderTimeout: defaultResponseHeaderTimeout,
+	// Keep any client certificate set by UseClientCert
+	tr := sc.httpTransport()
+	tr.TLSClientConfig.RootCAs = certPool
+	tr.TLSClientConfig.InsecureSkipVerify = false
+	(*sc).Transport = tr
+	sc.syncTokenClient()
+
+	return nil
+}
+
+// HTTPConfig holds the settings for requests to SMD, as configured in the
+// plugins. Unset (nil) fields keep the defaults set by NewSmdClient.
+type HTTPConfig struct {
+	Timeout      *time.Duration // overall timeout of each attempt; 0 disables
+	Retries      *int           // number of retries after the first attempt
+	RetryBackoff *time.Duration // initial backoff between attempts
+	KeepAlive    *bool          // whether connections are reused
+	MaxIdleConns *int           // idle keep-alive connections kept per host
+}
+
+// Validate checks that the settings in h are in range.
+func (h HTTPConfig) Validate() []error {
+	var errs []error
+	if h.Timeout != nil && *h.Timeout < 0 {
+		errs = append(errs, fmt.Errorf("smd_timeout must not be negative"))
+	}
+	if h.Retries != nil && *h.Retries < 0 {
+		errs = append(errs, fmt.Errorf("smd_retries must not be negative"))
+	}
+	if h.RetryBackoff != nil && *h.RetryBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("smd_retry_backoff must be positive"))
+	}
+	if h.MaxIdleConns != nil && *h.MaxIdleConns <= 0 {
+		errs = append(errs, fmt.Errorf("smd_max_idle_conns must be positive"))
+	}
+	if h.MaxIdleConns != nil && h.KeepAlive != nil && !*h.KeepAlive {
+		errs = append(errs, fmt.Errorf("smd_max_idle_conns has no effect with smd_keepalive disabled"))
+	}
+	return errs
+}
+
+// String returns a description of h suitable for logging.
+func (h HTTPConfig) String() string {
+	str := func(p any) string {
+		switch v := p.(type) {
+		case *time.Duration:
+			if v != nil {
+				return v.String()
+			}
+		case *int:
+			if v != nil {
+				return fmt.Sprint(*v)
+			}
+		case *bool:
+			if v != nil {
+				return fmt.Sprint(*v)
+			}
+		}
+		return "default"
+	}
+	return fmt.Sprintf("smd_timeout=%s smd_retries=%s smd_retry_backoff=%s smd_keepalive=%s smd_max_idle_conns=%s",
+		str(h.Timeout), str(h.Retries), str(h.RetryBackoff), str(h.KeepAlive), str(h.MaxIdleConns))
+}
+
+// UseHTTPConfig configures the client according to h.
+func (sc *SmdClient) UseHTTPConfig(h HTTPConfig) error {
+	if sc == nil {
+		return fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+	if errs := h.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	if h.Timeout != nil {
+		if err := sc.SetTimeout(*h.Timeout); err != nil {
+			return err
+		}
+	}
+	if h.Retries != nil {
+		sc.Retry.MaxAttempts = *h.Retries + 1
+	}
+	if h.RetryBackoff != nil {
+		sc.Retry.InitialBackoff = *h.RetryBackoff
+		if sc.Retry.MaxBackoff < *h.RetryBackoff {
+			sc.Retry.MaxBackoff = *h.RetryBackoff
+		}
+	}
+	if h.KeepAlive != nil || h.MaxIdleConns != nil {
+		enabled := h.KeepAlive == nil || *h.KeepAlive
+		maxIdle := 0
+		if h.MaxIdleConns != nil {
+			maxIdle = *h.MaxIdleConns
+		}
+		if err := sc.SetKeepAlive(enabled, maxIdle); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// APIGet performs a GET request on path and returns the response body. Non-2xx
+// responses are returned as an *HTTPError.
 func (sc *SmdClient) APIGet(path string) ([]byte, error) {
+	if sc == nil {
+		return nil, fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return nil, fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+
+	_, data, err := sc.get(sc.BaseURL.JoinPath(path), nil)
+	return data, err
+}
+
+// APIGetConditional performs a GET request on path with the query parameters
+// in query (which may be nil). If v is non-zero, its validators are sent as
+// If-None-Match and If-Modified-Since headers. If SMD responds with 304 Not
+// Modified, notModified is true, data is nil, and v is returned unchanged.
+// Otherwise, the response body is returned along with the validators from the
+// response headers. Non-2xx responses other than 304 are returned as an
+// *HTTPError.
+func (sc *SmdClient) APIGetConditional(path string, query url.Values, v Validators) (data []byte, newV Validators, notModified bool, err error) {
+	if sc == nil {
+		return nil, v, false, fmt.Errorf("SmdClient is nil")
+	}
+	if sc.Client == nil {
+		return nil, v, false, fmt.Errorf("SmdClient's HTTP client is nil")
+	}
+
 	endpoint := sc.BaseURL.JoinPath(path)
+	if len(query) > 0 {
+		endpoint.RawQuery = query.Encode()
+	}
+	header := make(http.Header)
+	if v.ETag != "" {
+		header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		header.Set("If-Modified-Since", v.LastModified)
+	}
+
+	resp, data, err := sc.get(endpoint, header, http.StatusNotModified)
+	if err != nil {
+		return nil, v, false, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, v, true, nil
+	}
+	newV = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	return data, newV, false, nil
+}
+
+// get performs a GET request on endpoint with the extra headers in header,
+// retrying transient failures according to sc.Retry, and a request whose
+// token was rejected once with a new token. It returns the response
+// (whose body has been closed) and body for 2xx responses and those with a
+// status in accept. Other statuses are returned as an *HTTPError.
+func (sc *SmdClient) get(endpoint *url.URL, header http.Header, accept ...int) (*http.Response, []byte, error) {
 	req, err := http.NewRequest("GET", endpoint.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
 	}
 
-	if sc == nil {
-		return nil, fmt.Errorf("SmdClient is nil")
+	attempts := max(sc.Retry.MaxAttempts, 1)
+	reauthorized := false
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(context.Background())
+		resp, data, err := sc.do(r, accept)
+		if err == nil {
+			return resp, data, nil
+		}
+		// A cached token may have been revoked, so retry once with a new
+		// one, without counting it as an attempt
+		if !reauthorized && sc.invalidateToken(r, err) {
+			reauthorized = true
+			attempt--
+			continue
+		}
+		if attempt >= attempts || !isRetryable(err) {
+			if attempt > 1 {
+				return nil, nil, fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return nil, nil, err
+		}
+		time.Sleep(sc.Retry.backoff(attempt, resp))
 	}
-	if sc.Client == nil {
-		return nil, fmt.Errorf("SmdClient's HTTP client is nil")
+}
+
+// do makes a single attempt at req. The response is returned alongside an
+// *HTTPError so that its headers (e.g. Retry-After) can be inspected.
+func (sc *SmdClient) do(req *http.Request, accept []int) (*http.Response, []byte, error) {
+	if err := sc.Authorize(req); err != nil {
+		return nil, nil, err
 	}
 
 	resp, err := sc.Client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute HTTP request: %w", err)
+		return nil, nil, fmt.Errorf("failed to execute HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode/100 == 2 || slices.Contains(accept, resp.StatusCode) {
+		return resp, data, nil
 	}
 
-	return data, nil
+	return resp, nil, NewHTTPError(resp, data)
 }
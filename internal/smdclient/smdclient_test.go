@@ -7,13 +7,16 @@ package smdclient
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 //==============================================================================
@@ -26,6 +29,10 @@ func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 	return f(r)
 }
 
+func ptr[T any](v T) *T {
+	return &v
+}
+
 type errorReader struct{}
 
 func (errorReader) Read(p []byte) (int, error) {
@@ -62,7 +69,20 @@ func TestNewSmdClient(t *testing.T) {
 				t.Errorf("BaseURL = %v, want %v", client.BaseURL, tt.baseURL)
 			}
 			if client.Client == nil {
-				t.Errorf("Client is nil, want non-nil http.Client")
+				t.Fatalf("Client is nil, want non-nil http.Client")
+			}
+			if client.Timeout != DefaultTimeout {
+				t.Errorf("Timeout = %v, want %v", client.Timeout, DefaultTimeout)
+			}
+			if client.Retry != DefaultRetryPolicy {
+				t.Errorf("Retry = %+v, want %+v", client.Retry, DefaultRetryPolicy)
+			}
+			tr, ok := client.Transport.(*http.Transport)
+			if !ok {
+				t.Fatalf("Transport type = %T, want *http.Transport", client.Transport)
+			}
+			if tr.DisableKeepAlives {
+				t.Errorf("DisableKeepAlives = true, want false")
 			}
 		})
 	}
@@ -125,8 +145,11 @@ func TestSmdClientUseCACert(t *testing.T) {
 				if tr.TLSClientConfig.InsecureSkipVerify {
 					t.Errorf("InsecureSkipVerify = true, want false")
 				}
-				if !tr.DisableKeepAlives {
-					t.Errorf("DisableKeepAlives = false, want true")
+				if tr.DisableKeepAlives {
+					t.Errorf("DisableKeepAlives = true, want false")
+				}
+				if tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+					t.Errorf("MaxIdleConnsPerHost = %d, want %d", tr.MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
 				}
 				if tr.TLSHandshakeTimeout != defaultTlsHandshakeTimeout {
 					t.Errorf("TLSHandshakeTimeout = %v, want %v", tr.TLSHandshakeTimeout, defaultTlsHandshakeTimeout)
@@ -227,6 +250,7 @@ func TestSmdClientAPIGet_SuccessAndStatusCodes(t *testing.T) {
 		name       string
 		statusCode int
 		body       string
+		wantErr    error
 	}{
 		{
 			name:       "status_200_ok",
@@ -234,9 +258,25 @@ func TestSmdClientAPIGet_SuccessAndStatusCodes(t *testing.T) {
 			body:       "ok",
 		},
 		{
-			name:       "status_500_still_reads_body",
+			name:       "status_204_no_content",
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "status_500_returns_server_error",
 			statusCode: http.StatusInternalServerError,
 			body:       "internal error",
+			wantErr:    ErrServer,
+		},
+		{
+			name:       "status_401_returns_unauthorized",
+			statusCode: http.StatusUnauthorized,
+			body:       "no token",
+			wantErr:    ErrUnauthorized,
+		},
+		{
+			name:       "status_404_returns_not_found",
+			statusCode: http.StatusNotFound,
+			wantErr:    ErrNotFound,
 		},
 	}
 
@@ -259,15 +299,31 @@ func TestSmdClientAPIGet_SuccessAndStatusCodes(t *testing.T) {
 			client := NewSmdClient(baseURL)
 			// Use test server's client
 			client.Client = srv.Client()
+			client.Retry = RetryPolicy{}
 
 			data, err := client.APIGet("/test/path")
-			if err != nil {
-				t.Fatalf("APIGet() unexpected error: %v", err)
-			}
-
 			if gotPath != "/test/path" {
 				t.Errorf("requested path = %q, want %q", gotPath, "/test/path")
 			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("APIGet() error = %v, want %v", err, tt.wantErr)
+				}
+				var httpErr *HTTPError
+				if !errors.As(err, &httpErr) {
+					t.Fatalf("APIGet() error type = %T, want *HTTPError", err)
+				}
+				if httpErr.StatusCode != tt.statusCode || httpErr.Body != tt.body {
+					t.Errorf("HTTPError = %+v, want status %d and body %q", httpErr, tt.statusCode, tt.body)
+				}
+				if data != nil {
+					t.Errorf("APIGet() body = %q, want nil", string(data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("APIGet() unexpected error: %v", err)
+			}
 			if string(data) != tt.body {
 				t.Errorf("APIGet() body = %q, want %q", string(data), tt.body)
 			}
@@ -339,6 +395,361 @@ func TestSmdClientAPIGet_ReadBodyError(t *testing.T) {
 	}
 }
 
+//==============================================================================
+// SmdClient.APIGetConditional
+//==============================================================================
+
+func TestSmdClientAPIGetConditional(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           url.Values
+		validators      Validators
+		wantQuery       string
+		wantNotModified bool
+		wantBody        string
+		wantErr         bool
+	}{
+		{
+			name:      "unconditional_returns_validators",
+			query:     url.Values{"NewerThan": []string{"2026-01-02T03:04:05Z"}},
+			wantQuery: "NewerThan=2026-01-02T03%3A04%3A05Z",
+			wantBody:  "body",
+		},
+		{
+			name:            "matching_etag_not_modified",
+			validators:      Validators{ETag: `"v1"`},
+			wantNotModified: true,
+		},
+		{
+			name:            "matching_last_modified_not_modified",
+			validators:      Validators{LastModified: "Fri, 02 Jan 2026 03:04:05 GMT"},
+			wantNotModified: true,
+		},
+		{
+			name:       "stale_etag_returns_body",
+			validators: Validators{ETag: `"v0"`},
+			wantBody:   "body",
+		},
+		{
+			name:    "error_status",
+			query:   url.Values{"fail": []string{"1"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotQuery string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotQuery = r.URL.RawQuery
+				if r.URL.Query().Get("fail") != "" {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Last-Modified", "Fri, 02 Jan 2026 03:04:05 GMT")
+				if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == "Fri, 02 Jan 2026 03:04:05 GMT" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				_, _ = w.Write([]byte("body"))
+			}))
+			defer srv.Close()
+
+			baseURL, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatalf("failed to parse test server URL: %v", err)
+			}
+			client := NewSmdClient(baseURL)
+			client.Client = srv.Client()
+			client.Retry = RetryPolicy{}
+
+			data, v, notModified, err := client.APIGetConditional("/test/path", tt.query, tt.validators)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("APIGetConditional() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantQuery != "" && gotQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", gotQuery, tt.wantQuery)
+			}
+			if notModified != tt.wantNotModified {
+				t.Errorf("notModified = %v, want %v", notModified, tt.wantNotModified)
+			}
+			if string(data) != tt.wantBody {
+				t.Errorf("body = %q, want %q", string(data), tt.wantBody)
+			}
+			if notModified {
+				if v != tt.validators {
+					t.Errorf("validators = %+v, want unchanged %+v", v, tt.validators)
+				}
+			} else if v.ETag != `"v1"` || v.LastModified != "Fri, 02 Jan 2026 03:04:05 GMT" {
+				t.Errorf("validators = %+v, want those from response", v)
+			}
+		})
+	}
+}
+
+//==============================================================================
+// Retries and timeouts
+//==============================================================================
+
+func TestSmdClientAPIGet_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // status of each attempt; the last repeats
+		maxAttempts  int
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "transient_then_success",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			maxAttempts:  3,
+			wantAttempts: 3,
+		},
+		{
+			name:         "gives_up_after_max_attempts",
+			statuses:     []int{http.StatusInternalServerError},
+			maxAttempts:  3,
+			wantAttempts: 3,
+			wantErr:      ErrServer,
+		},
+		{
+			name:         "not_found_not_retried",
+			statuses:     []int{http.StatusNotFound},
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantErr:      ErrNotFound,
+		},
+		{
+			name:         "unauthorized_not_retried",
+			statuses:     []int{http.StatusUnauthorized},
+			maxAttempts:  3,
+			wantAttempts: 1,
+			wantErr:      ErrUnauthorized,
+		},
+		{
+			name:         "rate_limited_retried",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			maxAttempts:  2,
+			wantAttempts: 2,
+		},
+		{
+			name:         "zero_policy_single_attempt",
+			statuses:     []int{http.StatusServiceUnavailable, http.StatusOK},
+			maxAttempts:  0,
+			wantAttempts: 1,
+			wantErr:      ErrServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte("body"))
+			}))
+			defer srv.Close()
+
+			baseURL, err := url.Parse(srv.URL)
+			if err != nil {
+				t.Fatalf("failed to parse test server URL: %v", err)
+			}
+			client := NewSmdClient(baseURL)
+			client.Retry = RetryPolicy{
+				MaxAttempts:    tt.maxAttempts,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     5 * time.Millisecond,
+			}
+
+			data, err := client.APIGet("/test/path")
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("APIGet() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("APIGet() unexpected error: %v", err)
+			}
+			if string(data) != "body" {
+				t.Errorf("APIGet() body = %q, want %q", string(data), "body")
+			}
+		})
+	}
+}
+
+func TestSmdClientAPIGet_RetriesTransportErrorAndReauthorizes(t *testing.T) {
+	baseURL, err := url.Parse("http://example.com")
+	if err != nil {
+		t.Fatalf("failed to parse URL: %v", err)
+	}
+
+	var auths []string
+	client := &SmdClient{
+		BaseURL: baseURL,
+		Client: &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				auths = append(auths, req.Header.Get("Authorization"))
+				if len(auths) == 1 {
+					return nil, fmt.Errorf("connection reset")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader("ok")),
+				}, nil
+			}),
+		},
+		TokenSource: staticTokenSource{token: "tok"},
+		Retry:       RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+	}
+
+	data, err := client.APIGet("/path")
+	if err != nil {
+		t.Fatalf("APIGet() unexpected error: %v", err)
+	}
+	if string(data) != "ok" {
+		t.Errorf("APIGet() body = %q, want %q", string(data), "ok")
+	}
+	if len(auths) != 2 || auths[0] != "Bearer tok" || auths[1] != "Bearer tok" {
+		t.Errorf("Authorization headers = %q, want two bearer tokens", auths)
+	}
+}
+
+func TestSmdClientAPIGet_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	baseURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL: %v", err)
+	}
+	client := NewSmdClient(baseURL)
+	client.Retry = RetryPolicy{}
+	if err := client.SetTimeout(50 * time.Millisecond); err != nil {
+		t.Fatalf("SetTimeout() error = %v", err)
+	}
+
+	start := time.Now()
+	if _, err := client.APIGet("/slow"); err == nil {
+		t.Fatalf("APIGet() error = nil, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("APIGet() took %v, want it to time out quickly", elapsed)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		5: time.Second,
+	} {
+		got := p.backoff(attempt, nil)
+		if got < want/2 || got > want {
+			t.Errorf("backoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	if got := (RetryPolicy{MaxBackoff: time.Minute}).backoff(1, resp); got != 2*time.Second {
+		t.Errorf("backoff() with Retry-After = %v, want 2s", got)
+	}
+	if got := p.backoff(1, resp); got != time.Second {
+		t.Errorf("backoff() with Retry-After over MaxBackoff = %v, want 1s", got)
+	}
+}
+
+//==============================================================================
+// SmdClient.UseHTTPConfig
+//==============================================================================
+
+func TestHTTPConfigValidate(t *testing.T) {
+	neg := -time.Second
+	zero := time.Duration(0)
+	negInt := -1
+	zeroInt := 0
+	no := false
+	tests := []struct {
+		name    string
+		cfg     HTTPConfig
+		wantErr bool
+	}{
+		{name: "empty", cfg: HTTPConfig{}},
+		{name: "zero_timeout_disables", cfg: HTTPConfig{Timeout: &zero, Retries: &zeroInt}},
+		{name: "negative_timeout", cfg: HTTPConfig{Timeout: &neg}, wantErr: true},
+		{name: "negative_retries", cfg: HTTPConfig{Retries: &negInt}, wantErr: true},
+		{name: "zero_backoff", cfg: HTTPConfig{RetryBackoff: &zero}, wantErr: true},
+		{name: "zero_max_idle", cfg: HTTPConfig{MaxIdleConns: &zeroInt}, wantErr: true},
+		{name: "max_idle_without_keepalive", cfg: HTTPConfig{KeepAlive: &no, MaxIdleConns: ptr(2)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := tt.cfg.Validate()
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSmdClientUseHTTPConfig(t *testing.T) {
+	client := NewSmdClient(nil)
+	cfg := HTTPConfig{
+		Timeout:      ptr(30 * time.Second),
+		Retries:      ptr(5),
+		RetryBackoff: ptr(20 * time.Second),
+		KeepAlive:    ptr(false),
+	}
+	if err := client.UseHTTPConfig(cfg); err != nil {
+		t.Fatalf("UseHTTPConfig() error = %v", err)
+	}
+	if client.Timeout != 30*time.Second {
+		t.Errorf("Timeout = %v, want 30s", client.Timeout)
+	}
+	if client.Retry.MaxAttempts != 6 {
+		t.Errorf("Retry.MaxAttempts = %d, want 6", client.Retry.MaxAttempts)
+	}
+	if client.Retry.InitialBackoff != 20*time.Second || client.Retry.MaxBackoff != 20*time.Second {
+		t.Errorf("Retry = %+v, want initial and max backoff of 20s", client.Retry)
+	}
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Transport type = %T, want *http.Transport", client.Transport)
+	}
+	if !tr.DisableKeepAlives {
+		t.Errorf("DisableKeepAlives = false, want true")
+	}
+
+	if err := client.UseHTTPConfig(HTTPConfig{MaxIdleConns: ptr(16)}); err != nil {
+		t.Fatalf("UseHTTPConfig() error = %v", err)
+	}
+	tr = client.Transport.(*http.Transport)
+	if tr.DisableKeepAlives || tr.MaxIdleConnsPerHost != 16 {
+		t.Errorf("DisableKeepAlives = %v, MaxIdleConnsPerHost = %d, want false and 16", tr.DisableKeepAlives, tr.MaxIdleConnsPerHost)
+	}
+
+	if err := client.UseHTTPConfig(HTTPConfig{Retries: ptr(-1)}); err == nil {
+		t.Errorf("UseHTTPConfig() error = nil, want error for invalid config")
+	}
+}
+
 //==============================================================================
 // Struct JSON behavior
 //==============================================================================
@@ -428,6 +839,7 @@ func TestEthernetInterfaceJSON(t *testing.T) {
 }
 
 func TestComponentJSON(t *testing.T) {
+	disabled := false
 	tests := []struct {
 		name string
 		json string
@@ -451,6 +863,22 @@ func TestComponentJSON(t *testing.T) {
 				Type: "Blade",
 			},
 		},
+		{
+			name: "state_and_role",
+			json: `{"ID":"x3000c0s1b0n0","NID":1,"Type":"Node","State":"Ready","Flag":"OK","Enabled":false,"Role":"Compute","SubRole":"Worker","Arch":"ARM","Class":"River"}`,
+			want: Component{
+				ID:      "x3000c0s1b0n0",
+				NID:     1,
+				Type:    "Node",
+				State:   "Ready",
+				Flag:    "OK",
+				Enabled: &disabled,
+				Role:    "Compute",
+				SubRole: "Worker",
+				Arch:    "ARM",
+				Class:   "River",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -469,6 +897,13 @@ func TestComponentJSON(t *testing.T) {
 			if got.Type != tt.want.Type {
 				t.Errorf("Type = %q, want %q", got.Type, tt.want.Type)
 			}
+			if got.State != tt.want.State || got.Flag != tt.want.Flag || got.Role != tt.want.Role ||
+				got.SubRole != tt.want.SubRole || got.Arch != tt.want.Arch || got.Class != tt.want.Class {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.IsEnabled() != tt.want.IsEnabled() {
+				t.Errorf("IsEnabled() = %v, want %v", got.IsEnabled(), tt.want.IsEnabled())
+			}
 		})
 	}
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 
 	"github.com/openchami/coresmd/internal/debug"
+	"github.com/openchami/coresmd/internal/iface"
 	"github.com/openchami/coresmd/internal/ipxe"
 	"github.com/openchami/coresmd/internal/subnet"
 	"github.com/openchami/coresmd/internal/version"
@@ -56,11 +57,15 @@ type Config struct {
 	ipv4End    *net.IP        // ipv4_end (legacy single pool)
 	scriptPath string         // script_path
 
+	// Bootloaders served to clients, matched before the built-in defaults
+	bootloaders ipxe.Bootloaders // bootloader
+
 	// Subnet-aware pools
 	subnetPools map[string]*SubnetPoolConfig // subnet_pool configurations
 
 	// Used, but not parse from configuration
-	ipv4Range uint32 // ipv4_range
+	ipv4Range uint32           // ipv4_range
+	bootTable ipxe.Bootloaders // bootloaders followed by the built-in defaults
 }
 
 // SubnetPoolConfig represents a pool configuration for a specific subnet
@@ -72,12 +77,16 @@ type SubnetPoolConfig struct {
 }
 
 func (c Config) String() string {
-	return fmt.Sprintf("ipv4_start=%s ipv4_end=%s ipv4_range=%d script_path=%s",
+	cfgStr := fmt.Sprintf("ipv4_start=%s ipv4_end=%s ipv4_range=%d script_path=%s",
 		c.ipv4Start,
 		c.ipv4End,
 		c.ipv4Range,
 		c.scriptPath,
 	)
+	for _, bl := range c.bootloaders {
+		cfgStr += fmt.Sprintf(" bootloader=%s", bl)
+	}
+	return cfgStr
 }
 
 const (
@@ -345,6 +354,13 @@ func parseConfig(argv ...string) (cfg Config, errs []error) {
 				EndIP:     endIP,
 				IPv4Range: ipv4Range,
 			}
+		case "bootloader":
+			bl, err := ipxe.ParseBootloader(opt[1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid bootloader: %q: %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.bootloaders = append(cfg.bootloaders, bl)
 		default:
 			errs = append(errs, fmt.Errorf("non-comment arg %d: unknown config key '%s' (skipping)", idx, opt[0]))
 			continue
@@ -411,6 +427,7 @@ func (c *Config) validate() (warns []string, errs []error) {
 		warns = append(warns, fmt.Sprintf("script_path unset, using default"))
 		c.scriptPath = defaultScriptPath
 	}
+	c.bootTable = c.bootloaders.WithDefaults()
 	return
 }
 
@@ -424,13 +441,20 @@ func (p *PluginState) Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool)
 	// Set root path to this server's IP
 	resp.Options.Update(dhcpv4.OptRootPath(resp.ServerIPAddr.String()))
 
+	// IPoIB clients are identified by the GUID in their client identifier
+	hwAddr := iface.ClientHWAddr(req)
+	if len(hwAddr) == 0 {
+		log.Errorf("unable to identify client: no hardware address or client identifier in request")
+		return nil, true
+	}
+
 	giaddr := req.GatewayIPAddr
-	record, ok := p.Recordsv4[req.ClientHWAddr.String()]
+	record, ok := p.Recordsv4[hwAddr.String()]
 	hostname := req.HostName()
 	cinfo := req.Options.Get(dhcpv4.OptionUserClassInformation)
 	if !ok {
 		// Allocating new address since there isn't one allocated
-		log.Printf("MAC address %s is new, leasing new IPv4 address (giaddr=%s)", req.ClientHWAddr.String(), giaddr)
+		log.Printf("MAC address %s is new, leasing new IPv4 address (giaddr=%s)", hwAddr.String(), giaddr)
 
 		// Select the appropriate allocator based on configuration
 		var allocator allocators.Allocator
@@ -450,7 +474,7 @@ func (p *PluginState) Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool)
 
 		ip, err := allocator.Allocate(net.IPNet{})
 		if err != nil {
-			log.Errorf("Could not allocate IP for MAC %s: %v", req.ClientHWAddr.String(), err)
+			log.Errorf("Could not allocate IP for MAC %s: %v", hwAddr.String(), err)
 			return nil, true
 		}
 		rec := Record{
@@ -458,23 +482,23 @@ func (p *PluginState) Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool)
 			expires:  int(time.Now().Add(p.LeaseTime).Unix()),
 			hostname: hostname,
 		}
-		err = p.saveIPAddress(req.ClientHWAddr, &rec)
+		err = p.saveIPAddress(hwAddr, &rec)
 		if err != nil {
-			log.Errorf("SaveIPAddress for MAC %s failed: %v", req.ClientHWAddr.String(), err)
+			log.Errorf("SaveIPAddress for MAC %s failed: %v", hwAddr.String(), err)
 		}
-		p.Recordsv4[req.ClientHWAddr.String()] = &rec
+		p.Recordsv4[hwAddr.String()] = &rec
 		record = &rec
 		resp.YourIPAddr = record.IP
 		resp.Options.Update(dhcpv4.OptIPAddressLeaseTime(p.LeaseTime.Round(time.Second)))
 		if p.useSubnetPools {
-			log.Infof("assigning %s to %s from subnet %s with a lease duration of %s", record.IP, req.ClientHWAddr.String(), cidr, p.LeaseTime)
+			log.Infof("assigning %s to %s from subnet %s with a lease duration of %s", record.IP, hwAddr.String(), cidr, p.LeaseTime)
 		} else {
-			log.Infof("assigning %s to %s with a lease duration of %s", record.IP, req.ClientHWAddr.String(), p.LeaseTime)
+			log.Infof("assigning %s to %s with a lease duration of %s", record.IP, hwAddr.String(), p.LeaseTime)
 		}
 
 		if string(cinfo) != "iPXE" {
 			// BOOT STAGE 1: Send iPXE bootloader over TFTP
-			resp, _ = ipxe.ServeIPXEBootloader(log, req, resp)
+			resp, _ = globalConfig.bootTable.Serve(log, req, resp)
 		}
 	} else {
 		if string(cinfo) == "iPXE" {
@@ -498,11 +522,11 @@ func (p *PluginState) Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool)
 				log.Errorf("failed to create new %s message: %s", dhcpv4.MessageTypeNak, err)
 				return resp, true
 			}
-			err = p.deleteIPAddress(req.ClientHWAddr)
+			err = p.deleteIPAddress(hwAddr)
 			if err != nil {
-				log.Errorf("DeleteIPAddress for MAC %s failed: %v", req.ClientHWAddr.String(), err)
+				log.Errorf("DeleteIPAddress for MAC %s failed: %v", hwAddr.String(), err)
 			}
-			delete(p.Recordsv4, req.ClientHWAddr.String())
+			delete(p.Recordsv4, hwAddr.String())
 
 			// Free the IP from the appropriate allocator
 			if p.useSubnetPools {
@@ -520,7 +544,7 @@ func (p *PluginState) Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool)
 					log.Warnf("unable to delete IP %s: %s", record.IP.String(), err)
 				}
 			}
-			log.Printf("MAC %s already exists with IP %s, sending %s to reinitiate DHCP handshake", req.ClientHWAddr.String(), record.IP, dhcpv4.MessageTypeNak)
+			log.Printf("MAC %s already exists with IP %s, sending %s to reinitiate DHCP handshake", hwAddr.String(), record.IP, dhcpv4.MessageTypeNak)
 		}
 	}
 
@@ -7,9 +7,17 @@ package bootloop
 
 import (
 	"net"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/coredhcp/coredhcp/plugins/allocators/bitmap"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+
+	"github.com/openchami/coresmd/internal/ipxe"
 )
 
 //==============================================================================
@@ -202,6 +210,26 @@ func TestParseConfig(t *testing.T) {
 			wantErrCount: 1,
 			wantErrSub:   []string{"ipv4_end: invalid ip address"},
 		},
+		{
+			name: "bootloader entries",
+			argv: []string{
+				"bootloader=arch:riscv64,file:ipxe-riscv64.efi",
+				"bootloader=arch:arm64_http,vendor_class:HTTPClient,file:'http://172.16.0.254/ipxe-arm64.efi'",
+			},
+			want: Config{
+				bootloaders: ipxe.Bootloaders{
+					{Arch: iana.EFI_RISCV64, File: "ipxe-riscv64.efi"},
+					{Arch: iana.EFI_ARM64_HTTP, VendorClass: "HTTPClient", File: "http://172.16.0.254/ipxe-arm64.efi"},
+				},
+			},
+		},
+		{
+			name:         "invalid bootloader",
+			argv:         []string{"bootloader=arch:x86_64,user_class:iPXE"},
+			want:         Config{},
+			wantErrCount: 1,
+			wantErrSub:   []string{"bootloader: invalid bootloader"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -281,6 +309,11 @@ func TestParseConfig(t *testing.T) {
 			if got.scriptPath != tt.want.scriptPath {
 				t.Errorf("parseConfig() scriptPath = %q, want %q", got.scriptPath, tt.want.scriptPath)
 			}
+
+			// Check bootloaders
+			if !slices.Equal(got.bootloaders, tt.want.bootloaders) {
+				t.Errorf("parseConfig() bootloaders = %v, want %v", got.bootloaders, tt.want.bootloaders)
+			}
 		})
 	}
 }
@@ -435,3 +468,58 @@ func TestConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler4_IPoIB(t *testing.T) {
+	allocator, err := bitmap.NewIPv4Allocator(net.IPv4(172, 16, 0, 10), net.IPv4(172, 16, 0, 20))
+	if err != nil {
+		t.Fatalf("NewIPv4Allocator() error = %v", err)
+	}
+	p := &PluginState{
+		Recordsv4: make(map[string]*Record),
+		LeaseTime: time.Hour,
+		allocator: allocator,
+	}
+	if err := p.registerBackingDB(filepath.Join(t.TempDir(), "leases.db")); err != nil {
+		t.Fatalf("registerBackingDB() error = %v", err)
+	}
+
+	// IPoIB clients send an empty chaddr and a client identifier (type 255,
+	// IAID, DUID-EN) ending in the GUID of their port. The user class skips
+	// serving a bootloader.
+	req, err := dhcpv4.New(dhcpv4.WithMessageType(dhcpv4.MessageTypeDiscover))
+	if err != nil {
+		t.Fatalf("dhcpv4.New() error = %v", err)
+	}
+	req.HWType = iana.HWTypeInfiniband
+	req.ClientHWAddr = nil
+	req.Options.Update(dhcpv4.OptClientIdentifier([]byte{
+		0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x02, 0xc9, 0x00,
+		0x98, 0x03, 0x9b, 0x03, 0x00, 0x12, 0x34, 0x56,
+	}))
+	req.Options.Update(dhcpv4.OptGeneric(dhcpv4.OptionUserClassInformation, []byte("iPXE")))
+	resp, err := dhcpv4.NewReplyFromRequest(req)
+	if err != nil {
+		t.Fatalf("NewReplyFromRequest() error = %v", err)
+	}
+
+	got, _ := p.Handler4(req, resp)
+	if got == nil {
+		t.Fatalf("Handler4() = nil, want a response")
+	}
+
+	const guid = "98:03:9b:03:00:12:34:56"
+	rec, ok := p.Recordsv4[guid]
+	if !ok {
+		t.Fatalf("no lease for GUID %s in %v", guid, p.Recordsv4)
+	}
+	if !got.YourIPAddr.Equal(rec.IP) {
+		t.Errorf("YourIPAddr = %s, want leased %s", got.YourIPAddr, rec.IP)
+	}
+	stored, err := loadRecords(p.leasedb)
+	if err != nil {
+		t.Fatalf("loadRecords() error = %v", err)
+	}
+	if _, ok := stored[guid]; !ok || len(stored) != 1 {
+		t.Errorf("stored leases = %v, want one for GUID %s", stored, guid)
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package coresmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/openchami/coresmd/internal/inventory"
+)
+
+const (
+	// metricsPath and inventoryPath are the paths metrics and the latest
+	// inventory report are served on by listenMetrics.
+	metricsPath   = "/metrics"
+	inventoryPath = "/inventory"
+)
+
+var (
+	// InventoryFindings is the number of inconsistencies in the SMD data found
+	// by the latest inventory check, by kind
+	InventoryFindings = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "coresmd",
+		Subsystem: "inventory",
+		Name:      "findings",
+		Help:      "Number of inconsistencies in the SMD data found by the latest inventory check, by kind.",
+	}, []string{"kind"})
+
+	// InventoryLastCheck is the time of the latest inventory check
+	InventoryLastCheck = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "coresmd",
+		Subsystem: "inventory",
+		Name:      "last_check_timestamp_seconds",
+		Help:      "Unix time of the latest inventory check of the SMD data.",
+	})
+)
+
+var (
+	invMutex  sync.Mutex
+	invLatest inventory.Report // latest inventory report
+
+	// inventoryCheckDelay is how long scheduleInventoryCheck waits before
+	// checking, so that a burst of cache updates (e.g. events) only causes
+	// one check
+	inventoryCheckDelay = 5 * time.Second
+	invPending          atomic.Bool // a check is scheduled
+
+	// The metrics listener is shared by setup4 and setup6
+	metricsOnce sync.Once
+	metricsErr  error
+)
+
+// scheduleInventoryCheck schedules checkInventory to run after
+// inventoryCheckDelay, unless a check is already scheduled. It is called
+// whenever the contents of the cache change.
+func scheduleInventoryCheck() {
+	if invPending.Swap(true) {
+		return
+	}
+	time.AfterFunc(inventoryCheckDelay, func() {
+		invPending.Store(false)
+		checkInventory()
+	})
+}
+
+// checkInventory checks the contents of the SMD cache for inconsistencies. New
+// findings are logged, the metrics are updated, and the report is written to
+// inventory_report if set.
+func checkInventory() {
+	smdCache.Mutex.RLock()
+	report := inventory.Check(smdCache, globalConfig.domain, globalConfig.rules)
+	smdCache.Mutex.RUnlock()
+
+	invMutex.Lock()
+	defer invMutex.Unlock()
+
+	for _, f := range report.NewSince(invLatest) {
+		log.WithField("kind", f.Kind).Warnf("inventory: %s", f.Message)
+	}
+	counts := make([]string, 0, len(inventory.Kinds))
+	for _, kind := range inventory.Kinds {
+		counts = append(counts, fmt.Sprintf("%s=%d", kind, report.Counts[kind]))
+		InventoryFindings.WithLabelValues(kind).Set(float64(report.Counts[kind]))
+	}
+	InventoryLastCheck.Set(float64(report.Time.Unix()))
+	log.Infof("inventory check of %d EthernetInterfaces and %d Components found %d problems (%s)",
+		report.EthernetInterfaces, report.Components, len(report.Findings), strings.Join(counts, " "))
+	invLatest = report
+
+	if globalConfig.invReport != "" {
+		if err := report.WriteFile(globalConfig.invReport); err != nil {
+			log.Errorf("failed to write inventory report: %v", err)
+		}
+	}
+}
+
+// inventoryHandler serves the latest inventory report as JSON.
+func inventoryHandler(w http.ResponseWriter, r *http.Request) {
+	invMutex.Lock()
+	data, err := json.Marshal(invLatest)
+	invMutex.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(data)
+}
+
+// startMetrics starts serving metrics and the inventory report at addr with
+// listenMetrics, unless that has already been done, and returns the error
+// listenMetrics returned, if any.
+func startMetrics(addr string) error {
+	metricsOnce.Do(func() {
+		_, metricsErr = listenMetrics(addr)
+	})
+	return metricsErr
+}
+
+// listenMetrics serves Prometheus metrics on metricsPath and the latest
+// inventory report on inventoryPath at addr in a new goroutine.
+func listenMetrics(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.Handler())
+	mux.HandleFunc(inventoryPath, inventoryHandler)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Infof("serving metrics on %s%s and inventory report on %s%s", ln.Addr(), metricsPath, ln.Addr(), inventoryPath)
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Errorf("metrics listener failed: %v", err)
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,69 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package coresmd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+func TestScheduleInventoryCheck(t *testing.T) {
+	oldCache, oldDelay := smdCache, inventoryCheckDelay
+	defer func() { smdCache, inventoryCheckDelay = oldCache, oldDelay }()
+	smdCache = &cache.Cache{
+		EthernetInterfaces: map[string]smdclient.EthernetInterface{
+			"de:ca:fc:0f:fe:e1": {MACAddress: "de:ca:fc:0f:fe:e1", ComponentID: "x3000c0s0b0n0"},
+		},
+		Components: map[string]smdclient.Component{},
+	}
+	inventoryCheckDelay = 50 * time.Millisecond
+
+	// A burst of updates causes a single check
+	for range 100 {
+		scheduleInventoryCheck()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	var first time.Time
+	for first.IsZero() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		invMutex.Lock()
+		first = invLatest.Time
+		invMutex.Unlock()
+	}
+	if first.IsZero() {
+		t.Fatal("timed out waiting for the inventory check")
+	}
+	time.Sleep(4 * inventoryCheckDelay)
+	invMutex.Lock()
+	defer invMutex.Unlock()
+	if !invLatest.Time.Equal(first) {
+		t.Fatalf("inventory checked again at %s after %s, want one check", invLatest.Time, first)
+	}
+	if invLatest.EthernetInterfaces != 1 || len(invLatest.Findings) != 2 {
+		t.Fatalf("invLatest = %+v, want 1 EthernetInterface with 2 findings", invLatest)
+	}
+}
+
+func TestStartMetrics(t *testing.T) {
+	// setup4 and setup6 both start the listener on the same address
+	for range 2 {
+		if err := startMetrics("127.0.0.1:0"); err != nil {
+			t.Fatalf("startMetrics() unexpected error: %v", err)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if _, err := listenMetrics(ln.Addr().String()); err == nil {
+		t.Fatal("listenMetrics() on an address in use succeeded, want error")
+	}
+}
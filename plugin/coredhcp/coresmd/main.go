@@ -6,9 +6,12 @@
 package coresmd
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -35,8 +38,20 @@ type Config struct {
 	// Parsed from configuration file
 	svcBaseURI    *url.URL              // svc_base_uri
 	ipxeBaseURI   *url.URL              // ipxe_base_uri
+	httpBootURI   *url.URL              // http_boot_uri
+	ipv6BootURI   *url.URL              // ipv6_boot_uri
+	bootloaders   ipxe.Bootloaders      // bootloader
 	caCert        string                // ca_cert
+	auth          smdclient.AuthConfig  // token_file, token_url, client_id, client_secret_file, token_scopes, client_cert, client_key
+	smdHTTP       smdclient.HTTPConfig  // smd_timeout, smd_retries, smd_retry_backoff, smd_keepalive, smd_max_idle_conns
 	cacheValid    *time.Duration        // cache_valid
+	fullResync    *time.Duration        // cache_full_resync
+	eventListen   string                // event_listen
+	eventToken    string                // event_token_file
+	eventFeed     string                // event_feed
+	cacheSnapshot string                // cache_snapshot
+	invReport     string                // inventory_report
+	metricsListen string                // metrics_listen
 	leaseTime     *time.Duration        // lease_time
 	singlePort    bool                  // single_port
 	tftpDir       string                // tftp_dir
@@ -45,14 +60,24 @@ type Config struct {
 	ruleLog       string                // rule_log
 	rules         []rule.Rule           // rule
 	subnetContext *subnet.SubnetContext // auto-built from rule subnet: match keys
+	bootTable     ipxe.Bootloaders      // bootloaders followed by the built-in defaults
 }
 
 func (c Config) String() string {
-	cfgStr := fmt.Sprintf("svc_base_uri=%s ipxe_base_uri=%s ca_cert=%s cache_valid=%s lease_time=%s single_port=%v tftp_dir=%s tftp_port=%d domain=%s rule_log=%s",
+	cfgStr := fmt.Sprintf("svc_base_uri=%s ipxe_base_uri=%s http_boot_uri=%s ipv6_boot_uri=%s ca_cert=%s cache_valid=%s cache_full_resync=%s event_listen=%s event_token_file=%s event_feed=%s cache_snapshot=%s inventory_report=%s metrics_listen=%s lease_time=%s single_port=%v tftp_dir=%s tftp_port=%d domain=%s rule_log=%s",
 		c.svcBaseURI,
 		c.ipxeBaseURI,
+		c.httpBootURI,
+		c.ipv6BootURI,
 		c.caCert,
 		c.cacheValid,
+		c.fullResync,
+		c.eventListen,
+		c.eventToken,
+		c.eventFeed,
+		c.cacheSnapshot,
+		c.invReport,
+		c.metricsListen,
 		c.leaseTime,
 		c.singlePort,
 		c.tftpDir,
@@ -60,6 +85,11 @@ func (c Config) String() string {
 		c.domain,
 		c.ruleLog,
 	)
+	cfgStr += " " + c.auth.String()
+	cfgStr += " " + c.smdHTTP.String()
+	for _, bl := range c.bootloaders {
+		cfgStr += fmt.Sprintf(" bootloader=%s", bl)
+	}
 	for _, rule := range c.rules {
 		cfgStr += fmt.Sprintf(" rule=%s", rule)
 	}
@@ -77,6 +107,10 @@ var (
 	smdCache     *cache.Cache
 	globalConfig Config
 	log          = logger.GetLogger("plugins/coresmd")
+
+	// Event sources of smdCache, see startEventSources
+	eventServer   *http.Server
+	stopEventFeed context.CancelFunc
 )
 
 var Plugin = plugins.Plugin{
@@ -104,6 +138,9 @@ func setup6(args ...string) (handler.Handler6, error) {
 	for _, warning := range warns {
 		log.Warn(warning)
 	}
+	if cfg.ipv6BootURI == nil {
+		log.Warn("ipv6_boot_uri unset, DHCPv6 clients will only be sent bootloaders set as absolute URLs by rules")
+	}
 	if len(errs) > 0 {
 		for _, err := range errs {
 			log.Error(err)
@@ -119,13 +156,32 @@ func setup6(args ...string) (handler.Handler6, error) {
 	if err := smdClient.UseCACert(cfg.caCert); err != nil {
 		return nil, fmt.Errorf("failed to set CA certificate: %w", err)
 	}
+	if err := smdClient.UseAuth(cfg.auth); err != nil {
+		return nil, fmt.Errorf("failed to configure SMD authentication: %w", err)
+	}
+	if err := smdClient.UseHTTPConfig(cfg.smdHTTP); err != nil {
+		return nil, fmt.Errorf("failed to configure SMD client: %w", err)
+	}
 
 	// Create cache and start fetching
 	var err error
 	if smdCache, err = cache.NewCache(log, cfg.cacheValid.String(), smdClient); err != nil {
 		return nil, fmt.Errorf("failed to create new cache: %w", err)
 	}
+	if cfg.fullResync != nil {
+		smdCache.FullResync = *cfg.fullResync
+	}
+	smdCache.SnapshotPath = cfg.cacheSnapshot
+	smdCache.OnUpdate = scheduleInventoryCheck
+	if cfg.metricsListen != "" {
+		if err := startMetrics(cfg.metricsListen); err != nil {
+			return nil, err
+		}
+	}
 	smdCache.RefreshLoop()
+	if err := startEventSources(cfg); err != nil {
+		return nil, err
+	}
 
 	// Start tftp server
 	log.Infof("starting TFTP server on port %d with directory %s", cfg.tftpPort, cfg.tftpDir)
@@ -172,13 +228,32 @@ func setup4(args ...string) (handler.Handler4, error) {
 	if err := smdClient.UseCACert(cfg.caCert); err != nil {
 		return nil, fmt.Errorf("failed to set CA certificate: %w", err)
 	}
+	if err := smdClient.UseAuth(cfg.auth); err != nil {
+		return nil, fmt.Errorf("failed to configure SMD authentication: %w", err)
+	}
+	if err := smdClient.UseHTTPConfig(cfg.smdHTTP); err != nil {
+		return nil, fmt.Errorf("failed to configure SMD client: %w", err)
+	}
 
 	// Create cache and start fetching
 	var err error
 	if smdCache, err = cache.NewCache(log, cfg.cacheValid.String(), smdClient); err != nil {
 		return nil, fmt.Errorf("failed to create new cache: %w", err)
 	}
+	if cfg.fullResync != nil {
+		smdCache.FullResync = *cfg.fullResync
+	}
+	smdCache.SnapshotPath = cfg.cacheSnapshot
+	smdCache.OnUpdate = scheduleInventoryCheck
+	if cfg.metricsListen != "" {
+		if err := startMetrics(cfg.metricsListen); err != nil {
+			return nil, err
+		}
+	}
 	smdCache.RefreshLoop()
+	if err := startEventSources(cfg); err != nil {
+		return nil, err
+	}
 
 	// Start tftp server
 	log.Infof("starting TFTP server on port %d with directory %s", cfg.tftpPort, cfg.tftpDir)
@@ -196,6 +271,49 @@ func setup4(args ...string) (handler.Handler4, error) {
 	return Handler4, nil
 }
 
+// startEventSources starts receiving change notifications for the SMD cache
+// from the webhook listener and/or event feed, if configured. Polling via
+// RefreshLoop continues regardless. Event sources started for a previous cache
+// (e.g. by setup4 when setup6 is called for a dual-stack config) are stopped
+// first.
+func startEventSources(cfg Config) error {
+	stopEventSources()
+
+	if cfg.eventListen != "" {
+		token, err := smdclient.NewFileTokenSource(cfg.eventToken)
+		if err != nil {
+			return fmt.Errorf("failed to read event webhook token: %w", err)
+		}
+		smdCache.EventToken = token
+		if eventServer, err = smdCache.ListenEvents(cfg.eventListen); err != nil {
+			return err
+		}
+	}
+	if cfg.eventFeed != "" {
+		var ctx context.Context
+		ctx, stopEventFeed = context.WithCancel(context.Background())
+		go smdCache.WatchEvents(ctx, cfg.eventFeed)
+	}
+	return nil
+}
+
+// stopEventSources stops the event sources started by startEventSources, if
+// any.
+func stopEventSources() {
+	if eventServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := eventServer.Shutdown(ctx); err != nil {
+			log.Warnf("failed to shut down cache event listener: %v", err)
+		}
+		eventServer = nil
+	}
+	if stopEventFeed != nil {
+		stopEventFeed()
+		stopEventFeed = nil
+	}
+}
+
 // parseConfig takes a variadic array of string arguments representing an array
 // of key=value pairs and parses them into a Config struct, returning it. If any
 // errors occur, they are gathered into errs, a slice of errors, so that they
@@ -254,12 +372,100 @@ func parseConfig(argv ...string) (cfg Config, errs []error) {
 			} else {
 				cfg.ipxeBaseURI = ipxeURI
 			}
+		case "http_boot_uri":
+			httpBootURI, err := url.Parse(strings.Trim(opt[1], `"'`))
+			if err == nil && httpBootURI.Scheme != "http" && httpBootURI.Scheme != "https" {
+				err = fmt.Errorf("scheme must be http or https")
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid URI '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.httpBootURI = httpBootURI
+		case "ipv6_boot_uri":
+			ipv6BootURI, err := url.Parse(strings.Trim(opt[1], `"'`))
+			if err == nil && ipv6BootURI.Scheme != "tftp" && ipv6BootURI.Scheme != "http" && ipv6BootURI.Scheme != "https" {
+				err = fmt.Errorf("scheme must be tftp, http, or https")
+			} else if err == nil && ipv6BootURI.Hostname() == "" {
+				err = fmt.Errorf("IPv6 address (in brackets) or hostname of boot server required")
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid URI '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.ipv6BootURI = ipv6BootURI
+		case "bootloader":
+			bl, err := ipxe.ParseBootloader(opt[1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid bootloader: %q: %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.bootloaders = append(cfg.bootloaders, bl)
 		case "ca_cert":
 			// Simply set if nonempty when trimmed. Checking happens later.
 			caCertPath := strings.Trim(opt[1], `"'`)
 			if caCertPath != "" {
 				cfg.caCert = caCertPath
 			}
+		case "token_file":
+			cfg.auth.TokenFile = strings.Trim(opt[1], `"'`)
+		case "token_url":
+			tokenURL := strings.Trim(opt[1], `"'`)
+			if u, err := url.Parse(tokenURL); err != nil || !u.IsAbs() {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid URI '%s' (skipping)", idx, opt[0], opt[1]))
+				continue
+			}
+			cfg.auth.TokenURL = tokenURL
+		case "client_id":
+			cfg.auth.ClientID = strings.Trim(opt[1], `"'`)
+		case "client_secret_file":
+			cfg.auth.ClientSecretFile = strings.Trim(opt[1], `"'`)
+		case "token_scopes":
+			cfg.auth.Scopes = nil
+			for _, scope := range strings.Split(strings.Trim(opt[1], `"'`), ",") {
+				if scope = strings.TrimSpace(scope); scope != "" {
+					cfg.auth.Scopes = append(cfg.auth.Scopes, scope)
+				}
+			}
+		case "client_cert":
+			cfg.auth.ClientCert = strings.Trim(opt[1], `"'`)
+		case "client_key":
+			cfg.auth.ClientKey = strings.Trim(opt[1], `"'`)
+		case "smd_timeout":
+			if timeout, err := time.ParseDuration(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid duration '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.smdHTTP.Timeout = &timeout
+			}
+		case "smd_retries":
+			if retries, err := strconv.Atoi(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid number '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.smdHTTP.Retries = &retries
+			}
+		case "smd_retry_backoff":
+			if backoff, err := time.ParseDuration(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid duration '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.smdHTTP.RetryBackoff = &backoff
+			}
+		case "smd_keepalive":
+			if keepAlive, err := strconv.ParseBool(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid value '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.smdHTTP.KeepAlive = &keepAlive
+			}
+		case "smd_max_idle_conns":
+			if maxIdle, err := strconv.Atoi(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid number '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.smdHTTP.MaxIdleConns = &maxIdle
+			}
 		case "cache_valid":
 			if cacheValid, err := time.ParseDuration(opt[1]); err != nil {
 				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid duration '%s' (skipping): %w", idx, opt[0], opt[1], err))
@@ -267,6 +473,49 @@ func parseConfig(argv ...string) (cfg Config, errs []error) {
 			} else {
 				cfg.cacheValid = &cacheValid
 			}
+		case "cache_full_resync":
+			if fullResync, err := time.ParseDuration(opt[1]); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid duration '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			} else {
+				cfg.fullResync = &fullResync
+			}
+		case "cache_snapshot":
+			cacheSnapshot := strings.Trim(opt[1], `"'`)
+			if cacheSnapshot != "" {
+				cfg.cacheSnapshot = cacheSnapshot
+			}
+		case "event_listen":
+			eventListen := strings.Trim(opt[1], `"'`)
+			if _, _, err := net.SplitHostPort(eventListen); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid listen address '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.eventListen = eventListen
+		case "event_token_file":
+			eventToken := strings.Trim(opt[1], `"'`)
+			if eventToken != "" {
+				cfg.eventToken = eventToken
+			}
+		case "event_feed":
+			eventFeed := strings.Trim(opt[1], `"'`)
+			if _, err := url.Parse(eventFeed); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid URI '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.eventFeed = eventFeed
+		case "inventory_report":
+			invReport := strings.Trim(opt[1], `"'`)
+			if invReport != "" {
+				cfg.invReport = invReport
+			}
+		case "metrics_listen":
+			metricsListen := strings.Trim(opt[1], `"'`)
+			if _, _, err := net.SplitHostPort(metricsListen); err != nil {
+				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid listen address '%s' (skipping): %w", idx, opt[0], opt[1], err))
+				continue
+			}
+			cfg.metricsListen = metricsListen
 		case "lease_time":
 			if leaseTime, err := time.ParseDuration(opt[1]); err != nil {
 				errs = append(errs, fmt.Errorf("non-comment arg %d: %s: invalid duration '%s' (skipping): %w", idx, opt[0], opt[1], err))
@@ -384,6 +633,8 @@ func (c *Config) validate() (warns []string, errs []error) {
 	if c.caCert == "" {
 		warns = append(warns, "ca_cert unset, TLS certificates will not be validated")
 	}
+	errs = append(errs, c.auth.Validate()...)
+	errs = append(errs, c.smdHTTP.Validate()...)
 	if c.cacheValid == nil {
 		warns = append(warns, fmt.Sprintf("cache_valid unset, defaulting to %s", cache.DefaultCacheValid))
 		duration, err := time.ParseDuration(cache.DefaultCacheValid)
@@ -393,6 +644,12 @@ func (c *Config) validate() (warns []string, errs []error) {
 			c.cacheValid = &duration
 		}
 	}
+	if c.eventListen != "" && c.eventToken == "" {
+		errs = append(errs, fmt.Errorf("event_listen requires event_token_file"))
+	}
+	if c.fullResync != nil && *c.fullResync > 0 && c.cacheValid != nil && *c.fullResync <= *c.cacheValid {
+		warns = append(warns, fmt.Sprintf("cache_full_resync (%s) is not greater than cache_valid (%s), every refresh will be a full refresh", c.fullResync, c.cacheValid))
+	}
 	if c.leaseTime == nil {
 		warns = append(warns, fmt.Sprintf("lease_time unset, defaulting to %s", defaultLeaseTime))
 		duration, err := time.ParseDuration(defaultLeaseTime)
@@ -416,6 +673,11 @@ func (c *Config) validate() (warns []string, errs []error) {
 	if c.domain == "" {
 		warns = append(warns, "domain unset, not configuring")
 	}
+	if c.ipv6BootURI != nil && c.ipv6BootURI.Scheme == "tftp" && c.ipv6BootURI.Port() == "" {
+		// Bootloaders are fetched from the TFTP server of this plugin
+		c.ipv6BootURI.Host = net.JoinHostPort(c.ipv6BootURI.Hostname(), strconv.Itoa(c.tftpPort))
+	}
+	c.bootTable = c.bootloaders.WithDefaults()
 	if strings.TrimSpace(c.ruleLog) == "" {
 		warns = append(warns, "rule_log unset, defaulting to info")
 		c.ruleLog = "info"
@@ -439,7 +701,7 @@ func Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool) {
 	defer smdCache.Mutex.RUnlock()
 
 	// STEP 1: Assign IP address and set standard DHCP options
-	hwAddr := req.ClientHWAddr.String()
+	hwAddr := iface.ClientKey(smdCache, req)
 	giaddr := req.GatewayIPAddr
 
 	// Use subnet-aware lookup if subnet context is configured
@@ -497,21 +759,115 @@ func Handler4(req, resp *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, bool) {
 	}).Info("DHCPv4 assignment")
 
 	// STEP 2: Send boot config
-	if cinfo := req.Options.Get(dhcpv4.OptionUserClassInformation); string(cinfo) != "iPXE" {
-		// BOOT STAGE 1: Send iPXE bootloader over TFTP
-		resp, _ = ipxe.ServeIPXEBootloader(log, req, resp)
-	} else {
-		// BOOT STAGE 2: Send URL to BSS boot script
-		bssURL := globalConfig.ipxeBaseURI.JoinPath("/boot/v1/bootscript")
-		bssURL.RawQuery = fmt.Sprintf("mac=%s", hwAddr)
-		resp.Options.Update(dhcpv4.OptBootFileName(bssURL.String()))
-	}
+	resp = serveBoot4(req, resp, ifaceInfo, rule.EvaluateBoot(ifaceInfo, globalConfig.rules))
 
 	debug.DebugResponse(log, resp)
 
 	return resp, true
 }
 
+// serveBoot4 sets the boot configuration in resp according to boot, the boot
+// settings of the rules matching the interface described by ifaceInfo.
+func serveBoot4(req, resp *dhcpv4.DHCPv4, ifaceInfo iface.IfaceInfo, boot rule.Boot) *dhcpv4.DHCPv4 {
+	if !boot.NetbootEnabled() {
+		log.Debugf("network boot disabled by rule for %s, not sending boot config", ifaceInfo.MAC)
+		return resp
+	}
+
+	// Set next server, from which bootloaders given as file names are fetched
+	if boot.NextServer != nil {
+		resp.ServerIPAddr = boot.NextServer
+		resp.Options.Update(dhcpv4.OptTFTPServerName(boot.NextServer.String()))
+	}
+
+	if cinfo := req.Options.Get(dhcpv4.OptionUserClassInformation); string(cinfo) != "iPXE" && ipxe.IsHTTPClient(req) {
+		// BOOT STAGE 1 (UEFI HTTP Boot): Send iPXE bootloader URL
+		resp, _ = globalConfig.bootTable.ServeHTTP(log, req, resp, boot.BootloaderFor(ipxe.ClientArch(req)), globalConfig.httpBootURI)
+	} else if string(cinfo) != "iPXE" {
+		// BOOT STAGE 1: Send iPXE bootloader over TFTP (or HTTP if the rule
+		// sets a URL)
+		if file := boot.BootloaderFor(ipxe.ClientArch(req)); file != "" {
+			resp.Options.Update(dhcpv4.OptBootFileName(file))
+		} else {
+			resp, _ = globalConfig.bootTable.Serve(log, req, resp)
+		}
+	} else {
+		// BOOT STAGE 2: Send URL to boot script
+		resp.Options.Update(dhcpv4.OptBootFileName(boot.Script(globalConfig.ipxeBaseURI, ifaceInfo.MAC)))
+	}
+
+	return resp
+}
+
+// serveBoot6 sets the boot configuration for the DHCPv6 client with hardware
+// address mac sending req in resp, according to the boot settings of the
+// rules matching the interface described by ifaceInfo.
+func serveBoot6(mac string, req, resp *dhcpv6.Message, ifaceInfo iface.IfaceInfo, boot rule.Boot) *dhcpv6.Message {
+	if !boot.NetbootEnabled() {
+		log.Debugf("network boot disabled by rule for %s, not sending boot config", ifaceInfo.MAC)
+		return resp
+	}
+
+	isIPXE := slices.ContainsFunc(req.Options.UserClasses(), func(uc []byte) bool { return string(uc) == "iPXE" })
+	if isIPXE {
+		// BOOT STAGE 2: Send URL to boot script
+		resp.UpdateOption(dhcpv6.OptBootFileURL(boot.Script(globalConfig.ipxeBaseURI, mac)))
+	} else if ipxe.IsHTTPClient6(req) {
+		// BOOT STAGE 1 (UEFI HTTP Boot): Send iPXE bootloader URL
+		resp, _ = globalConfig.bootTable.ServeHTTP6(log, mac, req, resp, boot.BootloaderFor(ipxe.ClientArch6(req)), globalConfig.httpBootURI)
+	} else {
+		// BOOT STAGE 1: Send iPXE bootloader URL for the client architecture,
+		// fetched from the IPv6 boot server
+		resp, _ = globalConfig.bootTable.Serve6(log, mac, req, resp, boot.BootloaderFor(ipxe.ClientArch6(req)), globalConfig.ipv6BootURI)
+	}
+
+	return resp
+}
+
+// relayLink6 returns the link-address and interface-id of the relay closest to
+// the client if req was relayed, and nil otherwise.
+func relayLink6(req dhcpv6.DHCPv6) (linkAddr net.IP, interfaceID []byte) {
+	for req != nil && req.IsRelay() {
+		relay, ok := req.(*dhcpv6.RelayMessage)
+		if !ok {
+			break
+		}
+		linkAddr = relay.LinkAddr
+		interfaceID = relay.Options.InterfaceID()
+		req = relay.Options.RelayMessage()
+	}
+	if linkAddr.IsUnspecified() {
+		// Relays set an unspecified link-address when they cannot tell the
+		// link of the client (RFC 8415 section 19.1.1)
+		linkAddr = nil
+	}
+	return linkAddr, interfaceID
+}
+
+// linkPrefixLen6 is the prefix length of the link an IPv6 address is assumed
+// to be on when there are no subnet rules to tell.
+const linkPrefixLen6 = 64
+
+// pickIPv6 returns the first IPv6 address in ips on the /64 of linkAddr, if
+// linkAddr is not nil and there is one, and the first IPv6 address in ips
+// otherwise. Returns nil if ips has no IPv6 addresses.
+func pickIPv6(ips []net.IP, linkAddr net.IP) net.IP {
+	var first net.IP
+	link := net.IPNet{IP: linkAddr.Mask(net.CIDRMask(linkPrefixLen6, 128)), Mask: net.CIDRMask(linkPrefixLen6, 128)}
+	for _, ip := range ips {
+		if ip.To4() != nil || ip.To16() == nil {
+			continue
+		}
+		if linkAddr == nil || link.Contains(ip) {
+			return ip
+		}
+		if first == nil {
+			first = ip
+		}
+	}
+	return first
+}
+
 func Handler6(req, resp dhcpv6.DHCPv6) (dhcpv6.DHCPv6, bool) {
 	log.Debugf("DHCPv6 HANDLER CALLED ON MESSAGE TYPE: req(%s), resp(%s)", req.Type(), resp.Type())
 
@@ -526,29 +882,60 @@ func Handler6(req, resp dhcpv6.DHCPv6) (dhcpv6.DHCPv6, bool) {
 		return resp, false
 	}
 
-	// STEP 1: Lookup interface info and assign IPv6 address
-	macStr := hwAddr.String()
-	ifaceInfo, err := iface.LookupMAC(log, macStr, smdCache)
+	// Unwrap relayed requests. The link-address of the relay closest to the
+	// client plays the role of giaddr in DHCPv4.
+	reqMsg, err := req.GetInnerMessage()
 	if err != nil {
-		log.Errorf("IP lookup failed: %v", err)
+		log.Errorf("Failed to get inner message from DHCPv6 request: %v", err)
 		return resp, false
 	}
+	linkAddr, interfaceID := relayLink6(req)
 
-	// Find an IPv6 address from the IP list
-	var assignedIPv6 net.IP
-	for _, ip := range ifaceInfo.IPList {
-		if ip.To4() == nil && ip.To16() != nil {
-			// This is an IPv6 address
-			assignedIPv6 = ip
-			break
+	// STEP 1: Lookup interface info and assign IPv6 address
+	macStr := hwAddr.String()
+
+	// Subnet rules only select addresses if one of them contains the
+	// link-address. Others, such as IPv4 subnet rules in a dual-stack
+	// configuration, cannot tell the link of the client.
+	subnetLink := linkAddr
+	if sc := globalConfig.subnetContext; sc == nil || sc.IsEmpty() || linkAddr == nil {
+		subnetLink = nil
+	} else if _, _, err := sc.FindSubnetForIP(linkAddr); err != nil {
+		log.Debugf("link_addr %s not in any configured subnet: %v", linkAddr, err)
+		subnetLink = nil
+	}
+
+	// Use subnet-aware lookup if subnet context is configured
+	var ifaceInfo iface.IfaceInfo
+	if globalConfig.subnetContext != nil && !globalConfig.subnetContext.IsEmpty() {
+		ifaceInfo, err = iface.LookupMACWithSubnet(log, macStr, subnetLink, smdCache, globalConfig.subnetContext)
+		if err != nil {
+			log.Errorf("subnet-aware IP lookup failed for MAC %s (link_addr=%s): %v", macStr, linkAddr, err)
+			return resp, false
+		}
+	} else {
+		ifaceInfo, err = iface.LookupMAC(log, macStr, smdCache)
+		if err != nil {
+			log.Errorf("IP lookup failed: %v", err)
+			return resp, false
 		}
 	}
 
+	// Find an IPv6 address from the IP list. Without a subnet rule to select
+	// it, prefer an address on the link of the relay, if any.
+	var onLink net.IP
+	if subnetLink == nil {
+		onLink = linkAddr
+	}
+	assignedIPv6 := pickIPv6(ifaceInfo.IPList, onLink)
 	if assignedIPv6 == nil {
 		log.Errorf("No IPv6 address found for MAC %s", macStr)
 		return resp, false
 	}
 
+	// Rules match subnets against the first IP, so make it the assigned one
+	ifaceInfo.IPList = append([]net.IP{assignedIPv6}, slices.DeleteFunc(slices.Clone(ifaceInfo.IPList), assignedIPv6.Equal)...)
+
 	// Get the message and modify it
 	msg, ok := resp.(*dhcpv6.Message)
 	if !ok {
@@ -565,27 +952,24 @@ func Handler6(req, resp dhcpv6.DHCPv6) (dhcpv6.DHCPv6, bool) {
 	}
 
 	// Add IANA (Identity Association for Non-temporary Addresses) with the IPv6 address
-	reqMsg, ok := req.(*dhcpv6.Message)
-	if ok {
-		// Get IAID from request if present
-		if iana := reqMsg.Options.OneIANA(); iana != nil {
-			// Create IANA with the assigned address
-			ianaOpt := &dhcpv6.OptIANA{
-				IaId: iana.IaId,
-				T1:   time.Duration(globalConfig.leaseTime.Seconds()/2) * time.Second,
-				T2:   time.Duration(globalConfig.leaseTime.Seconds()*3/4) * time.Second,
-				Options: dhcpv6.IdentityOptions{
-					Options: []dhcpv6.Option{
-						&dhcpv6.OptIAAddress{
-							IPv6Addr:          assignedIPv6,
-							PreferredLifetime: *globalConfig.leaseTime,
-							ValidLifetime:     *globalConfig.leaseTime,
-						},
+	// Get IAID from request if present
+	if iana := reqMsg.Options.OneIANA(); iana != nil {
+		// Create IANA with the assigned address
+		ianaOpt := &dhcpv6.OptIANA{
+			IaId: iana.IaId,
+			T1:   time.Duration(globalConfig.leaseTime.Seconds()/2) * time.Second,
+			T2:   time.Duration(globalConfig.leaseTime.Seconds()*3/4) * time.Second,
+			Options: dhcpv6.IdentityOptions{
+				Options: []dhcpv6.Option{
+					&dhcpv6.OptIAAddress{
+						IPv6Addr:          assignedIPv6,
+						PreferredLifetime: *globalConfig.leaseTime,
+						ValidLifetime:     *globalConfig.leaseTime,
 					},
 				},
-			}
-			msg.UpdateOption(ianaOpt)
+			},
 		}
+		msg.UpdateOption(ianaOpt)
 	}
 
 	// Log assignment
@@ -596,40 +980,14 @@ func Handler6(req, resp dhcpv6.DHCPv6) (dhcpv6.DHCPv6, bool) {
 		"comp_ips":          ifaceInfo.IPList,
 		"comp_mac":          ifaceInfo.MAC,
 		"assigned_ipv6":     assignedIPv6,
+		"link_addr":         linkAddr,
+		"interface_id":      string(interfaceID),
 		"assigned_hostname": resp.GetOption(dhcpv6.OptionFQDN),
 		"lease_duration":    globalConfig.leaseTime,
 	}).Info("DHCPv6 assignment")
 
 	// STEP 2: Send boot config for iPXE
-	if reqMsg, ok := req.(*dhcpv6.Message); ok {
-		if uc := reqMsg.GetOneOption(dhcpv6.OptionUserClass); uc != nil {
-			if userClass, ok := uc.(*dhcpv6.OptUserClass); ok {
-				// Check if this is iPXE
-				isPXE := false
-				for _, data := range userClass.UserClasses {
-					if string(data) == "iPXE" {
-						isPXE = true
-						break
-					}
-				}
-
-				if isPXE {
-					// BOOT STAGE 2: Send URL to BSS boot script
-					bssURL := globalConfig.ipxeBaseURI.JoinPath("/boot/v1/bootscript")
-					bssURL.RawQuery = fmt.Sprintf("mac=%s", macStr)
-					msg.UpdateOption(dhcpv6.OptBootFileURL(bssURL.String()))
-				} else {
-					// BOOT STAGE 1: Send iPXE bootloader URL
-					// For DHCPv6, we need to provide the bootfile URL
-					// Get server ID from response message
-					if serverID := msg.GetOneOption(dhcpv6.OptionServerID); serverID != nil {
-						tftpURL := fmt.Sprintf("tftp://[%s]:%d/ipxe.efi", serverID, globalConfig.tftpPort)
-						msg.UpdateOption(dhcpv6.OptBootFileURL(tftpURL))
-					}
-				}
-			}
-		}
-	}
+	msg = serveBoot6(macStr, reqMsg, msg, ifaceInfo, rule.EvaluateBoot(ifaceInfo, globalConfig.rules))
 
 	return msg, true
 }
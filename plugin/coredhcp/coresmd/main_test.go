@@ -6,13 +6,22 @@
 package coresmd
 
 import (
+	"net"
 	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+
 	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/ipxe"
 	"github.com/openchami/coresmd/internal/rule"
+	"github.com/openchami/coresmd/internal/smdclient"
+	"github.com/openchami/coresmd/internal/subnet"
 	"github.com/openchami/coresmd/internal/tftp"
 )
 
@@ -264,6 +273,631 @@ func TestParseConfig_SubnetWithRules(t *testing.T) {
 	}
 }
 
+func TestParseConfig_CacheFullResync(t *testing.T) {
+	base := []string{
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"cache_valid=30s",
+	}
+
+	cfg, errs := parseConfig(append(base, "cache_full_resync=15m")...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if cfg.fullResync == nil || *cfg.fullResync != 15*time.Minute {
+		t.Fatalf("fullResync=%v want %s", cfg.fullResync, 15*time.Minute)
+	}
+	if !strings.Contains(cfg.String(), "cache_full_resync=15m0s") {
+		t.Fatalf("Config.String() missing cache_full_resync in %q", cfg.String())
+	}
+
+	_, errs = parseConfig(append(base, "cache_full_resync=often")...)
+	if len(errs) != 1 {
+		t.Fatalf("parseConfig() with invalid duration: errs=%v, want 1 error", errs)
+	}
+
+	// A resync interval not exceeding cache_valid makes incremental
+	// refreshes pointless and should produce a warning.
+	cfg, _ = parseConfig(append(base, "cache_full_resync=10s")...)
+	warns, errs := cfg.validate()
+	if len(errs) != 0 {
+		t.Fatalf("validate() errs=%v", errs)
+	}
+	found := false
+	for _, w := range warns {
+		if strings.Contains(w, "cache_full_resync") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("validate() expected cache_full_resync warning, got %v", warns)
+	}
+}
+
+func TestParseConfig_EventSources(t *testing.T) {
+	base := []string{
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+	}
+
+	cfg, errs := parseConfig(append(base, "event_listen=:8090", "event_token_file=/etc/coresmd/event-token", "event_feed=/hsm/v2/events")...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if cfg.eventListen != ":8090" {
+		t.Fatalf("eventListen=%q want %q", cfg.eventListen, ":8090")
+	}
+	if cfg.eventToken != "/etc/coresmd/event-token" {
+		t.Fatalf("eventToken=%q want %q", cfg.eventToken, "/etc/coresmd/event-token")
+	}
+	if _, errs := cfg.validate(); len(errs) != 0 {
+		t.Fatalf("validate() unexpected errors: %v", errs)
+	}
+	if cfg.eventFeed != "/hsm/v2/events" {
+		t.Fatalf("eventFeed=%q want %q", cfg.eventFeed, "/hsm/v2/events")
+	}
+
+	cfg, errs = parseConfig(append(base, "event_listen=8090")...)
+	if len(errs) != 1 {
+		t.Fatalf("parseConfig() with invalid listen address: errs=%v, want 1 error", errs)
+	}
+	if cfg.eventListen != "" {
+		t.Fatalf("eventListen=%q want empty after invalid value", cfg.eventListen)
+	}
+
+	cfg, _ = parseConfig(append(base, "event_listen=:8090")...)
+	if _, errs := cfg.validate(); len(errs) != 1 {
+		t.Fatalf("validate() without event_token_file: errs=%v, want 1 error", errs)
+	}
+}
+
+func TestParseConfig_CacheSnapshot(t *testing.T) {
+	cfg, errs := parseConfig(
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"cache_snapshot='/var/lib/coresmd/cache.json'",
+	)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if cfg.cacheSnapshot != "/var/lib/coresmd/cache.json" {
+		t.Fatalf("cacheSnapshot=%q want %q", cfg.cacheSnapshot, "/var/lib/coresmd/cache.json")
+	}
+	if !strings.Contains(cfg.String(), "cache_snapshot=/var/lib/coresmd/cache.json") {
+		t.Fatalf("Config.String() missing cache_snapshot in %q", cfg.String())
+	}
+}
+
+func TestParseConfig_HTTPBootURI(t *testing.T) {
+	cfg, errs := parseConfig(
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"http_boot_uri='http://172.16.0.254:8080/boot'",
+	)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if cfg.httpBootURI == nil || cfg.httpBootURI.String() != "http://172.16.0.254:8080/boot" {
+		t.Fatalf("httpBootURI=%v want http://172.16.0.254:8080/boot", cfg.httpBootURI)
+	}
+
+	cfg, errs = parseConfig("http_boot_uri=tftp://172.16.0.254/boot")
+	if len(errs) != 1 {
+		t.Fatalf("parseConfig() with tftp scheme: errs=%v, want 1 error", errs)
+	}
+	if cfg.httpBootURI != nil {
+		t.Fatalf("httpBootURI=%v want nil after invalid value", cfg.httpBootURI)
+	}
+}
+
+func TestParseConfig_IPv6BootURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{"tftp_default_port", []string{"ipv6_boot_uri=tftp://[fd00:100::254]"}, "tftp://[fd00:100::254]:69", false},
+		{"tftp_tftp_port", []string{"ipv6_boot_uri=tftp://[fd00:100::254]", "tftp_port=1069"}, "tftp://[fd00:100::254]:1069", false},
+		{"tftp_explicit_port", []string{"ipv6_boot_uri=tftp://boot.example.test:6969", "tftp_port=1069"}, "tftp://boot.example.test:6969", false},
+		{"http", []string{"ipv6_boot_uri='http://[fd00:100::254]:8080/boot'"}, "http://[fd00:100::254]:8080/boot", false},
+		{"bad_scheme", []string{"ipv6_boot_uri=ftp://[fd00:100::254]"}, "", true},
+		{"no_host", []string{"ipv6_boot_uri=tftp:///boot"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, errs := parseConfig(tt.args...)
+			if (len(errs) != 0) != tt.wantErr {
+				t.Fatalf("parseConfig() errs=%v, wantErr=%v", errs, tt.wantErr)
+			}
+			cfg.validate()
+			var got string
+			if cfg.ipv6BootURI != nil {
+				got = cfg.ipv6BootURI.String()
+			}
+			if got != tt.want {
+				t.Fatalf("ipv6BootURI=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfig_Inventory(t *testing.T) {
+	cfg, errs := parseConfig("inventory_report='/var/lib/coresmd/inventory.json'", "metrics_listen=127.0.0.1:9101")
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() errs=%v", errs)
+	}
+	if cfg.invReport != "/var/lib/coresmd/inventory.json" || cfg.metricsListen != "127.0.0.1:9101" {
+		t.Fatalf("invReport=%q metricsListen=%q", cfg.invReport, cfg.metricsListen)
+	}
+
+	if cfg, errs = parseConfig("metrics_listen=9101"); len(errs) != 1 || cfg.metricsListen != "" {
+		t.Fatalf("parseConfig(metrics_listen=9101) errs=%v metricsListen=%q, want 1 error and no address", errs, cfg.metricsListen)
+	}
+}
+
+func TestParseConfig_Bootloaders(t *testing.T) {
+	cfg, errs := parseConfig(
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"bootloader=arch:riscv64,file:ipxe-riscv64.efi",
+		"bootloader=arch:x86_64,user_class:custom,file:custom.efi",
+		"bootloader=arch:sparc,file:boot.efi",
+		"bootloader=arch:x86_64",
+	)
+	if len(errs) != 2 {
+		t.Fatalf("parseConfig() errs=%v, want 2 errors", errs)
+	}
+	if len(cfg.bootloaders) != 2 {
+		t.Fatalf("bootloaders=%v want 2 entries", cfg.bootloaders)
+	}
+	if !strings.Contains(cfg.String(), "bootloader=arch:x86_64,user_class:custom,file:custom.efi") {
+		t.Fatalf("Config.String() missing bootloader in %q", cfg.String())
+	}
+
+	cfg.validate()
+	if got, want := len(cfg.bootTable), 2+len(ipxe.DefaultBootloaders); got != want {
+		t.Fatalf("bootTable has %d entries, want %d", got, want)
+	}
+	if file, _ := cfg.bootTable.Lookup(iana.EFI_X86_64, []byte("custom"), nil); file != "custom.efi" {
+		t.Fatalf("bootloader for user class custom=%q want custom.efi", file)
+	}
+	if file, _ := cfg.bootTable.Lookup(iana.EFI_X86_64, nil, nil); file != "ipxe-x86_64.efi" {
+		t.Fatalf("default bootloader=%q want ipxe-x86_64.efi", file)
+	}
+}
+
+func TestParseConfig_Auth(t *testing.T) {
+	base := []string{
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"ca_cert=/etc/pki/ca.pem",
+	}
+
+	cfg, errs := parseConfig(append(base,
+		"token_url=https://auth.example.test/oauth2/token",
+		"client_id=coresmd",
+		"client_secret_file=/run/secrets/coresmd",
+		"token_scopes=smd.read,smd.events",
+		"client_cert=/etc/coresmd/client.crt",
+		"client_key=/etc/coresmd/client.key",
+	)...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if cfg.auth.TokenURL != "https://auth.example.test/oauth2/token" || cfg.auth.ClientID != "coresmd" || cfg.auth.ClientSecretFile != "/run/secrets/coresmd" {
+		t.Fatalf("auth=%+v", cfg.auth)
+	}
+	if len(cfg.auth.Scopes) != 2 || cfg.auth.Scopes[0] != "smd.read" || cfg.auth.Scopes[1] != "smd.events" {
+		t.Fatalf("auth.Scopes=%v", cfg.auth.Scopes)
+	}
+	if cfg.auth.ClientCert != "/etc/coresmd/client.crt" || cfg.auth.ClientKey != "/etc/coresmd/client.key" {
+		t.Fatalf("auth client cert=%q key=%q", cfg.auth.ClientCert, cfg.auth.ClientKey)
+	}
+	if _, errs := cfg.validate(); len(errs) != 0 {
+		t.Fatalf("validate() errs=%v", errs)
+	}
+
+	// Conflicting and incomplete settings are fatal
+	cfg, errs = parseConfig(append(base, "token_file=/run/secrets/token", "token_url=https://auth.example.test/token")...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if _, errs := cfg.validate(); len(errs) == 0 {
+		t.Fatalf("validate() expected errors for token_file with incomplete token_url")
+	}
+
+	_, errs = parseConfig(append(base, "token_url=not a url")...)
+	if len(errs) != 1 {
+		t.Fatalf("parseConfig() with invalid token_url: errs=%v, want 1 error", errs)
+	}
+}
+
+func TestParseConfig_SMDHTTP(t *testing.T) {
+	base := []string{
+		"svc_base_uri=https://svc.example.test",
+		"ipxe_base_uri=https://ipxe.example.test",
+		"ca_cert=/etc/pki/ca.pem",
+	}
+
+	cfg, errs := parseConfig(append(base,
+		"smd_timeout=30s",
+		"smd_retries=5",
+		"smd_retry_backoff=2s",
+		"smd_keepalive=true",
+		"smd_max_idle_conns=8",
+	)...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	h := cfg.smdHTTP
+	if h.Timeout == nil || *h.Timeout != 30*time.Second {
+		t.Fatalf("smd_timeout=%v, want 30s", h.Timeout)
+	}
+	if h.Retries == nil || *h.Retries != 5 {
+		t.Fatalf("smd_retries=%v, want 5", h.Retries)
+	}
+	if h.RetryBackoff == nil || *h.RetryBackoff != 2*time.Second {
+		t.Fatalf("smd_retry_backoff=%v, want 2s", h.RetryBackoff)
+	}
+	if h.KeepAlive == nil || !*h.KeepAlive || h.MaxIdleConns == nil || *h.MaxIdleConns != 8 {
+		t.Fatalf("smd_keepalive=%v smd_max_idle_conns=%v, want true and 8", h.KeepAlive, h.MaxIdleConns)
+	}
+	if _, errs := cfg.validate(); len(errs) != 0 {
+		t.Fatalf("validate() errs=%v", errs)
+	}
+	if !strings.Contains(cfg.String(), "smd_retries=5") {
+		t.Errorf("String() = %q, want it to include smd_retries", cfg.String())
+	}
+
+	// Unparseable values are skipped
+	_, errs = parseConfig(append(base, "smd_timeout=soon", "smd_retries=many", "smd_keepalive=maybe")...)
+	if len(errs) != 3 {
+		t.Fatalf("parseConfig() with invalid values: errs=%v, want 3 errors", errs)
+	}
+
+	// Out of range values are fatal
+	cfg, errs = parseConfig(append(base, "smd_retries=-1")...)
+	if len(errs) != 0 {
+		t.Fatalf("parseConfig() unexpected errors: %v", errs)
+	}
+	if _, errs := cfg.validate(); len(errs) == 0 {
+		t.Fatalf("validate() expected error for negative smd_retries")
+	}
+}
+
+func TestServeBoot4(t *testing.T) {
+	ipxeURI, _ := url.Parse("https://ipxe.example.test")
+	httpBootURI, _ := url.Parse("http://172.16.0.254:8080/boot")
+	oldCfg := globalConfig
+	riscv, err := ipxe.ParseBootloader("arch:riscv64,file:ipxe-riscv64.efi")
+	if err != nil {
+		t.Fatalf("ParseBootloader: %v", err)
+	}
+	globalConfig = Config{ipxeBaseURI: ipxeURI, httpBootURI: httpBootURI, bootloaders: ipxe.Bootloaders{riscv}}
+	globalConfig.validate()
+	t.Cleanup(func() { globalConfig = oldCfg })
+
+	mac := net.HardwareAddr{0xde, 0xca, 0xfc, 0x0f, 0xfe, 0xe1}
+	mkReq := func(arch iana.Arch, ipxeClient bool) *dhcpv4.DHCPv4 {
+		req := &dhcpv4.DHCPv4{ClientHWAddr: mac, Options: dhcpv4.Options{}}
+		req.Options.Update(dhcpv4.OptClientArch(arch))
+		if ipxeClient {
+			req.Options.Update(dhcpv4.OptUserClass("iPXE"))
+		}
+		return req
+	}
+	mkHTTPReq := func(arch iana.Arch) *dhcpv4.DHCPv4 {
+		req := mkReq(arch, false)
+		req.Options.Update(dhcpv4.OptClassIdentifier("HTTPClient:Arch:00016:UNDI:003001"))
+		return req
+	}
+	mkBoot := func(s string) rule.Boot {
+		r, err := rule.ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		return r.Action.Boot
+	}
+	ii := iface.IfaceInfo{MAC: mac.String()}
+
+	tests := []struct {
+		name           string
+		req            *dhcpv4.DHCPv4
+		boot           rule.Boot
+		wantBootfile   string
+		wantNextServer string
+	}{
+		{"default_bootloader", mkReq(iana.EFI_X86_64, false), rule.Boot{}, "ipxe-x86_64.efi", ""},
+		{"default_script", mkReq(iana.EFI_X86_64, true), rule.Boot{}, "https://ipxe.example.test/boot/v1/bootscript?mac=de:ca:fc:0f:fe:e1", ""},
+		{"table_bootloader", mkReq(iana.EFI_RISCV64, false), rule.Boot{}, "ipxe-riscv64.efi", ""},
+		{"rule_bootloader", mkReq(iana.EFI_ARM64, false), mkBoot("bootloader:arm64=custom.efi,next_server:172.16.0.254"), "custom.efi", "172.16.0.254"},
+		{"rule_bootloader_other_arch", mkReq(iana.EFI_X86_64, false), mkBoot("bootloader:arm64=custom.efi"), "ipxe-x86_64.efi", ""},
+		{"rule_script", mkReq(iana.EFI_X86_64, true), mkBoot("boot_script:http://172.16.0.254/boot.ipxe"), "http://172.16.0.254/boot.ipxe", ""},
+		{"netboot_disabled", mkReq(iana.EFI_X86_64, false), mkBoot("netboot:false,next_server:172.16.0.254"), "", ""},
+		{"http_boot", mkHTTPReq(iana.EFI_X86_64_HTTP), rule.Boot{}, "http://172.16.0.254:8080/boot/ipxe-x86_64.efi", ""},
+		{"http_boot_rule_file", mkHTTPReq(iana.EFI_X86_64_HTTP), mkBoot("bootloader:16=uki.efi"), "http://172.16.0.254:8080/boot/uki.efi", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := serveBoot4(tt.req, &dhcpv4.DHCPv4{Options: dhcpv4.Options{}}, ii, tt.boot)
+			if got := string(resp.Options.Get(dhcpv4.OptionBootfileName)); got != tt.wantBootfile {
+				t.Fatalf("bootfile=%q want %q", got, tt.wantBootfile)
+			}
+			if got := string(resp.Options.Get(dhcpv4.OptionTFTPServerName)); got != tt.wantNextServer {
+				t.Fatalf("option 66=%q want %q", got, tt.wantNextServer)
+			}
+			if ipxe.IsHTTPClient(tt.req) && string(resp.Options.Get(dhcpv4.OptionClassIdentifier)) != ipxe.HTTPClientClass {
+				t.Fatalf("vendor class not echoed to HTTP boot client")
+			}
+			if tt.wantNextServer != "" && resp.ServerIPAddr.String() != tt.wantNextServer {
+				t.Fatalf("siaddr=%s want %s", resp.ServerIPAddr, tt.wantNextServer)
+			}
+		})
+	}
+}
+
+func TestServeBoot6(t *testing.T) {
+	ipxeURI, _ := url.Parse("https://ipxe.example.test")
+	httpBootURI, _ := url.Parse("http://[fd00::254]:8080/boot")
+	ipv6BootURI, _ := url.Parse("tftp://[fd00::254]")
+	oldCfg := globalConfig
+	globalConfig = Config{ipxeBaseURI: ipxeURI, httpBootURI: httpBootURI, ipv6BootURI: ipv6BootURI}
+	globalConfig.validate()
+	t.Cleanup(func() { globalConfig = oldCfg })
+
+	mac := "de:ca:fc:0f:fe:e1"
+	mkReq := func(arch iana.Arch, opts ...dhcpv6.Option) *dhcpv6.Message {
+		req, err := dhcpv6.NewMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddOption(dhcpv6.OptClientArchType(arch))
+		for _, opt := range opts {
+			req.AddOption(opt)
+		}
+		return req
+	}
+	ipxeClass := &dhcpv6.OptUserClass{UserClasses: [][]byte{[]byte("iPXE")}}
+	httpClass := &dhcpv6.OptVendorClass{EnterpriseNumber: 343, Data: [][]byte{[]byte("HTTPClient:Arch:00016:UNDI:003001")}}
+	mkBoot := func(s string) rule.Boot {
+		r, err := rule.ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		return r.Action.Boot
+	}
+	ii := iface.IfaceInfo{MAC: mac}
+
+	tests := []struct {
+		name string
+		req  *dhcpv6.Message
+		boot rule.Boot
+		want string
+	}{
+		{"x86_64", mkReq(iana.EFI_X86_64), rule.Boot{}, "tftp://[fd00::254]:69/ipxe-x86_64.efi"},
+		{"arm64", mkReq(iana.EFI_ARM64), rule.Boot{}, "tftp://[fd00::254]:69/ipxe-arm64.efi"},
+		{"unknown_arch", mkReq(iana.EFI_RISCV64), rule.Boot{}, ""},
+		{"rule_bootloader", mkReq(iana.EFI_ARM64), mkBoot("bootloader:arm64=custom.efi"), "tftp://[fd00::254]:69/custom.efi"},
+		{"http_boot", mkReq(iana.EFI_X86_64_HTTP, httpClass), rule.Boot{}, "http://[fd00::254]:8080/boot/ipxe-x86_64.efi"},
+		{"ipxe_script", mkReq(iana.EFI_X86_64, ipxeClass), rule.Boot{}, "https://ipxe.example.test/boot/v1/bootscript?mac=de:ca:fc:0f:fe:e1"},
+		{"netboot_disabled", mkReq(iana.EFI_X86_64), mkBoot("netboot:false"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := dhcpv6.NewMessage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp = serveBoot6(mac, tt.req, resp, ii, tt.boot)
+			if got := resp.Options.BootFileURL(); got != tt.want {
+				t.Fatalf("bootfile URL=%q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler6_Relayed(t *testing.T) {
+	mac := net.HardwareAddr{0xde, 0xca, 0xfc, 0x0f, 0xfe, 0xe1}
+	oldCache, oldCfg := smdCache, globalConfig
+	t.Cleanup(func() { smdCache, globalConfig = oldCache, oldCfg })
+	smdCache = &cache.Cache{
+		EthernetInterfaces: map[string]smdclient.EthernetInterface{
+			mac.String(): {
+				MACAddress:  mac.String(),
+				ComponentID: "x3000c0s0b0n0",
+				IPAddresses: []smdclient.IPAddress{{IPAddress: "fd00:1::10"}, {IPAddress: "fd00:2::10"}},
+			},
+		},
+		Components: map[string]smdclient.Component{
+			"x3000c0s0b0n0": {ID: "x3000c0s0b0n0", NID: 1, Type: "Node"},
+		},
+	}
+
+	var rules []rule.Rule
+	sc := subnet.NewSubnetContext()
+	for _, s := range []string{"subnet:fd00:1::/64,hostname:mgmt{04d}", "subnet:fd00:2::/64,hostname:hsn{04d}"} {
+		r, err := rule.ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		rules = append(rules, r)
+		if err := sc.AddSubnetCIDROnly(r.Match.Subnets[0].String()); err != nil {
+			t.Fatalf("AddSubnetCIDROnly: %v", err)
+		}
+	}
+	leaseTime := time.Hour
+	globalConfig = Config{leaseTime: &leaseTime, domain: "example.test", rules: rules, subnetContext: sc}
+	globalConfig.validate()
+
+	tests := []struct {
+		name     string
+		linkAddr net.IP
+		wantIP   string
+		wantFQDN string
+	}{
+		{"direct", nil, "fd00:1::10", "mgmt0001.example.test"},
+		{"relayed_first_subnet", net.ParseIP("fd00:1::1"), "fd00:1::10", "mgmt0001.example.test"},
+		{"relayed_second_subnet", net.ParseIP("fd00:2::1"), "fd00:2::10", "hsn0001.example.test"},
+		{"unspecified_link_addr", net.IPv6unspecified, "fd00:1::10", "mgmt0001.example.test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solicit, err := dhcpv6.NewSolicit(mac)
+			if err != nil {
+				t.Fatalf("NewSolicit: %v", err)
+			}
+			resp, err := dhcpv6.NewAdvertiseFromSolicit(solicit)
+			if err != nil {
+				t.Fatalf("NewAdvertiseFromSolicit: %v", err)
+			}
+			var req dhcpv6.DHCPv6 = solicit
+			if tt.linkAddr != nil {
+				if req, err = dhcpv6.EncapsulateRelay(solicit, dhcpv6.MessageTypeRelayForward, tt.linkAddr, net.ParseIP("fe80::1")); err != nil {
+					t.Fatalf("EncapsulateRelay: %v", err)
+				}
+			}
+
+			got, ok := Handler6(req, resp)
+			if !ok || got == nil {
+				t.Fatalf("Handler6() = %v, %v, want a response", got, ok)
+			}
+			msg := got.(*dhcpv6.Message)
+			iana := msg.Options.OneIANA()
+			if iana == nil || iana.Options.OneAddress() == nil {
+				t.Fatalf("no IA_NA address in response")
+			}
+			if ip := iana.Options.OneAddress().IPv6Addr.String(); ip != tt.wantIP {
+				t.Fatalf("assigned %s want %s", ip, tt.wantIP)
+			}
+			if fqdn := msg.Options.FQDN(); fqdn == nil || strings.Join(fqdn.DomainName.Labels, ".") != tt.wantFQDN {
+				t.Fatalf("FQDN=%v want %s", fqdn, tt.wantFQDN)
+			}
+		})
+	}
+}
+
+func TestHandler6_RelayedNoSubnetRules(t *testing.T) {
+	mac := net.HardwareAddr{0xde, 0xca, 0xfc, 0x0f, 0xfe, 0xe1}
+	oldCache, oldCfg := smdCache, globalConfig
+	t.Cleanup(func() { smdCache, globalConfig = oldCache, oldCfg })
+	smdCache = &cache.Cache{
+		EthernetInterfaces: map[string]smdclient.EthernetInterface{
+			mac.String(): {
+				MACAddress:  mac.String(),
+				ComponentID: "x3000c0s0b0n0",
+				IPAddresses: []smdclient.IPAddress{{IPAddress: "172.16.0.10"}, {IPAddress: "fd00:1::10"}, {IPAddress: "fd00:2::10"}},
+			},
+		},
+		Components: map[string]smdclient.Component{
+			"x3000c0s0b0n0": {ID: "x3000c0s0b0n0", NID: 1, Type: "Node"},
+		},
+	}
+	leaseTime := time.Hour
+	globalConfig = Config{leaseTime: &leaseTime}
+	globalConfig.validate()
+
+	// Without subnet rules, the address on the /64 of the relay's
+	// link-address is assigned
+	tests := []struct {
+		name     string
+		linkAddr net.IP
+		wantIP   string
+	}{
+		{"direct", nil, "fd00:1::10"},
+		{"relayed_first_link", net.ParseIP("fd00:1::1"), "fd00:1::10"},
+		{"relayed_second_link", net.ParseIP("fd00:2::1"), "fd00:2::10"},
+		{"relayed_unknown_link", net.ParseIP("fd00:3::1"), "fd00:1::10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			solicit, err := dhcpv6.NewSolicit(mac)
+			if err != nil {
+				t.Fatalf("NewSolicit: %v", err)
+			}
+			resp, err := dhcpv6.NewAdvertiseFromSolicit(solicit)
+			if err != nil {
+				t.Fatalf("NewAdvertiseFromSolicit: %v", err)
+			}
+			var req dhcpv6.DHCPv6 = solicit
+			if tt.linkAddr != nil {
+				if req, err = dhcpv6.EncapsulateRelay(solicit, dhcpv6.MessageTypeRelayForward, tt.linkAddr, net.ParseIP("fe80::1")); err != nil {
+					t.Fatalf("EncapsulateRelay: %v", err)
+				}
+			}
+
+			got, ok := Handler6(req, resp)
+			if !ok || got == nil {
+				t.Fatalf("Handler6() = %v, %v, want a response", got, ok)
+			}
+			iana := got.(*dhcpv6.Message).Options.OneIANA()
+			if iana == nil || iana.Options.OneAddress() == nil {
+				t.Fatalf("no IA_NA address in response")
+			}
+			if ip := iana.Options.OneAddress().IPv6Addr.String(); ip != tt.wantIP {
+				t.Fatalf("assigned %s want %s", ip, tt.wantIP)
+			}
+		})
+	}
+}
+
+func TestHandler6_RelayedIPv4SubnetRules(t *testing.T) {
+	mac := net.HardwareAddr{0xde, 0xca, 0xfc, 0x0f, 0xfe, 0xe1}
+	oldCache, oldCfg := smdCache, globalConfig
+	t.Cleanup(func() { smdCache, globalConfig = oldCache, oldCfg })
+	smdCache = &cache.Cache{
+		EthernetInterfaces: map[string]smdclient.EthernetInterface{
+			mac.String(): {
+				MACAddress:  mac.String(),
+				ComponentID: "x3000c0s0b0n0",
+				IPAddresses: []smdclient.IPAddress{{IPAddress: "172.16.0.10"}, {IPAddress: "fd00:1::10"}, {IPAddress: "fd00:2::10"}},
+			},
+		},
+		Components: map[string]smdclient.Component{
+			"x3000c0s0b0n0": {ID: "x3000c0s0b0n0", NID: 1, Type: "Node"},
+		},
+	}
+
+	r, err := rule.ParseRule("subnet:172.16.0.0/24,hostname:nid{04d}")
+	if err != nil {
+		t.Fatalf("ParseRule: %v", err)
+	}
+	sc := subnet.NewSubnetContext()
+	if err := sc.AddSubnetCIDROnly(r.Match.Subnets[0].String()); err != nil {
+		t.Fatalf("AddSubnetCIDROnly: %v", err)
+	}
+	leaseTime := time.Hour
+	globalConfig = Config{leaseTime: &leaseTime, rules: []rule.Rule{r}, subnetContext: sc}
+	globalConfig.validate()
+
+	// An IPv4 subnet rule cannot contain the relay's link-address, so the
+	// address on its /64 is assigned
+	solicit, err := dhcpv6.NewSolicit(mac)
+	if err != nil {
+		t.Fatalf("NewSolicit: %v", err)
+	}
+	resp, err := dhcpv6.NewAdvertiseFromSolicit(solicit)
+	if err != nil {
+		t.Fatalf("NewAdvertiseFromSolicit: %v", err)
+	}
+	req, err := dhcpv6.EncapsulateRelay(solicit, dhcpv6.MessageTypeRelayForward, net.ParseIP("fd00:2::1"), net.ParseIP("fe80::1"))
+	if err != nil {
+		t.Fatalf("EncapsulateRelay: %v", err)
+	}
+
+	got, ok := Handler6(req, resp)
+	if !ok || got == nil {
+		t.Fatalf("Handler6() = %v, %v, want a response", got, ok)
+	}
+	iana := got.(*dhcpv6.Message).Options.OneIANA()
+	if iana == nil || iana.Options.OneAddress() == nil {
+		t.Fatalf("no IA_NA address in response")
+	}
+	if ip := iana.Options.OneAddress().IPv6Addr.String(); ip != "fd00:2::10" {
+		t.Fatalf("assigned %s want fd00:2::10", ip)
+	}
+}
+
 func TestSetup6_InvalidConfigFails(t *testing.T) {
 	if Plugin.Setup6 == nil {
 		t.Fatal("Plugin.Setup6 is nil")
@@ -0,0 +1,48 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package coresmd
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// CacheFullRefreshes and CacheIncrementalRefreshes are the number of
+	// successful full and incremental refreshes of the SMD cache, exported as
+	// coresmd_cache_refreshes_total{kind}
+	CacheFullRefreshes = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   "coresmd",
+		Subsystem:   "cache",
+		Name:        "refreshes_total",
+		Help:        "Counter of successful SMD cache refreshes by kind (full or incremental).",
+		ConstLabels: prometheus.Labels{"kind": "full"},
+	}, func() float64 {
+		full, _ := cacheRefreshes()
+		return float64(full)
+	})
+	CacheIncrementalRefreshes = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Namespace:   "coresmd",
+		Subsystem:   "cache",
+		Name:        "refreshes_total",
+		Help:        "Counter of successful SMD cache refreshes by kind (full or incremental).",
+		ConstLabels: prometheus.Labels{"kind": "incremental"},
+	}, func() float64 {
+		_, incremental := cacheRefreshes()
+		return float64(incremental)
+	})
+)
+
+// cacheRefreshes returns the number of successful full and incremental
+// refreshes of the SMD cache.
+func cacheRefreshes() (full, incremental uint64) {
+	c := smdCache
+	if c == nil {
+		return 0, 0
+	}
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	return c.FullRefreshes, c.IncrementalRefreshes
+}
@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package coresmd
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/openchami/coresmd/internal/cache"
+)
+
+func TestCacheRefreshesMetric(t *testing.T) {
+	oldCache := smdCache
+	defer func() { smdCache = oldCache }()
+	smdCache = &cache.Cache{FullRefreshes: 2, IncrementalRefreshes: 5}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() unexpected error: %v", err)
+	}
+	got := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "coresmd_cache_refreshes_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "kind" {
+					got[l.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if got["full"] != 2 || got["incremental"] != 5 || len(got) != 2 {
+		t.Fatalf("coresmd_cache_refreshes_total = %v, want full=2 incremental=5", got)
+	}
+}
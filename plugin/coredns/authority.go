@@ -0,0 +1,212 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Default TTL of records served by the plugin, and SOA timers used when they
+// can't be derived from the cache refresh interval.
+const (
+	recordTTL  = 60
+	soaRefresh = 3600
+	soaExpire  = 86400
+)
+
+// ttl returns the TTL of records of type rrtype in the zone: the TTL
+// configured for the type, or else the zone's default TTL (configured for
+// dns.TypeNone), or else recordTTL.
+func (z Zone) ttl(rrtype uint16) uint32 {
+	if ttl, ok := z.TTLs[rrtype]; ok {
+		return ttl
+	}
+	if ttl, ok := z.TTLs[dns.TypeNone]; ok {
+		return ttl
+	}
+	return recordTTL
+}
+
+// origin returns the zone apex as a fully qualified, lowercase name.
+func (z Zone) origin() string {
+	return dns.Fqdn(strings.ToLower(z.Name))
+}
+
+// nameservers returns the fully qualified names of the zone's name servers.
+func (z Zone) nameservers() []string {
+	if len(z.NS) == 0 {
+		return []string{"ns." + z.origin()}
+	}
+	ns := make([]string, 0, len(z.NS))
+	for _, n := range z.NS {
+		ns = append(ns, dns.Fqdn(n))
+	}
+	return ns
+}
+
+// soa returns the SOA record of the zone with the given serial. Secondaries
+// are asked to check for changes as often as the cache is refreshed (every
+// refresh, if nonzero), and to retry twice as often. The SOA minimum, which
+// resolvers use as TTL of negative answers, is the zone's default TTL.
+func (z Zone) soa(serial uint32, refresh time.Duration) *dns.SOA {
+	mbox := "hostmaster." + z.origin()
+	if z.Hostmaster != "" {
+		mbox = dns.Fqdn(z.Hostmaster)
+	}
+	refreshSecs := uint32(soaRefresh)
+	if refresh > 0 {
+		refreshSecs = max(uint32(refresh.Round(time.Second)/time.Second), 1)
+	}
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: z.origin(), Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: z.ttl(dns.TypeSOA)},
+		Ns:      z.nameservers()[0],
+		Mbox:    mbox,
+		Serial:  serial,
+		Refresh: refreshSecs,
+		Retry:   max(refreshSecs/2, 1),
+		Expire:  max(soaExpire, 2*refreshSecs),
+		Minttl:  z.ttl(dns.TypeNone),
+	}
+}
+
+// ns returns the NS records of the zone.
+func (z Zone) ns() []dns.RR {
+	var rrs []dns.RR
+	for _, n := range z.nameservers() {
+		rrs = append(rrs, &dns.NS{
+			Hdr: dns.RR_Header{Name: z.origin(), Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: z.ttl(dns.TypeNS)},
+			Ns:  n,
+		})
+	}
+	return rrs
+}
+
+// addressRecords returns A and/or AAAA records named name for the given
+// addresses, depending on qtype (A, AAAA, or ANY for both).
+func addressRecords(name string, qtype uint16, ips []net.IP) []dns.RR {
+	var rrs []dns.RR
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if qtype == dns.TypeA || qtype == dns.TypeANY {
+				rrs = append(rrs, &dns.A{
+					Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: recordTTL},
+					A:   ip4,
+				})
+			}
+		} else if qtype == dns.TypeAAAA || qtype == dns.TypeANY {
+			rrs = append(rrs, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: recordTTL},
+				AAAA: ip,
+			})
+		}
+	}
+	return rrs
+}
+
+// ptrRecord returns a PTR record named name pointing to target.
+func ptrRecord(name, target string) dns.RR {
+	return &dns.PTR{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: recordTTL},
+		Ptr: dns.Fqdn(target),
+	}
+}
+
+// cnameRecord returns a CNAME record named name pointing to target.
+func cnameRecord(name, target string) dns.RR {
+	return &dns.CNAME{
+		Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: recordTTL},
+		Target: dns.Fqdn(target),
+	}
+}
+
+// srvRecord returns a SRV record named name for a service on port of target.
+func srvRecord(name string, port uint16, target string) dns.RR {
+	return &dns.SRV{
+		Hdr:    dns.RR_Header{Name: name, Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: recordTTL},
+		Port:   port,
+		Target: dns.Fqdn(target),
+	}
+}
+
+// txtRecord returns a TXT record named name holding txt.
+func txtRecord(name string, txt []string) dns.RR {
+	return &dns.TXT{
+		Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: recordTTL},
+		Txt: txt,
+	}
+}
+
+// glue returns address records for those of the zone's name servers that are
+// published by the plugin.
+func (z Zone) glue(idx *recordIndex) []dns.RR {
+	var rrs []dns.RR
+	for _, n := range z.nameservers() {
+		rrs = append(rrs, addressRecords(n, dns.TypeANY, idx.lookup(n))...)
+	}
+	return rrs
+}
+
+// refreshInterval returns how often the cache is refreshed, or 0 if unknown.
+func (p *Plugin) refreshInterval() time.Duration {
+	if p.cache == nil {
+		return 0
+	}
+	return p.cache.Duration
+}
+
+// untilRefresh returns the number of seconds until the cache is next
+// refreshed, rounded up and at least 1, and whether it is known.
+func (p *Plugin) untilRefresh() (uint32, bool) {
+	if p.cache == nil {
+		return 0, false
+	}
+	p.cache.Mutex.RLock()
+	last, interval := p.cache.LastUpdated, p.cache.Duration
+	p.cache.Mutex.RUnlock()
+	if last.IsZero() || interval <= 0 {
+		return 0, false
+	}
+
+	remaining := time.Until(last.Add(interval))
+	secs := (remaining + time.Second - 1) / time.Second
+	return uint32(max(secs, 1)), true
+}
+
+// setTTLs sets the TTLs of the records in msg according to the zones they
+// are in. Records outside of the zones get recordTTL. In zones with CapTTL
+// set, TTLs don't extend past the next cache refresh. Following RFC 2308, a
+// SOA in the authority section has the TTL of negative answers.
+func (p *Plugin) setTTLs(msg *dns.Msg, zm *ZoneManager) {
+	remaining, known := p.untilRefresh()
+	set := func(rr dns.RR, z *Zone, ttl uint32) {
+		if z != nil && z.CapTTL && known {
+			ttl = min(ttl, remaining)
+		}
+		rr.Header().Ttl = ttl
+	}
+
+	for _, section := range [][]dns.RR{msg.Answer, msg.Extra} {
+		for _, rr := range section {
+			z := zm.FindZone(rr.Header().Name)
+			ttl := uint32(recordTTL)
+			if z != nil {
+				ttl = z.ttl(rr.Header().Rrtype)
+			}
+			set(rr, z, ttl)
+		}
+	}
+	for _, rr := range msg.Ns {
+		z := zm.FindZone(rr.Header().Name)
+		ttl := rr.Header().Ttl
+		if soa, ok := rr.(*dns.SOA); ok {
+			ttl = min(ttl, soa.Minttl)
+		}
+		set(rr, z, ttl)
+	}
+}
@@ -7,10 +7,7 @@ package plugin
 
 import (
 	"context"
-	"fmt"
 	"net"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
@@ -18,7 +15,12 @@ import (
 	"github.com/miekg/dns"
 )
 
-// ServeDNS handles DNS requests for the coresmd plugin
+// ServeDNS handles DNS requests for the coresmd plugin. The plugin is
+// authoritative for its configured forward and reverse zones: names that don't
+// exist get NXDOMAIN and names without records of the requested type get
+// NODATA, both with the zone's SOA in the authority section. Other names are
+// answered if the naming rules (or, without reverse zones, the cache) publish
+// them and passed to the next plugin otherwise.
 func (p Plugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
 	start := time.Now()
 	server := "default" // Use default server name for metrics
@@ -30,232 +32,105 @@ func (p Plugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	}
 
 	q := r.Question[0]
-	qName := strings.TrimSuffix(q.Name, ".")
+	qName := strings.ToLower(strings.TrimSuffix(q.Name, "."))
 	qType := q.Qtype
 
-	// Determine zone for metrics
+	// Determine the zone the plugin is authoritative for, if any
+	zm := p.zm
+	authZone := zm.FindZone(qName)
 	zone := "unknown"
-	for _, z := range p.zones {
-		if strings.HasSuffix(qName, z.Name) {
-			zone = z.Name
-			break
-		}
+	apex := false
+	if authZone != nil {
+		zone = authZone.Name
+		apex = qName == strings.TrimSuffix(authZone.origin(), ".")
 	}
 
-	// Handle DNS queries based on type
+	typeLabel := "other"
 	switch qType {
-	case dns.TypeA:
-		// Handle A record queries (IPv4)
-		if ip := p.lookupA(qName); ip != nil {
-			log.Debugf("A record lookup succeeded: %s -> %s", qName, ip)
-			msg := new(dns.Msg)
-			msg.SetReply(r)
-			msg.Authoritative = true
-			rr := &dns.A{
-				Hdr: dns.RR_Header{
-					Name:   q.Name,
-					Rrtype: dns.TypeA,
-					Class:  dns.ClassINET,
-					Ttl:    60,
-				},
-				A: ip,
-			}
-			msg.Answer = append(msg.Answer, rr)
-			if err := w.WriteMsg(msg); err != nil {
-				log.Errorf("Failed to write A record response for %s: %v", qName, err)
-				return dns.RcodeServerFailure, err
-			}
-
-			// Record metrics
-			RequestCount.WithLabelValues(server, zone, "A").Inc()
-			CacheHits.WithLabelValues(server, zone, "A").Inc()
-			RequestDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
-
-			return dns.RcodeSuccess, nil
-		}
-		// Cache miss for A record
-		log.Debugf("A record cache miss for %s in zone %s", qName, zone)
-		CacheMisses.WithLabelValues(server, zone, "A").Inc()
-
-	case dns.TypeAAAA:
-		// Handle AAAA record queries (IPv6)
-		if ip := p.lookupAAAA(qName); ip != nil {
-			log.Debugf("AAAA record lookup succeeded: %s -> %s", qName, ip)
-			msg := new(dns.Msg)
-			msg.SetReply(r)
-			msg.Authoritative = true
-			rr := &dns.AAAA{
-				Hdr: dns.RR_Header{
-					Name:   q.Name,
-					Rrtype: dns.TypeAAAA,
-					Class:  dns.ClassINET,
-					Ttl:    60,
-				},
-				AAAA: ip,
-			}
-			msg.Answer = append(msg.Answer, rr)
-			if err := w.WriteMsg(msg); err != nil {
-				log.Errorf("Failed to write AAAA record response for %s: %v", qName, err)
-				return dns.RcodeServerFailure, err
-			}
+	case dns.TypeA, dns.TypeAAAA, dns.TypePTR, dns.TypeCNAME, dns.TypeSRV, dns.TypeTXT:
+		typeLabel = dns.TypeToString[qType]
+	}
+	RequestCount.WithLabelValues(server, zone, typeLabel).Inc()
+	defer func() {
+		RequestDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
+	}()
+
+	// Zone transfers are served by the transfer plugin (see Transfer)
+	if qType == dns.TypeAXFR || qType == dns.TypeIXFR {
+		return dns.RcodeRefused, nil
+	}
 
-			// Record metrics
-			RequestCount.WithLabelValues(server, zone, "AAAA").Inc()
-			CacheHits.WithLabelValues(server, zone, "AAAA").Inc()
-			RequestDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
+	if p.cache == nil {
+		log.Warnf("Cache is nil, passing %s (type %s) to next plugin", qName, dns.TypeToString[qType])
+		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+	}
+	idx := p.records()
 
-			return dns.RcodeSuccess, nil
-		}
-		// Cache miss for AAAA record
-		log.Debugf("AAAA record cache miss for %s in zone %s", qName, zone)
-		CacheMisses.WithLabelValues(server, zone, "AAAA").Inc()
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
 
-	case dns.TypePTR:
-		// Handle PTR record queries (reverse lookups)
+	// Handle DNS queries based on type. Aliases are followed within the
+	// published records.
+	msg.Answer = idx.lookupRecords(q.Name, qName, qType)
+	if target, ok := idx.cname[qName]; ok && qType != dns.TypeCNAME && qType != dns.TypeANY {
+		msg.Answer = append([]dns.RR{cnameRecord(q.Name, target)}, idx.lookupRecords(dns.Fqdn(target), target, qType)...)
+	}
+	if qType == dns.TypePTR || qType == dns.TypeANY {
 		if ptr := p.lookupPTR(qName); ptr != "" {
-			log.Debugf("PTR record lookup succeeded: %s -> %s", qName, ptr)
-			msg := new(dns.Msg)
-			msg.SetReply(r)
-			msg.Authoritative = true
-			rr := &dns.PTR{
-				Hdr: dns.RR_Header{
-					Name:   q.Name,
-					Rrtype: dns.TypePTR,
-					Class:  dns.ClassINET,
-					Ttl:    60,
-				},
-				Ptr: dns.Fqdn(ptr),
-			}
-			msg.Answer = append(msg.Answer, rr)
-			if err := w.WriteMsg(msg); err != nil {
-				log.Errorf("Failed to write PTR record response for %s: %v", qName, err)
-				return dns.RcodeServerFailure, err
-			}
-
-			// Record metrics
-			RequestCount.WithLabelValues(server, zone, "PTR").Inc()
-			CacheHits.WithLabelValues(server, zone, "PTR").Inc()
-			RequestDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
-
-			return dns.RcodeSuccess, nil
+			msg.Answer = append(msg.Answer, ptrRecord(q.Name, ptr))
 		}
-		// Cache miss for PTR record
-		log.Debugf("PTR record cache miss for %s in zone %s", qName, zone)
-		CacheMisses.WithLabelValues(server, zone, "PTR").Inc()
-
-	default:
-		// Record metrics for other query types
-		RequestCount.WithLabelValues(server, zone, "other").Inc()
 	}
-
-	RequestDuration.WithLabelValues(server, zone).Observe(time.Since(start).Seconds())
-
-	// Fall through to the next plugin
-	log.Debugf("No match found for %s (type %s), passing to next plugin", qName, dns.TypeToString[qType])
-	return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
-}
-
-// lookupA tries to find an A record (IPv4) for the given name using the SMD cache and zones
-func (p *Plugin) lookupA(name string) net.IP {
-	if p.cache == nil {
-		log.Warn("Cache is nil during A record lookup")
-		return nil
+	if apex {
+		switch qType {
+		case dns.TypeSOA:
+			msg.Answer = append(msg.Answer, authZone.soa(idx.serial(*authZone), p.refreshInterval()))
+		case dns.TypeNS:
+			msg.Answer = append(msg.Answer, authZone.ns()...)
+			msg.Extra = authZone.glue(idx)
+		case dns.TypeANY:
+			msg.Answer = append(msg.Answer, authZone.soa(idx.serial(*authZone), p.refreshInterval()))
+			msg.Answer = append(msg.Answer, authZone.ns()...)
+		}
 	}
-	p.cache.Mutex.RLock()
-	defer p.cache.Mutex.RUnlock()
-
-	for _, zone := range p.zones {
-		if strings.HasSuffix(name, zone.Name) {
-			for _, ei := range p.cache.EthernetInterfaces {
-				if comp, ok := p.cache.Components[ei.ComponentID]; ok && comp.Type == "Node" {
-					xnameHost := comp.ID
-					xnameFQDN := xnameHost + "." + zone.Name
-					// Expand node pattern
-					nidFQDN := ""
-					if zone.NodePattern != "" {
-						// nid{04d} pattern: e.g., nid0001.cluster.local
-						nidHost := expandPattern(zone.NodePattern, comp.NID, comp.ID)
-						nidFQDN = nidHost + "." + zone.Name
-					}
 
-					if name == nidFQDN || name == xnameFQDN {
-						for _, ipEntry := range ei.IPAddresses {
-							if ip := net.ParseIP(ipEntry.IPAddress); ip != nil && ip.To4() != nil {
-								return ip
-							}
-						}
-					}
-				}
-			}
+	if len(msg.Answer) > 0 {
+		p.setTTLs(msg, zm)
+		log.Debugf("%s record lookup succeeded: %s -> %d records", dns.TypeToString[qType], qName, len(msg.Answer))
+		if err := w.WriteMsg(msg); err != nil {
+			log.Errorf("Failed to write %s record response for %s: %v", dns.TypeToString[qType], qName, err)
+			return dns.RcodeServerFailure, err
 		}
-		if strings.HasSuffix(name, zone.Name) {
-			for _, ei := range p.cache.EthernetInterfaces {
-				if comp, ok := p.cache.Components[ei.ComponentID]; ok && comp.Type == "NodeBMC" {
-					xnameHost := comp.ID
-					xnameFQDN := xnameHost + "." + zone.Name
-					if name == xnameFQDN {
-						for _, ipEntry := range ei.IPAddresses {
-							if ip := net.ParseIP(ipEntry.IPAddress); ip != nil && ip.To4() != nil {
-								return ip
-							}
-						}
-					}
-				}
-			}
+		if typeLabel != "other" {
+			CacheHits.WithLabelValues(server, zone, typeLabel).Inc()
 		}
+		return dns.RcodeSuccess, nil
 	}
-	return nil
-}
-
-// lookupAAAA tries to find an AAAA record (IPv6) for the given name using the SMD cache and zones
-func (p *Plugin) lookupAAAA(name string) net.IP {
-	if p.cache == nil {
-		log.Warn("Cache is nil during AAAA record lookup")
-		return nil
+	if typeLabel != "other" {
+		log.Debugf("%s record cache miss for %s in zone %s", typeLabel, qName, zone)
+		CacheMisses.WithLabelValues(server, zone, typeLabel).Inc()
 	}
-	p.cache.Mutex.RLock()
-	defer p.cache.Mutex.RUnlock()
 
-	for _, zone := range p.zones {
-		if strings.HasSuffix(name, zone.Name) {
-			for _, ei := range p.cache.EthernetInterfaces {
-				if comp, ok := p.cache.Components[ei.ComponentID]; ok && comp.Type == "Node" {
-					xnameHost := comp.ID
-					xnameFQDN := xnameHost + "." + zone.Name
-					// Expand node pattern
-					nidFQDN := ""
-					if zone.NodePattern != "" {
-						nidHost := expandPattern(zone.NodePattern, comp.NID, comp.ID)
-						nidFQDN = nidHost + "." + zone.Name
-					}
+	// Outside of the configured zones, leave the name to the next plugin
+	exists := apex || idx.exists(qName)
+	if authZone == nil || !exists && p.Fall.Through(q.Name) {
+		log.Debugf("No match found for %s (type %s), passing to next plugin", qName, dns.TypeToString[qType])
+		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
+	}
 
-					if name == nidFQDN || name == xnameFQDN {
-						for _, ipEntry := range ei.IPAddresses {
-							if ip := net.ParseIP(ipEntry.IPAddress); ip != nil && ip.To4() == nil && ip.To16() != nil {
-								return ip
-							}
-						}
-					}
-				}
-			}
-		}
-		if strings.HasSuffix(name, zone.Name) {
-			for _, ei := range p.cache.EthernetInterfaces {
-				if comp, ok := p.cache.Components[ei.ComponentID]; ok && comp.Type == "NodeBMC" {
-					xnameHost := comp.ID
-					xnameFQDN := xnameHost + "." + zone.Name
-					if name == xnameFQDN {
-						for _, ipEntry := range ei.IPAddresses {
-							if ip := net.ParseIP(ipEntry.IPAddress); ip != nil && ip.To4() == nil && ip.To16() != nil {
-								return ip
-							}
-						}
-					}
-				}
-			}
-		}
+	// Authoritative negative answer: NXDOMAIN if the name doesn't exist,
+	// NODATA if it has no records of the requested type
+	if !exists {
+		msg.Rcode = dns.RcodeNameError
 	}
-	return nil
+	msg.Ns = []dns.RR{authZone.soa(idx.serial(*authZone), p.refreshInterval())}
+	p.setTTLs(msg, zm)
+	log.Debugf("No %s records for %s, answering %s", dns.TypeToString[qType], qName, dns.RcodeToString[msg.Rcode])
+	if err := w.WriteMsg(msg); err != nil {
+		log.Errorf("Failed to write negative response for %s: %v", qName, err)
+		return dns.RcodeServerFailure, err
+	}
+	return msg.Rcode, nil
 }
 
 // lookupPTR tries to find a PTR record for the given reverse lookup name (both IPv4 and IPv6)
@@ -264,31 +139,15 @@ func (p *Plugin) lookupPTR(name string) string {
 		log.Warn("Cache is nil during PTR record lookup")
 		return ""
 	}
-	p.cache.Mutex.RLock()
-	defer p.cache.Mutex.RUnlock()
 
-	// Convert reverse name to IP (handles both IPv4 and IPv6)
+	// Canonicalize standard reverse names (handles both IPv4 and IPv6);
+	// others, such as RFC 2317 classless names, are looked up as is
 	if ip := reverseToIP(name); ip != nil {
-		// Find matching EthernetInterface
-		for _, ei := range p.cache.EthernetInterfaces {
-			for _, ipEntry := range ei.IPAddresses {
-				if net.ParseIP(ipEntry.IPAddress).Equal(ip) {
-					if comp, ok := p.cache.Components[ei.ComponentID]; ok {
-						// Return node or BMC hostname
-						for _, zone := range p.zones {
-							if comp.Type == "Node" && zone.NodePattern != "" {
-								return comp.ID + "." + zone.Name
-							}
-							if comp.Type == "NodeBMC" {
-								return comp.ID + "." + zone.Name
-							}
-						}
-					}
-				}
-			}
+		if rev, err := dns.ReverseAddr(ip.String()); err == nil {
+			name = rev
 		}
 	}
-	return ""
+	return p.records().lookupPTR(name)
 }
 
 // reverseToIP converts a reverse DNS name to an IP address (supports both IPv4 and IPv6)
@@ -333,18 +192,6 @@ func reverseToIP(name string) net.IP {
 	return nil
 }
 
-// expandPattern replaces {Nd} with zero-padded NID and {id} with xname
-func expandPattern(pattern string, nid int64, id string) string {
-	out := strings.ReplaceAll(pattern, "{id}", id)
-	re := regexp.MustCompile(`\{0*(\d+)d\}`)
-	out = re.ReplaceAllStringFunc(out, func(m string) string {
-		nStr := re.FindStringSubmatch(m)[1]
-		n, _ := strconv.Atoi(nStr)
-		return fmt.Sprintf("%0*d", n, nid)
-	})
-	return out
-}
-
 // Name returns the plugin name
 func (p Plugin) Name() string {
 	return "coresmd"
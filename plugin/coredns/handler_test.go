@@ -14,9 +14,12 @@ import (
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/miekg/dns"
 
 	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/rule"
 	"github.com/openchami/coresmd/internal/smdclient"
 )
 
@@ -75,7 +78,8 @@ func createTestPlugin() *Plugin {
 		},
 	}
 
-	return &Plugin{
+	p := &Plugin{
+		index: &indexHolder{},
 		zones: []Zone{
 			{
 				Name:        "cluster.local",
@@ -84,6 +88,29 @@ func createTestPlugin() *Plugin {
 		},
 		cache: cache,
 	}
+	p.initZones()
+	return p
+}
+
+// lookupA returns the first IPv4 address published for name.
+func (p *Plugin) lookupA(name string) net.IP {
+	return p.lookupIP(name, func(ip net.IP) bool { return ip.To4() != nil })
+}
+
+// lookupAAAA returns the first IPv6 address published for name.
+func (p *Plugin) lookupAAAA(name string) net.IP {
+	return p.lookupIP(name, func(ip net.IP) bool { return ip.To4() == nil && ip.To16() != nil })
+}
+
+// lookupIP returns the first address accepted by family that is published for
+// name.
+func (p *Plugin) lookupIP(name string, family func(net.IP) bool) net.IP {
+	for _, ip := range p.records().lookup(name) {
+		if family(ip) {
+			return ip
+		}
+	}
+	return nil
 }
 
 func TestCreateTestPlugin(t *testing.T) {
@@ -268,21 +295,17 @@ func TestServeDNS_Unknown_A_Record(t *testing.T) {
 	w := &mockResponseWriter{}
 
 	// Call ServeDNS
-	_, err := p.ServeDNS(context.Background(), w, req)
+	rcode, err := p.ServeDNS(context.Background(), w, req)
 
 	if err != nil {
 		t.Fatalf("ServeDNS failed: %v", err)
 	}
 
-	// Should call next plugin
-	if !mock.called {
-		t.Error("Expected next plugin to be called")
-	}
-
-	// Should not have written a response
-	if w.msg != nil {
-		t.Error("Expected no response to be written")
+	// Should answer NXDOMAIN authoritatively instead of calling next plugin
+	if mock.called {
+		t.Error("Expected next plugin not to be called")
 	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeNameError, "cluster.local.")
 }
 
 func TestServeDNS_Unknown_PTR_Record(t *testing.T) {
@@ -328,21 +351,17 @@ func TestServeDNS_Other_Record_Types(t *testing.T) {
 	w := &mockResponseWriter{}
 
 	// Call ServeDNS
-	_, err := p.ServeDNS(context.Background(), w, req)
+	rcode, err := p.ServeDNS(context.Background(), w, req)
 
 	if err != nil {
 		t.Fatalf("ServeDNS failed: %v", err)
 	}
 
-	// Should call next plugin
-	if !mock.called {
-		t.Error("Expected next plugin to be called")
-	}
-
-	// Should not have written a response
-	if w.msg != nil {
-		t.Error("Expected no response to be written")
+	// Name exists, so the answer is NODATA
+	if mock.called {
+		t.Error("Expected next plugin not to be called")
 	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeSuccess, "cluster.local.")
 }
 
 func TestServeDNS_Empty_Question(t *testing.T) {
@@ -376,6 +395,7 @@ func TestServeDNS_Empty_Question(t *testing.T) {
 
 func TestServeDNS_Nil_Cache(t *testing.T) {
 	p := &Plugin{
+		index: &indexHolder{},
 		zones: []Zone{
 			{
 				Name:        "cluster.local",
@@ -384,6 +404,7 @@ func TestServeDNS_Nil_Cache(t *testing.T) {
 		},
 		cache: nil, // No cache
 	}
+	p.initZones()
 	mock := &mockHandler{}
 	p.Next = mock
 
@@ -518,7 +539,8 @@ func TestLookupA_Direct(t *testing.T) {
 }
 
 func makeTestPluginWithPattern(pattern string, nid int, xname, ip string) *Plugin {
-	return &Plugin{
+	p := &Plugin{
+		index: &indexHolder{},
 		zones: []Zone{
 			{
 				Name:        "test.cluster",
@@ -550,6 +572,8 @@ func makeTestPluginWithPattern(pattern string, nid int, xname, ip string) *Plugi
 			},
 		},
 	}
+	p.initZones()
+	return p
 }
 
 func TestLookupA_Patterns(t *testing.T) {
@@ -606,7 +630,8 @@ func createTestPluginForBugReport() *Plugin {
 		},
 	}
 
-	return &Plugin{
+	p := &Plugin{
+		index: &indexHolder{},
 		zones: []Zone{
 			{
 				Name:        "redondo.usrc",
@@ -615,6 +640,8 @@ func createTestPluginForBugReport() *Plugin {
 		},
 		cache: cache,
 	}
+	p.initZones()
+	return p
 }
 
 // TestServeDNS_BMC_XName_BugReport tests the reported bug for BMC xname lookup
@@ -739,7 +766,8 @@ func createTestPluginWithIPv6() *Plugin {
 		},
 	}
 
-	return &Plugin{
+	p := &Plugin{
+		index: &indexHolder{},
 		zones: []Zone{
 			{
 				Name:        "cluster.local",
@@ -748,6 +776,8 @@ func createTestPluginWithIPv6() *Plugin {
 		},
 		cache: cache,
 	}
+	p.initZones()
+	return p
 }
 
 // TestServeDNS_AAAA_Record_Node tests AAAA record lookup for a node
@@ -921,7 +951,7 @@ func TestServeDNS_A_Record_WithIPv6_OnlyReturnsIPv4(t *testing.T) {
 	}
 }
 
-// TestServeDNS_A_Record_IPv6Only_NoAnswer tests that A query on IPv6-only node falls through
+// TestServeDNS_A_Record_IPv6Only_NoAnswer tests that A query on IPv6-only node gets NODATA
 func TestServeDNS_A_Record_IPv6Only_NoAnswer(t *testing.T) {
 	p := createTestPluginWithIPv6()
 	mock := &mockHandler{}
@@ -932,16 +962,17 @@ func TestServeDNS_A_Record_IPv6Only_NoAnswer(t *testing.T) {
 
 	w := &mockResponseWriter{}
 
-	_, err := p.ServeDNS(context.Background(), w, req)
+	rcode, err := p.ServeDNS(context.Background(), w, req)
 
 	if err != nil {
 		t.Fatalf("ServeDNS failed: %v", err)
 	}
 
-	// Should fall through to next plugin since no IPv4 address
-	if !mock.called {
-		t.Error("Expected next plugin to be called for IPv6-only node with A query")
+	// Should answer NODATA since the name exists with only an IPv6 address
+	if mock.called {
+		t.Error("Expected next plugin not to be called for IPv6-only node with A query")
 	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeSuccess, "cluster.local.")
 }
 
 // TestServeDNS_PTR_Record_IPv6 tests reverse lookup for IPv6 address
@@ -995,16 +1026,17 @@ func TestServeDNS_Unknown_AAAA_Record(t *testing.T) {
 
 	w := &mockResponseWriter{}
 
-	_, err := p.ServeDNS(context.Background(), w, req)
+	rcode, err := p.ServeDNS(context.Background(), w, req)
 
 	if err != nil {
 		t.Fatalf("ServeDNS failed: %v", err)
 	}
 
-	// Should call next plugin
-	if !mock.called {
-		t.Error("Expected next plugin to be called for unknown hostname")
+	// Should answer NXDOMAIN authoritatively
+	if mock.called {
+		t.Error("Expected next plugin not to be called for unknown hostname")
 	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeNameError, "cluster.local.")
 }
 
 // TestReverseToIP_IPv4 tests IPv4 reverse DNS conversion
@@ -1186,3 +1218,745 @@ func TestServeDNS_WriteMsg_Failure(t *testing.T) {
 		t.Error("Expected next plugin not to be called when write fails")
 	}
 }
+
+// createTestPluginWithComponentTypes creates a plugin whose cache holds
+// components of types other than Node and NodeBMC
+func createTestPluginWithComponentTypes(zone Zone) *Plugin {
+	p := createTestPlugin()
+	p.zones = []Zone{zone}
+	p.initZones()
+	for _, c := range []struct {
+		mac, id, typ, ip string
+	}{
+		{"02:00:00:00:00:01", "x3000c0w14", "MgmtSwitch", "192.168.2.14"},
+		{"02:00:00:00:00:02", "x3000c0r15b0", "RouterBMC", "192.168.2.15"},
+		{"02:00:00:00:00:03", "x1000c1b0", "ChassisBMC", "192.168.2.16"},
+		{"02:00:00:00:00:04", "x3000m0", "CabinetPDUController", "192.168.2.17"},
+	} {
+		p.cache.EthernetInterfaces[c.mac] = smdclient.EthernetInterface{
+			MACAddress:  c.mac,
+			ComponentID: c.id,
+			IPAddresses: []smdclient.IPAddress{{IPAddress: c.ip}},
+		}
+		p.cache.Components[c.id] = smdclient.Component{ID: c.id, Type: c.typ}
+	}
+	return p
+}
+
+func TestLookupA_ComponentTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		zone   Zone
+		lookup string
+		want   string // empty if no record is expected
+	}{
+		{
+			name:   "default_types_exclude_switch",
+			zone:   Zone{Name: "cluster.local", NodePattern: "nid{04d}"},
+			lookup: "x3000c0w14.cluster.local",
+		},
+		{
+			name:   "default_types_include_node_pattern",
+			zone:   Zone{Name: "cluster.local", NodePattern: "nid{04d}"},
+			lookup: "nid0001.cluster.local",
+			want:   "192.168.1.10",
+		},
+		{
+			name: "switch_by_xname",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"MgmtSwitch": true}, Pattern: "sw-{id}"},
+			}},
+			lookup: "x3000c0w14.cluster.local",
+			want:   "192.168.2.14",
+		},
+		{
+			name: "switch_by_pattern",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"MgmtSwitch": true}, Pattern: "sw-{id}"},
+			}},
+			lookup: "sw-x3000c0w14.cluster.local",
+			want:   "192.168.2.14",
+		},
+		{
+			name: "multiple_types_in_one_record_type",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"RouterBMC": true, "ChassisBMC": true}},
+			}},
+			lookup: "x1000c1b0.cluster.local",
+			want:   "192.168.2.16",
+		},
+		{
+			name: "explicit_types_replace_defaults",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"RouterBMC": true}},
+			}},
+			lookup: "bmc001.cluster.local",
+		},
+		{
+			name: "node_pattern_kept_with_explicit_types",
+			zone: Zone{Name: "cluster.local", NodePattern: "nid{04d}", Records: []RecordType{
+				{Types: map[string]bool{"RouterBMC": true}},
+			}},
+			lookup: "nid0001.cluster.local",
+			want:   "192.168.1.10",
+		},
+		{
+			name: "wildcard_publishes_all_types",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"*": true}},
+			}},
+			lookup: "x3000m0.cluster.local",
+			want:   "192.168.2.17",
+		},
+		{
+			name: "case_insensitive",
+			zone: Zone{Name: "cluster.local", Records: []RecordType{
+				{Types: map[string]bool{"MgmtSwitch": true}},
+			}},
+			lookup: "X3000C0W14.Cluster.Local",
+			want:   "192.168.2.14",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := createTestPluginWithComponentTypes(tt.zone)
+			ip := p.lookupA(tt.lookup)
+			if tt.want == "" {
+				if ip != nil {
+					t.Errorf("lookupA(%q) = %v, want nil", tt.lookup, ip)
+				}
+				return
+			}
+			if !ip.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("lookupA(%q) = %v, want %s", tt.lookup, ip, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupPTR_ComponentTypes(t *testing.T) {
+	p := createTestPluginWithComponentTypes(Zone{Name: "cluster.local", Records: []RecordType{
+		{Types: map[string]bool{"MgmtSwitch": true}, Pattern: "sw-{id}"},
+	}})
+
+	if got := p.lookupPTR("14.2.168.192.in-addr.arpa."); got != "x3000c0w14.cluster.local" {
+		t.Errorf("lookupPTR() for switch = %q, want x3000c0w14.cluster.local", got)
+	}
+	if got := p.lookupPTR("15.2.168.192.in-addr.arpa."); got != "" {
+		t.Errorf("lookupPTR() for unpublished RouterBMC = %q, want none", got)
+	}
+}
+
+func mustParseRules(t *testing.T, rules ...string) []rule.Rule {
+	t.Helper()
+	var parsed []rule.Rule
+	for _, s := range rules {
+		r, err := rule.ParseRule(s)
+		if err != nil {
+			t.Fatalf("ParseRule(%q): %v", s, err)
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed
+}
+
+func TestLookup_Rules(t *testing.T) {
+	p := createTestPlugin()
+	p.zones = append(p.zones, Zone{Name: "other.local"})
+	p.initZones()
+	p.domain = "cluster.local"
+	p.rules = mustParseRules(t,
+		"type:Node,hostname:compute{03d}",
+		"type:NodeBMC,hostname:{id}-mgmt,domain_append:none",
+	)
+
+	tests := []struct {
+		name string
+		want string // empty if no record is expected
+	}{
+		{name: "compute001.cluster.local", want: "192.168.1.10"},
+		{name: "COMPUTE001.cluster.local", want: "192.168.1.10"},
+		{name: "bmc001-mgmt.cluster.local", want: "192.168.1.100"},
+		{name: "bmc001-mgmt.other.local", want: "192.168.1.100"},
+		// Zone records are still published alongside rule names
+		{name: "nid0001.cluster.local", want: "192.168.1.10"},
+		{name: "compute001.other.local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip := p.lookupA(tt.name)
+			if tt.want == "" {
+				if ip != nil {
+					t.Errorf("lookupA(%q) = %v, want nil", tt.name, ip)
+				}
+				return
+			}
+			if !ip.Equal(net.ParseIP(tt.want)) {
+				t.Errorf("lookupA(%q) = %v, want %s", tt.name, ip, tt.want)
+			}
+		})
+	}
+
+	// Reverse records use the rule names
+	if got := p.lookupPTR("10.1.168.192.in-addr.arpa."); got != "compute001.cluster.local" {
+		t.Errorf("lookupPTR() for node = %q, want compute001.cluster.local", got)
+	}
+	if got := p.lookupPTR("100.1.168.192.in-addr.arpa."); got != "bmc001-mgmt.cluster.local" {
+		t.Errorf("lookupPTR() for BMC = %q, want bmc001-mgmt.cluster.local", got)
+	}
+}
+
+func TestLookup_RulesIgnore(t *testing.T) {
+	p := createTestPlugin()
+	p.domain = "cluster.local"
+	p.rules = mustParseRules(t, "type:NodeBMC,ignore:true", "hostname:host-{id}")
+
+	if ip := p.lookupA("host-bmc001.cluster.local"); ip != nil {
+		t.Errorf("lookupA() for ignored BMC = %v, want nil", ip)
+	}
+	if ip := p.lookupA("host-node001.cluster.local"); !ip.Equal(net.ParseIP("192.168.1.10")) {
+		t.Errorf("lookupA() for node = %v, want 192.168.1.10", ip)
+	}
+}
+
+// TestLookup_RulesAgreeWithDHCP checks that the hostname CoreDHCP hands out
+// for an interface resolves to the interface's address.
+func TestLookup_RulesAgreeWithDHCP(t *testing.T) {
+	p := createTestPlugin()
+	p.domain = "cluster.local"
+	p.rules = mustParseRules(t,
+		"subnet:192.168.1.0/26,hostname:nid{04d},continue:true",
+		"type:NodeBMC,hostname:{id},domain:bmc.cluster.local",
+	)
+
+	for mac, ei := range p.cache.EthernetInterfaces {
+		comp := p.cache.Components[ei.ComponentID]
+		ii := iface.NewIfaceInfo(ei, comp)
+
+		resp, err := dhcpv4.New()
+		if err != nil {
+			t.Fatalf("dhcpv4.New(): %v", err)
+		}
+		if !rule.Evaluate4(nil, ii, p.domain, "none", resp, p.rules) {
+			t.Fatalf("Evaluate4() dropped %s", mac)
+		}
+		hostname := resp.HostName()
+
+		if ip := p.lookupA(hostname); !ip.Equal(ii.IPList[0]) {
+			t.Errorf("DHCP hostname %q of %s resolves to %v, want %s", hostname, mac, ip, ii.IPList[0])
+		}
+		reverse, _ := dns.ReverseAddr(ii.IPList[0].String())
+		if got := p.lookupPTR(reverse); got != hostname {
+			t.Errorf("PTR of %s = %q, want DHCP hostname %q", ii.IPList[0], got, hostname)
+		}
+	}
+}
+
+func TestLookup_IndexFollowsCache(t *testing.T) {
+	p := createTestPlugin()
+
+	if ip := p.lookupA("nid0001.cluster.local."); ip == nil || ip.String() != "192.168.1.10" {
+		t.Fatalf("lookupA before update = %v, want 192.168.1.10", ip)
+	}
+
+	// Change the cache the way a refresh does
+	p.cache.Mutex.Lock()
+	ei := p.cache.EthernetInterfaces["00:11:22:33:44:55"]
+	ei.IPAddresses = []smdclient.IPAddress{{IPAddress: "192.168.1.11"}}
+	p.cache.EthernetInterfaces["00:11:22:33:44:55"] = ei
+	p.cache.Generation++
+	p.cache.Mutex.Unlock()
+
+	if ip := p.lookupA("NID0001.Cluster.Local."); ip == nil || ip.String() != "192.168.1.11" {
+		t.Errorf("lookupA after update = %v, want 192.168.1.11", ip)
+	}
+	if name := p.lookupPTR("11.1.168.192.in-addr.arpa."); name != "node001.cluster.local" {
+		t.Errorf("lookupPTR after update = %q, want node001.cluster.local", name)
+	}
+	if name := p.lookupPTR("10.1.168.192.in-addr.arpa."); name != "" {
+		t.Errorf("lookupPTR for removed address = %q, want empty", name)
+	}
+}
+
+// createBenchmarkPlugin creates a plugin whose cache holds n nodes, each with a
+// BMC.
+func createBenchmarkPlugin(n int) *Plugin {
+	c := &cache.Cache{
+		EthernetInterfaces: make(map[string]smdclient.EthernetInterface, 2*n),
+		Components:         make(map[string]smdclient.Component, 2*n),
+	}
+	for i := range n {
+		node := fmt.Sprintf("x%dc0s%db0n0", 1000+i/64, i%64)
+		bmc := fmt.Sprintf("x%dc0s%db0", 1000+i/64, i%64)
+		c.Components[node] = smdclient.Component{ID: node, NID: int64(i + 1), Type: "Node"}
+		c.Components[bmc] = smdclient.Component{ID: bmc, Type: "NodeBMC"}
+		c.EthernetInterfaces[fmt.Sprintf("02:00:00:%02x:%02x:00", i>>8, i&0xff)] = smdclient.EthernetInterface{
+			ComponentID: node,
+			IPAddresses: []smdclient.IPAddress{{IPAddress: fmt.Sprintf("10.1.%d.%d", i>>8, i&0xff)}},
+		}
+		c.EthernetInterfaces[fmt.Sprintf("02:00:00:%02x:%02x:01", i>>8, i&0xff)] = smdclient.EthernetInterface{
+			ComponentID: bmc,
+			IPAddresses: []smdclient.IPAddress{{IPAddress: fmt.Sprintf("10.2.%d.%d", i>>8, i&0xff)}},
+		}
+	}
+	p := &Plugin{
+		zones: []Zone{{Name: "cluster.local", NodePattern: "nid{04d}"}},
+		cache: c,
+		index: &indexHolder{},
+	}
+	p.initZones()
+	return p
+}
+
+func BenchmarkLookupA(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		p := createBenchmarkPlugin(n)
+		name := fmt.Sprintf("nid%04d.cluster.local.", n)
+		if p.lookupA(name) == nil {
+			b.Fatalf("no A record for %s", name)
+		}
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				p.lookupA(name)
+			}
+		})
+	}
+}
+
+func BenchmarkLookupPTR(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		p := createBenchmarkPlugin(n)
+		name := fmt.Sprintf("%d.%d.2.10.in-addr.arpa.", (n-1)&0xff, (n-1)>>8)
+		if p.lookupPTR(name) == "" {
+			b.Fatalf("no PTR record for %s", name)
+		}
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			for b.Loop() {
+				p.lookupPTR(name)
+			}
+		})
+	}
+}
+
+func BenchmarkBuildIndex(b *testing.B) {
+	p := createBenchmarkPlugin(10000)
+	for b.Loop() {
+		p.buildIndex()
+	}
+}
+
+// checkNegativeResponse checks that msg is an authoritative negative answer
+// with the given rcode and the SOA of zone in the authority section.
+func checkNegativeResponse(t *testing.T, msg *dns.Msg, rcode, wantRcode int, zone string) {
+	t.Helper()
+
+	if rcode != wantRcode {
+		t.Errorf("rcode = %s, want %s", dns.RcodeToString[rcode], dns.RcodeToString[wantRcode])
+	}
+	if msg == nil {
+		t.Fatal("Expected a response to be written")
+	}
+	if msg.Rcode != wantRcode {
+		t.Errorf("response rcode = %s, want %s", dns.RcodeToString[msg.Rcode], dns.RcodeToString[wantRcode])
+	}
+	if !msg.Authoritative {
+		t.Error("Expected response to be authoritative")
+	}
+	if len(msg.Answer) != 0 {
+		t.Errorf("Expected no answers, got %v", msg.Answer)
+	}
+	if len(msg.Ns) != 1 {
+		t.Fatalf("Expected 1 authority record, got %v", msg.Ns)
+	}
+	soa, ok := msg.Ns[0].(*dns.SOA)
+	if !ok {
+		t.Fatalf("Authority record is not a SOA record: %v", msg.Ns[0])
+	}
+	if soa.Hdr.Name != zone {
+		t.Errorf("SOA name = %s, want %s", soa.Hdr.Name, zone)
+	}
+}
+
+func TestServeDNS_A_Record_AllAddresses(t *testing.T) {
+	p := createTestPlugin()
+	p.cache.EthernetInterfaces["00:11:22:33:44:56"] = smdclient.EthernetInterface{
+		MACAddress:  "00:11:22:33:44:56",
+		ComponentID: "node001",
+		IPAddresses: []smdclient.IPAddress{{IPAddress: "192.168.2.10"}, {IPAddress: "fd00::10"}},
+	}
+
+	tests := []struct {
+		qtype uint16
+		want  []string
+	}{
+		{dns.TypeA, []string{"192.168.1.10", "192.168.2.10"}},
+		{dns.TypeAAAA, []string{"fd00::10"}},
+	}
+	for _, tt := range tests {
+		t.Run(dns.TypeToString[tt.qtype], func(t *testing.T) {
+			req := new(dns.Msg)
+			req.SetQuestion("NID0001.cluster.local.", tt.qtype)
+			w := &mockResponseWriter{}
+
+			if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+				t.Fatalf("ServeDNS failed: %v", err)
+			}
+			var got []string
+			for _, rr := range w.msg.Answer {
+				if rr.Header().Name != "NID0001.cluster.local." {
+					t.Errorf("answer name = %s, want question name", rr.Header().Name)
+				}
+				switch rr := rr.(type) {
+				case *dns.A:
+					got = append(got, rr.A.String())
+				case *dns.AAAA:
+					got = append(got, rr.AAAA.String())
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("answers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeDNS_Apex(t *testing.T) {
+	p := createTestPlugin()
+	p.zones[0].NS = []string{"nid0001.cluster.local"}
+	p.zones[0].Hostmaster = "admin.example.com"
+	p.initZones()
+
+	// SOA
+	req := new(dns.Msg)
+	req.SetQuestion("cluster.local.", dns.TypeSOA)
+	w := &mockResponseWriter{}
+	if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	if len(w.msg.Answer) != 1 {
+		t.Fatalf("Expected 1 SOA answer, got %v", w.msg.Answer)
+	}
+	soa, ok := w.msg.Answer[0].(*dns.SOA)
+	if !ok {
+		t.Fatalf("Answer is not a SOA record: %v", w.msg.Answer[0])
+	}
+	if soa.Ns != "nid0001.cluster.local." || soa.Mbox != "admin.example.com." || soa.Serial == 0 {
+		t.Errorf("unexpected SOA %v", soa)
+	}
+
+	// NS, with glue for the in-zone name server
+	req.SetQuestion("cluster.local.", dns.TypeNS)
+	w = &mockResponseWriter{}
+	if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].(*dns.NS).Ns != "nid0001.cluster.local." {
+		t.Errorf("unexpected NS answer %v", w.msg.Answer)
+	}
+	if len(w.msg.Extra) != 1 || w.msg.Extra[0].(*dns.A).A.String() != "192.168.1.10" {
+		t.Errorf("unexpected NS glue %v", w.msg.Extra)
+	}
+
+	// Apex exists, so other types get NODATA
+	req.SetQuestion("cluster.local.", dns.TypeA)
+	w = &mockResponseWriter{}
+	rcode, err := p.ServeDNS(context.Background(), w, req)
+	if err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeSuccess, "cluster.local.")
+}
+
+func TestServeDNS_EmptyNonTerminal(t *testing.T) {
+	p := createTestPlugin()
+	p.rules = mustParseRules(t, "type:NodeBMC,hostname:{id},domain:bmc.cluster.local")
+
+	req := new(dns.Msg)
+	req.SetQuestion("bmc.cluster.local.", dns.TypeA)
+	w := &mockResponseWriter{}
+	rcode, err := p.ServeDNS(context.Background(), w, req)
+	if err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	checkNegativeResponse(t, w.msg, rcode, dns.RcodeSuccess, "cluster.local.")
+}
+
+func TestServeDNS_Fallthrough(t *testing.T) {
+	tests := []struct {
+		name     string
+		fall     []string
+		qname    string
+		qtype    uint16
+		wantNext bool
+	}{
+		{name: "disabled", qname: "unknown.cluster.local.", qtype: dns.TypeA},
+		{name: "all zones", fall: []string{}, qname: "unknown.cluster.local.", qtype: dns.TypeA, wantNext: true},
+		{name: "matching zone", fall: []string{"cluster.local"}, qname: "unknown.cluster.local.", qtype: dns.TypeA, wantNext: true},
+		{name: "other zone", fall: []string{"other.local"}, qname: "unknown.cluster.local.", qtype: dns.TypeA},
+		{name: "NODATA never falls through", fall: []string{}, qname: "nid0001.cluster.local.", qtype: dns.TypeMX},
+		{name: "outside zones", qname: "unknown.example.com.", qtype: dns.TypeA, wantNext: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := createTestPlugin()
+			if tt.fall != nil {
+				p.Fall.SetZonesFromArgs(tt.fall)
+			}
+			mock := &mockHandler{}
+			p.Next = mock
+
+			req := new(dns.Msg)
+			req.SetQuestion(tt.qname, tt.qtype)
+			w := &mockResponseWriter{}
+			if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+				t.Fatalf("ServeDNS failed: %v", err)
+			}
+			if mock.called != tt.wantNext {
+				t.Errorf("next plugin called = %v, want %v", mock.called, tt.wantNext)
+			}
+			if !tt.wantNext && w.msg == nil {
+				t.Error("Expected a response to be written")
+			}
+		})
+	}
+}
+
+func TestServeDNS_TTLs(t *testing.T) {
+	p := createTestPlugin()
+	p.zones[0].TTLs = map[uint16]uint32{dns.TypeNone: 300, dns.TypeAAAA: 30, dns.TypeSOA: 600}
+	p.initZones()
+	p.cache.EthernetInterfaces["00:11:22:33:44:56"] = smdclient.EthernetInterface{
+		ComponentID: "node001",
+		IPAddresses: []smdclient.IPAddress{{IPAddress: "fd00::10"}},
+	}
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		t.Helper()
+		req := new(dns.Msg)
+		req.SetQuestion(name, qtype)
+		w := &mockResponseWriter{}
+		if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+			t.Fatalf("ServeDNS failed: %v", err)
+		}
+		return w.msg
+	}
+	ttls := func(rrs []dns.RR) []uint32 {
+		var out []uint32
+		for _, rr := range rrs {
+			out = append(out, rr.Header().Ttl)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		qname   string
+		qtype   uint16
+		section func(*dns.Msg) []dns.RR
+		want    []uint32
+	}{
+		{"zone default", "nid0001.cluster.local.", dns.TypeA, func(m *dns.Msg) []dns.RR { return m.Answer }, []uint32{300}},
+		{"type override", "nid0001.cluster.local.", dns.TypeAAAA, func(m *dns.Msg) []dns.RR { return m.Answer }, []uint32{30}},
+		{"SOA", "cluster.local.", dns.TypeSOA, func(m *dns.Msg) []dns.RR { return m.Answer }, []uint32{600}},
+		{"negative answer", "unknown.cluster.local.", dns.TypeA, func(m *dns.Msg) []dns.RR { return m.Ns }, []uint32{300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ttls(tt.section(query(tt.qname, tt.qtype)))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("TTLs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// SOA timers follow the cache refresh interval
+	soa := query("cluster.local.", dns.TypeSOA).Answer[0].(*dns.SOA)
+	if soa.Refresh != 60 || soa.Retry != 30 || soa.Minttl != 300 {
+		t.Errorf("SOA timers = refresh %d, retry %d, minimum %d; want 60, 30, 300", soa.Refresh, soa.Retry, soa.Minttl)
+	}
+
+	// Capped at the time until the next refresh, 10s from now
+	p.zones[0].CapTTL = true
+	p.initZones()
+	p.cache.LastUpdated = time.Now().Add(-50 * time.Second)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		for _, ttl := range ttls(query("nid0001.cluster.local.", qtype).Answer) {
+			if ttl > 10 || ttl < 9 {
+				t.Errorf("%s TTL = %d, want capped at 10", dns.TypeToString[qtype], ttl)
+			}
+		}
+	}
+	if ttl := query("unknown.cluster.local.", dns.TypeA).Ns[0].Header().Ttl; ttl > 10 {
+		t.Errorf("negative answer TTL = %d, want capped at 10", ttl)
+	}
+
+	// Overdue refreshes still get a nonzero TTL
+	p.cache.LastUpdated = time.Now().Add(-time.Hour)
+	if ttl := query("nid0001.cluster.local.", dns.TypeA).Answer[0].Header().Ttl; ttl != 1 {
+		t.Errorf("TTL with overdue refresh = %d, want 1", ttl)
+	}
+}
+
+func TestServeDNS_InventoryRecords(t *testing.T) {
+	p := createTestPlugin()
+	p.zones[0].NodePattern = ""
+	p.zones[0].CNAMEs = []RecordType{{Types: map[string]bool{"Node": true}, Pattern: "nid{04d}"}}
+	p.zones[0].SRVs = []ServiceRecord{{Service: "_redfish._tcp", Port: 443, Types: map[string]bool{"NodeBMC": true}}}
+	p.zones[0].TXT = []string{"type", "nid"}
+	p.initZones()
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		want  []string
+	}{
+		{
+			qname: "nid0001.cluster.local.",
+			qtype: dns.TypeA,
+			want: []string{
+				"nid0001.cluster.local.\t60\tIN\tCNAME\tnode001.cluster.local.",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.10",
+			},
+		},
+		{
+			qname: "nid0001.cluster.local.",
+			qtype: dns.TypeCNAME,
+			want:  []string{"nid0001.cluster.local.\t60\tIN\tCNAME\tnode001.cluster.local."},
+		},
+		{
+			qname: "nid0001.cluster.local.",
+			qtype: dns.TypeTXT,
+			want: []string{
+				"nid0001.cluster.local.\t60\tIN\tCNAME\tnode001.cluster.local.",
+				"node001.cluster.local.\t60\tIN\tTXT\t\"type=Node\" \"nid=1\"",
+			},
+		},
+		{
+			qname: "bmc001.cluster.local.",
+			qtype: dns.TypeTXT,
+			want:  []string{"bmc001.cluster.local.\t60\tIN\tTXT\t\"type=NodeBMC\""},
+		},
+		{
+			qname: "_redfish._tcp.cluster.local.",
+			qtype: dns.TypeSRV,
+			want:  []string{"_redfish._tcp.cluster.local.\t60\tIN\tSRV\t0 0 443 bmc001.cluster.local."},
+		},
+		{
+			qname: "_redfish._tcp.bmc001.cluster.local.",
+			qtype: dns.TypeSRV,
+			want:  []string{"_redfish._tcp.bmc001.cluster.local.\t60\tIN\tSRV\t0 0 443 bmc001.cluster.local."},
+		},
+		{
+			qname: "_redfish._tcp.node001.cluster.local.",
+			qtype: dns.TypeSRV,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qname+" "+dns.TypeToString[tt.qtype], func(t *testing.T) {
+			req := new(dns.Msg)
+			req.SetQuestion(tt.qname, tt.qtype)
+			w := &mockResponseWriter{}
+			rcode, err := p.ServeDNS(context.Background(), w, req)
+			if err != nil {
+				t.Fatalf("ServeDNS failed: %v", err)
+			}
+			if tt.want == nil {
+				checkNegativeResponse(t, w.msg, rcode, dns.RcodeNameError, "cluster.local.")
+				return
+			}
+			var got []string
+			for _, rr := range w.msg.Answer {
+				got = append(got, rr.String())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("answers =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+
+	// Aliases aren't published over names that have addresses
+	p = createTestPlugin()
+	p.zones[0].CNAMEs = []RecordType{{Types: map[string]bool{"Node": true}, Pattern: "nid{04d}"}}
+	p.initZones()
+	req := new(dns.Msg)
+	req.SetQuestion("nid0001.cluster.local.", dns.TypeA)
+	w := &mockResponseWriter{}
+	if _, err := p.ServeDNS(context.Background(), w, req); err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	if len(w.msg.Answer) != 1 || w.msg.Answer[0].Header().Rrtype != dns.TypeA {
+		t.Errorf("Expected only an A record, got %v", w.msg.Answer)
+	}
+}
+
+func TestServeDNS_ComponentFilters(t *testing.T) {
+	p := createTestPlugin()
+	disabled := false
+	p.cache.EthernetInterfaces["00:11:22:33:44:66"] = smdclient.EthernetInterface{
+		MACAddress:  "00:11:22:33:44:66",
+		ComponentID: "node002",
+		IPAddresses: []smdclient.IPAddress{{IPAddress: "192.168.1.11"}},
+	}
+	p.cache.EthernetInterfaces["00:11:22:33:44:77"] = smdclient.EthernetInterface{
+		MACAddress:  "00:11:22:33:44:77",
+		ComponentID: "node003",
+		IPAddresses: []smdclient.IPAddress{{IPAddress: "192.168.1.12"}},
+	}
+	p.cache.Components["node002"] = smdclient.Component{ID: "node002", NID: 2, Type: "Node", Role: "Management"}
+	p.cache.Components["node003"] = smdclient.Component{ID: "node003", NID: 3, Type: "Node", Enabled: &disabled}
+
+	mustParseMatch := func(s string) rule.Match {
+		m, err := rule.ParseMatch(s)
+		if err != nil {
+			t.Fatalf("failed to parse match %q: %v", s, err)
+		}
+		return m
+	}
+	p.zones[0].NodePattern = ""
+	p.zones[0].Filter = mustParseMatch("enabled:true")
+	p.zones[0].Records = []RecordType{
+		{Types: map[string]bool{"Node": true}, Pattern: "mgmt{03d}", Filter: mustParseMatch("role:management")},
+		{Types: map[string]bool{"Node": true}, Pattern: "nid{04d}"},
+		{Types: map[string]bool{"NodeBMC": true}, Pattern: "{id}-mgmt", Filter: mustParseMatch("group:mgmt")},
+	}
+	p.zones[0].TXT = []string{"role", "enabled"}
+	p.initZones()
+	p.cache.Groups = map[string][]string{"bmc001": {"mgmt"}}
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		want  []string
+	}{
+		{qname: "nid0001.cluster.local.", qtype: dns.TypeA, want: []string{"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.10"}},
+		{qname: "mgmt002.cluster.local.", qtype: dns.TypeA, want: []string{"mgmt002.cluster.local.\t60\tIN\tA\t192.168.1.11"}},
+		{qname: "node002.cluster.local.", qtype: dns.TypeTXT, want: []string{"node002.cluster.local.\t60\tIN\tTXT\t\"role=Management\" \"enabled=true\""}},
+		{qname: "bmc001-mgmt.cluster.local.", qtype: dns.TypeA, want: []string{"bmc001-mgmt.cluster.local.\t60\tIN\tA\t192.168.1.100"}},
+		{qname: "nid0002.cluster.local.", qtype: dns.TypeA},
+		{qname: "nid0003.cluster.local.", qtype: dns.TypeA},
+		{qname: "node003.cluster.local.", qtype: dns.TypeA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.qname+" "+dns.TypeToString[tt.qtype], func(t *testing.T) {
+			req := new(dns.Msg)
+			req.SetQuestion(tt.qname, tt.qtype)
+			w := &mockResponseWriter{}
+			rcode, err := p.ServeDNS(context.Background(), w, req)
+			if err != nil {
+				t.Fatalf("ServeDNS failed: %v", err)
+			}
+			if tt.want == nil {
+				checkNegativeResponse(t, w.msg, rcode, dns.RcodeNameError, "cluster.local.")
+				return
+			}
+			var got []string
+			for _, rr := range w.msg.Answer {
+				got = append(got, rr.String())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("answers =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/openchami/coresmd/internal/hostname"
+)
+
+// recordIndex holds the DNS records derived from one generation of the SMD
+// cache so that queries don't need to scan the cache.
+type recordIndex struct {
+	generation uint64                  // cache generation the index was built from
+	forward    map[string][]net.IP     // lowercase FQDN (without trailing dot) to addresses
+	ptr        map[string]string       // lowercase reverse name (without trailing dot) to PTR target
+	cname      map[string]string       // lowercase alias (without trailing dot) to its target
+	srv        map[string][]srvTarget  // lowercase service name (without trailing dot) to its targets
+	txt        map[string][]string     // lowercase name (without trailing dot) to TXT strings
+	names      map[string]bool         // names owning records and all of their ancestors
+	zones      map[string]*zoneContent // contents of each configured zone by origin
+}
+
+// indexHolder shares the current recordIndex between copies of the Plugin.
+type indexHolder struct {
+	mu      sync.Mutex // serializes rebuilds
+	current atomic.Pointer[recordIndex]
+}
+
+// records returns an index that is up to date with the cache, rebuilding it if
+// the cache has changed since it was built. Rebuilds normally happen when the
+// cache is updated (see rebuildIndex), so queries only pay for a rebuild if
+// they race with an update.
+func (p *Plugin) records() *recordIndex {
+	p.cache.Mutex.RLock()
+	gen := p.cache.Generation
+	p.cache.Mutex.RUnlock()
+
+	if idx := p.index.current.Load(); idx != nil && idx.generation == gen {
+		return idx
+	}
+	return p.rebuildIndex()
+}
+
+// rebuildIndex builds a new index from the cache and swaps it in, unless
+// another rebuild already brought the index up to date.
+func (p *Plugin) rebuildIndex() *recordIndex {
+	p.index.mu.Lock()
+	defer p.index.mu.Unlock()
+
+	p.cache.Mutex.RLock()
+	gen := p.cache.Generation
+	p.cache.Mutex.RUnlock()
+	if idx := p.index.current.Load(); idx != nil && idx.generation == gen {
+		return idx
+	}
+
+	idx := p.buildIndex()
+	var changed []string
+	if prev := p.index.current.Load(); prev != nil {
+		changed = idx.carryOver(prev)
+	}
+	p.index.current.Store(idx)
+	log.Debugf("rebuilt DNS record index with %d names and %d PTR records", len(idx.forward), len(idx.ptr))
+
+	// Let secondaries know that they are out of date
+	if p.transfer != nil {
+		for _, origin := range changed {
+			go func() {
+				if err := p.transfer.Notify(origin); err != nil {
+					log.Warnf("failed to notify secondaries of changes to zone %s: %v", origin, err)
+				}
+			}()
+		}
+	}
+	return idx
+}
+
+// buildIndex builds the forward and reverse records for the current contents
+// of the cache. Interfaces are visited in order of MAC address so that the
+// result doesn't depend on map iteration order.
+func (p *Plugin) buildIndex() *recordIndex {
+	p.cache.Mutex.RLock()
+	defer p.cache.Mutex.RUnlock()
+
+	idx := &recordIndex{
+		generation: p.cache.Generation,
+		forward:    make(map[string][]net.IP),
+		ptr:        make(map[string]string),
+		cname:      make(map[string]string),
+		srv:        make(map[string][]srvTarget),
+		txt:        make(map[string][]string),
+		names:      make(map[string]bool),
+	}
+	// Record names and their ancestors so that empty non-terminals (e.g. the
+	// "bmc" in x3000c0s1b0.bmc.cluster.local) exist
+	addName := func(name string) {
+		for n := name; n != "" && !idx.names[n]; {
+			idx.names[n] = true
+			_, n, _ = strings.Cut(n, ".")
+		}
+	}
+	add := func(name string, ip net.IP) {
+		name = strings.ToLower(name)
+		for _, existing := range idx.forward[name] {
+			if existing.Equal(ip) {
+				return
+			}
+		}
+		idx.forward[name] = append(idx.forward[name], ip)
+		addName(name)
+	}
+	// Forward names of each address in order of preference as PTR target:
+	// rule names, then names in zone order
+	var addrs []netip.Addr
+	ptrNames := make(map[netip.Addr][]string)
+
+	macs := make([]string, 0, len(p.cache.EthernetInterfaces))
+	for mac := range p.cache.EthernetInterfaces {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+
+	for _, mac := range macs {
+		ei := p.cache.EthernetInterfaces[mac]
+		comp, ok := p.cache.Components[ei.ComponentID]
+		if !ok {
+			continue
+		}
+
+		// Names from zone configuration cover all addresses of the interface
+		info := p.componentInfo(comp)
+		var zoneNames []string
+		for _, zone := range p.zones {
+			for _, host := range zone.hostnames(comp, info) {
+				zoneNames = append(zoneNames, host+"."+zone.Name)
+			}
+		}
+
+		for _, ipEntry := range ei.IPAddresses {
+			addr, err := netip.ParseAddr(ipEntry.IPAddress)
+			if err != nil {
+				continue
+			}
+			addr = addr.Unmap()
+			ip := net.IP(addr.AsSlice())
+
+			for _, name := range zoneNames {
+				add(name, ip)
+			}
+			ruleNames := p.ruleNames(ei, comp, ip)
+			for _, name := range ruleNames {
+				add(name, ip)
+			}
+
+			names := append(ruleNames, zoneNames...)
+			if len(names) == 0 {
+				continue
+			}
+			if _, ok := ptrNames[addr]; !ok {
+				addrs = append(addrs, addr)
+			}
+			ptrNames[addr] = append(ptrNames[addr], names...)
+		}
+	}
+
+	// Without reverse zones, PTR records are published for every address
+	// with the most preferred name as target. Otherwise, they are only
+	// published in the reverse zone containing the address, and the target
+	// is in the zone's forward zone if possible.
+	reverseZones := p.reverseZones()
+	zm := NewZoneManager(p.zones)
+	for _, addr := range addrs {
+		names := ptrNames[addr]
+		if len(reverseZones) == 0 {
+			name := strings.TrimSuffix(mustReverseAddr(addr), ".")
+			idx.ptr[name] = strings.ToLower(names[0])
+			addName(name)
+			continue
+		}
+		if rz := reverseZoneFor(reverseZones, addr); rz != nil {
+			name := rz.ptrName(addr)
+			idx.ptr[name] = strings.ToLower(rz.ptrTarget(names, zm))
+			addName(name)
+		}
+	}
+
+	p.indexInventoryRecords(idx, addName)
+
+	idx.zones = p.zoneContents(idx, uint32(time.Now().Unix()))
+	return idx
+}
+
+// srvTarget is the target of a SRV record.
+type srvTarget struct {
+	port   uint16
+	target string // lowercase FQDN without trailing dot
+}
+
+// indexInventoryRecords adds the CNAME, SRV, and TXT records configured in the
+// zones to idx, which must already hold the address records. Records are only
+// published for components whose xname has addresses in the zone, and an
+// alias is only published if the name isn't otherwise in use.
+func (p *Plugin) indexInventoryRecords(idx *recordIndex, addName func(string)) {
+	ids := make([]string, 0, len(p.cache.Components))
+	for id := range p.cache.Components {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, z := range p.zones {
+		if len(z.CNAMEs) == 0 && len(z.SRVs) == 0 && len(z.TXT) == 0 {
+			continue
+		}
+		for _, id := range ids {
+			comp := p.cache.Components[id]
+			info := p.componentInfo(comp)
+			if z.hostnames(comp, info) == nil {
+				continue
+			}
+			xname := strings.ToLower(comp.ID + "." + z.Name)
+			if len(idx.forward[xname]) == 0 {
+				continue
+			}
+
+			for _, rt := range z.CNAMEs {
+				if !rt.Matches(info) {
+					continue
+				}
+				alias := strings.ToLower(hostname.ExpandHostnamePattern(rt.Pattern, comp.NID, comp.ID) + "." + z.Name)
+				if alias != xname && !idx.names[alias] {
+					idx.cname[alias] = xname
+					addName(alias)
+				}
+				break
+			}
+
+			for _, sr := range z.SRVs {
+				if !sr.Matches(comp.Type) {
+					continue
+				}
+				target := srvTarget{port: sr.Port, target: xname}
+				for _, owner := range []string{sr.Service + "." + z.Name, sr.Service + "." + xname} {
+					owner = strings.ToLower(owner)
+					idx.srv[owner] = append(idx.srv[owner], target)
+					addName(owner)
+				}
+			}
+
+			var txt []string
+			for _, key := range z.TXT {
+				if v, ok := txtValue(comp, key); ok {
+					txt = append(txt, key+"="+v)
+				}
+			}
+			if len(txt) > 0 {
+				idx.txt[xname] = txt
+			}
+		}
+	}
+}
+
+// lookup returns the addresses published for name.
+func (idx *recordIndex) lookup(name string) []net.IP {
+	return idx.forward[strings.ToLower(strings.TrimSuffix(name, "."))]
+}
+
+// exists returns true if name owns records or is an ancestor of a name that
+// does.
+func (idx *recordIndex) exists(name string) bool {
+	return idx.names[strings.ToLower(strings.TrimSuffix(name, "."))]
+}
+
+// lookupRecords returns the records of type qtype (or of all types for ANY)
+// published for name, named owner. Aliases are not followed.
+func (idx *recordIndex) lookupRecords(owner, name string, qtype uint16) []dns.RR {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	var rrs []dns.RR
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeANY:
+		rrs = addressRecords(owner, qtype, idx.forward[name])
+	}
+	if target, ok := idx.cname[name]; ok && (qtype == dns.TypeCNAME || qtype == dns.TypeANY) {
+		rrs = append(rrs, cnameRecord(owner, target))
+	}
+	if qtype == dns.TypeSRV || qtype == dns.TypeANY {
+		for _, t := range idx.srv[name] {
+			rrs = append(rrs, srvRecord(owner, t.port, t.target))
+		}
+	}
+	if txt, ok := idx.txt[name]; ok && (qtype == dns.TypeTXT || qtype == dns.TypeANY) {
+		rrs = append(rrs, txtRecord(owner, txt))
+	}
+	return rrs
+}
+
+// lookupPTR returns the PTR target published for the reverse name name, or an
+// empty string if there is none.
+func (idx *recordIndex) lookupPTR(name string) string {
+	return idx.ptr[strings.ToLower(strings.TrimSuffix(name, "."))]
+}
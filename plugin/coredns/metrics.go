@@ -60,4 +60,33 @@ var (
 		Name:      "smd_cache_size",
 		Help:      "Number of entries in the SMD cache.",
 	}, []string{"server", "type"})
+
+	// SMDCacheRefreshes is the number of successful SMD cache refreshes by kind
+	// (full or incremental)
+	SMDCacheRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "coresmd",
+		Name:      "smd_cache_refreshes_total",
+		Help:      "Counter of successful SMD cache refreshes by kind (full or incremental).",
+	}, []string{"server", "kind"})
+
+	// SMDCacheFromSnapshot is 1 if the SMD cache is serving data loaded from a
+	// snapshot file that has not been refreshed from SMD since
+	SMDCacheFromSnapshot = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "coresmd",
+		Name:      "smd_cache_from_snapshot",
+		Help:      "Whether the SMD cache is serving stale data loaded from a snapshot (1) or not (0).",
+	}, []string{"server"})
+
+	// SMDCacheHWAddrProblems is the number of EthernetInterfaces in SMD with
+	// unparseable hardware addresses (kind "invalid") or left out of the cache
+	// because their normalized hardware address is that of another (kind
+	// "collision")
+	SMDCacheHWAddrProblems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "coresmd",
+		Name:      "smd_cache_hwaddr_problems",
+		Help:      "Number of EthernetInterfaces in SMD with unparseable (invalid) or duplicate (collision) hardware addresses.",
+	}, []string{"server", "kind"})
 )
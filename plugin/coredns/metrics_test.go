@@ -25,6 +25,8 @@ func TestMetricsRegistration(t *testing.T) {
 		CacheMisses,
 		SMDCacheAge,
 		SMDCacheSize,
+		SMDCacheRefreshes,
+		SMDCacheFromSnapshot,
 	}
 
 	for _, metric := range metrics {
@@ -69,6 +71,17 @@ func TestReadyFunction(t *testing.T) {
 	if p.Ready() {
 		t.Error("Expected Ready() to return false for old cache")
 	}
+
+	// Test Ready function with cache loaded from a recent and a stale snapshot
+	p.cache.LoadedFromSnapshot = true
+	p.cache.LastUpdated = time.Now().Add(-1 * time.Minute)
+	if !p.Ready() {
+		t.Error("Expected Ready() to return true for cache loaded from recent snapshot")
+	}
+	p.cache.LastUpdated = time.Now().Add(-1 * time.Hour)
+	if p.Ready() {
+		t.Error("Expected Ready() to return false for cache loaded from stale snapshot")
+	}
 }
 
 func TestHealthFunction(t *testing.T) {
@@ -6,10 +6,14 @@
 package plugin
 
 import (
+	"context"
+	"fmt"
 	"time"
 )
 
 // Ready checks if the plugin's cache is initialized, has been updated at least once (i.e., LastUpdated is non-zero), and is not older than 5 minutes.
+// If the cache was loaded from a snapshot because SMD was unavailable, LastUpdated is the time the snapshot's data was fetched,
+// so a stale snapshot is reported as not ready.
 // It implements the ready.Readiness interface (Ready() bool) for https://coredns.io/plugins/ready/.
 // Returns true if the cache is ready, otherwise false.
 func (p Plugin) Ready() bool {
@@ -39,7 +43,19 @@ func (p Plugin) OnStartupComplete() error {
 	return nil
 }
 
-func (p Plugin) OnShutdown() error {
-	// Plugin shutdown is complete
+// OnShutdown stops the event sources started by OnStartup so that a new
+// instance of the plugin (e.g. after a Corefile reload) can take over.
+func (p *Plugin) OnShutdown() error {
+	if p.stop != nil {
+		p.stop()
+	}
+	if p.eventServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := p.eventServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shut down cache event listener: %w", err)
+		}
+		p.eventServer = nil
+	}
 	return nil
 }
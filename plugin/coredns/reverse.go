@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ReverseZone is a reverse (in-addr.arpa or ip6.arpa) zone the plugin is
+// authoritative for. PTR targets of the addresses in it are taken from the
+// forward zone it was declared in.
+type ReverseZone struct {
+	Zone                 // Name is the reverse zone name; NS, Hostmaster, and TTLs are those of the forward zone
+	Prefix  netip.Prefix // Addresses whose PTR records are in the zone
+	Forward string       // Name of the forward zone providing PTR targets
+}
+
+// newReverseZones creates the reverse zones covering prefix for forward zone
+// fwd. Prefixes that don't end on an octet (IPv4) or nibble (IPv6) boundary
+// are split into zones that do, except that IPv4 prefixes longer than /24 get
+// a single RFC 2317 classless zone, named e.g. 0/26.1.168.192.in-addr.arpa
+// unless name is set. name may only be set if prefix results in one zone.
+func newReverseZones(prefix netip.Prefix, name string, fwd Zone) ([]ReverseZone, error) {
+	prefix = prefix.Masked()
+	step := 4
+	if prefix.Addr().Is4() {
+		step = 8
+	}
+
+	prefixes := []netip.Prefix{prefix}
+	if bits := prefix.Bits(); bits%step != 0 && !isClassless(prefix) {
+		// Split into 2^n prefixes ending on the next boundary
+		aligned := bits + step - bits%step
+		prefixes = nil
+		for p := netip.PrefixFrom(prefix.Addr(), aligned); prefix.Contains(p.Addr()); {
+			prefixes = append(prefixes, p)
+			next := lastAddr(p).Next()
+			if !next.IsValid() {
+				break
+			}
+			p = netip.PrefixFrom(next, aligned)
+		}
+	}
+	if name != "" && len(prefixes) != 1 {
+		return nil, fmt.Errorf("reverse zone name '%s' given for %s, which spans %d zones", name, prefix, len(prefixes))
+	}
+
+	hostmaster := fwd.Hostmaster
+	if hostmaster == "" {
+		hostmaster = "hostmaster." + fwd.origin()
+	}
+
+	var zones []ReverseZone
+	for _, p := range prefixes {
+		rz := ReverseZone{
+			Zone:    Zone{Name: name, NS: fwd.nameservers(), Hostmaster: hostmaster, TTLs: fwd.TTLs, CapTTL: fwd.CapTTL},
+			Prefix:  p,
+			Forward: fwd.Name,
+		}
+		if rz.Name == "" {
+			rz.Name = reverseZoneName(p)
+		}
+		rz.Name = strings.ToLower(strings.TrimSuffix(rz.Name, "."))
+		zones = append(zones, rz)
+	}
+	return zones, nil
+}
+
+// isClassless returns true if prefix is an IPv4 network smaller than a /24,
+// whose reverse zone needs RFC 2317 classless delegation.
+func isClassless(prefix netip.Prefix) bool {
+	return prefix.Addr().Is4() && prefix.Bits() > 24 && prefix.Bits() < 32
+}
+
+// lastAddr returns the last address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for i := prefix.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// reverseZoneName returns the default name of the reverse zone for prefix,
+// which must end on an octet (IPv4) or nibble (IPv6) boundary or be
+// classless.
+func reverseZoneName(prefix netip.Prefix) string {
+	full := strings.TrimSuffix(mustReverseAddr(prefix.Addr()), ".")
+	labels := strings.Split(full, ".")
+	if isClassless(prefix) {
+		// RFC 2317: <first address>/<prefix length>.<c>.<b>.<a>.in-addr.arpa
+		return fmt.Sprintf("%s/%d.%s", labels[0], prefix.Bits(), strings.Join(labels[1:], "."))
+	}
+
+	// Keep the labels covered by the prefix plus the in-addr.arpa or
+	// ip6.arpa suffix
+	keep := prefix.Bits()/8 + 2
+	if prefix.Addr().Is6() {
+		keep = prefix.Bits()/4 + 2
+	}
+	return strings.Join(labels[len(labels)-keep:], ".")
+}
+
+// ptrName returns the name of the PTR record for addr in the zone.
+func (rz ReverseZone) ptrName(addr netip.Addr) string {
+	if isClassless(rz.Prefix) {
+		return fmt.Sprintf("%d.%s", addr.As4()[3], rz.Name)
+	}
+	return strings.TrimSuffix(mustReverseAddr(addr), ".")
+}
+
+// ptrTarget chooses the PTR target from names, the forward names of an
+// address in order of preference: the first name in the zone's forward zone,
+// or the first name if there is none.
+func (rz ReverseZone) ptrTarget(names []string, zm *ZoneManager) string {
+	for _, name := range names {
+		if z := zm.FindZone(name); z != nil && strings.EqualFold(z.Name, rz.Forward) {
+			return name
+		}
+	}
+	return names[0]
+}
+
+// mustReverseAddr returns the reverse lookup name of a valid addr.
+func mustReverseAddr(addr netip.Addr) string {
+	name, err := dns.ReverseAddr(addr.String())
+	if err != nil {
+		panic(err)
+	}
+	return strings.ToLower(name)
+}
+
+// reverseZones returns the reverse zones declared in all zones.
+func (p *Plugin) reverseZones() []ReverseZone {
+	var zones []ReverseZone
+	for _, z := range p.zones {
+		zones = append(zones, z.Reverse...)
+	}
+	return zones
+}
+
+// allZones returns the forward and reverse zones the plugin is authoritative
+// for.
+func (p *Plugin) allZones() []Zone {
+	zones := append([]Zone(nil), p.zones...)
+	for _, rz := range p.reverseZones() {
+		zones = append(zones, rz.Zone)
+	}
+	return zones
+}
+
+// initZones sets up the lookup of the zones returned by allZones. It must be
+// called whenever the zones change so that queries don't need to collect them.
+func (p *Plugin) initZones() {
+	p.zm = NewZoneManager(p.allZones())
+}
+
+// reverseZoneFor returns the most specific reverse zone containing addr, or
+// nil if there is none.
+func reverseZoneFor(zones []ReverseZone, addr netip.Addr) *ReverseZone {
+	var found *ReverseZone
+	for i := range zones {
+		if zones[i].Prefix.Contains(addr) && (found == nil || zones[i].Prefix.Bits() > found.Prefix.Bits()) {
+			found = &zones[i]
+		}
+	}
+	return found
+}
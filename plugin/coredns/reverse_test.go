@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestNewReverseZones(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		name    string
+		want    []string
+		wantErr bool
+	}{
+		{prefix: "10.1.0.0/16", want: []string{"1.10.in-addr.arpa"}},
+		{prefix: "192.168.1.0/24", want: []string{"1.168.192.in-addr.arpa"}},
+		{prefix: "10.0.0.0/22", want: []string{"0.0.10.in-addr.arpa", "1.0.10.in-addr.arpa", "2.0.10.in-addr.arpa", "3.0.10.in-addr.arpa"}},
+		{prefix: "192.168.1.64/26", want: []string{"64/26.1.168.192.in-addr.arpa"}},
+		{prefix: "192.168.1.70/26", want: []string{"64/26.1.168.192.in-addr.arpa"}},
+		{prefix: "192.168.1.64/26", name: "64-26.1.168.192.in-addr.arpa.", want: []string{"64-26.1.168.192.in-addr.arpa"}},
+		{prefix: "2001:db8::/32", want: []string{"8.b.d.0.1.0.0.2.ip6.arpa"}},
+		{prefix: "2001:db8::/31", want: []string{"8.b.d.0.1.0.0.2.ip6.arpa", "9.b.d.0.1.0.0.2.ip6.arpa"}},
+		{prefix: "10.0.0.0/23", name: "x.in-addr.arpa", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.name, func(t *testing.T) {
+			zones, err := newReverseZones(netip.MustParsePrefix(tt.prefix), tt.name, Zone{Name: "cluster.local"})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got zones %v", zones)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var got []string
+			for _, rz := range zones {
+				got = append(got, rz.Name)
+				if rz.Forward != "cluster.local" {
+					t.Errorf("zone %s forward zone = %q, want cluster.local", rz.Name, rz.Forward)
+				}
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("zones = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// createTestPluginWithReverseZones creates a plugin whose cluster.local zone
+// has the reverse zone 1.168.192.in-addr.arpa, and whose mgmt.local zone
+// publishes BMCs and has the classless reverse zone 96/27.1.168.192.in-addr.arpa.
+func createTestPluginWithReverseZones(t *testing.T) *Plugin {
+	t.Helper()
+
+	p := createTestPlugin()
+	p.zones = append(p.zones, Zone{
+		Name:    "mgmt.local",
+		Records: []RecordType{{Types: map[string]bool{"NodeBMC": true}}},
+	})
+	for i, prefix := range []string{"192.168.1.0/24", "192.168.1.96/27"} {
+		zones, err := newReverseZones(netip.MustParsePrefix(prefix), "", p.zones[i])
+		if err != nil {
+			t.Fatalf("newReverseZones(%s) unexpected error: %v", prefix, err)
+		}
+		p.zones[i].Reverse = zones
+	}
+	p.initZones()
+	return p
+}
+
+func TestServeDNS_ReverseZones(t *testing.T) {
+	p := createTestPluginWithReverseZones(t)
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		wantPTR   string
+		wantRcode int
+		wantSOA   string // zone of the SOA in the authority section
+		wantNext  bool
+	}{
+		{
+			name:    "PTR target from declaring zone",
+			qname:   "10.1.168.192.in-addr.arpa.",
+			qtype:   dns.TypePTR,
+			wantPTR: "node001.cluster.local.",
+		},
+		{
+			name:    "classless PTR target from declaring zone",
+			qname:   "100.96/27.1.168.192.in-addr.arpa.",
+			qtype:   dns.TypePTR,
+			wantPTR: "bmc001.mgmt.local.",
+		},
+		{
+			name:      "miss in reverse zone",
+			qname:     "11.1.168.192.in-addr.arpa.",
+			qtype:     dns.TypePTR,
+			wantRcode: dns.RcodeNameError,
+			wantSOA:   "1.168.192.in-addr.arpa.",
+		},
+		{
+			name:      "address in classless zone not published in parent zone",
+			qname:     "100.1.168.192.in-addr.arpa.",
+			qtype:     dns.TypePTR,
+			wantRcode: dns.RcodeNameError,
+			wantSOA:   "1.168.192.in-addr.arpa.",
+		},
+		{
+			name:      "miss in classless zone",
+			qname:     "101.96/27.1.168.192.in-addr.arpa.",
+			qtype:     dns.TypePTR,
+			wantRcode: dns.RcodeNameError,
+			wantSOA:   "96/27.1.168.192.in-addr.arpa.",
+		},
+		{
+			name:      "other type of existing name",
+			qname:     "10.1.168.192.in-addr.arpa.",
+			qtype:     dns.TypeA,
+			wantRcode: dns.RcodeSuccess,
+			wantSOA:   "1.168.192.in-addr.arpa.",
+		},
+		{
+			name:     "outside reverse zones",
+			qname:    "1.1.1.1.in-addr.arpa.",
+			qtype:    dns.TypePTR,
+			wantNext: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockHandler{}
+			p.Next = mock
+
+			req := new(dns.Msg)
+			req.SetQuestion(tt.qname, tt.qtype)
+			w := &mockResponseWriter{}
+			rcode, err := p.ServeDNS(context.Background(), w, req)
+			if err != nil {
+				t.Fatalf("ServeDNS failed: %v", err)
+			}
+
+			switch {
+			case tt.wantNext:
+				if !mock.called {
+					t.Error("Expected next plugin to be called")
+				}
+			case tt.wantPTR != "":
+				if len(w.msg.Answer) != 1 {
+					t.Fatalf("Expected 1 answer, got %v", w.msg.Answer)
+				}
+				if ptr := w.msg.Answer[0].(*dns.PTR).Ptr; ptr != tt.wantPTR {
+					t.Errorf("PTR = %s, want %s", ptr, tt.wantPTR)
+				}
+			default:
+				checkNegativeResponse(t, w.msg, rcode, tt.wantRcode, tt.wantSOA)
+			}
+		})
+	}
+}
+
+func TestTransfer_ReverseZone(t *testing.T) {
+	p := createTestPluginWithReverseZones(t)
+
+	rrs := collectTransfer(t, p, "96/27.1.168.192.in-addr.arpa.", 0)
+	var got []string
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeSOA {
+			got = append(got, rr.String())
+		}
+	}
+	want := []string{
+		"96/27.1.168.192.in-addr.arpa.\t60\tIN\tNS\tns.mgmt.local.",
+		"100.96/27.1.168.192.in-addr.arpa.\t60\tIN\tPTR\tbmc001.mgmt.local.",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("AXFR records =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"net"
+	"strings"
+
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/rule"
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// ruleNames returns the FQDNs (without trailing dots) that the configured
+// rules assign to ip of interface ei, which belongs to comp. Rules are
+// evaluated as CoreDHCP does for a request served from ip, so the name matches
+// the hostname handed out over DHCP. A name without a domain is published in
+// every zone.
+func (p *Plugin) ruleNames(ei smdclient.EthernetInterface, comp smdclient.Component, ip net.IP) []string {
+	if len(p.rules) == 0 {
+		return nil
+	}
+
+	ii := iface.NewIfaceInfo(ei, comp)
+	ii.Groups, ii.Partition = p.cache.Membership(comp.ID)
+	ii.IPList = []net.IP{ip}
+	host, fqdn, ok := rule.Hostname(ii, p.domain, p.rules)
+	if !ok || fqdn == "" {
+		return nil
+	}
+	if fqdn != host {
+		return []string{strings.TrimSuffix(fqdn, ".")}
+	}
+
+	names := make([]string, 0, len(p.zones))
+	for _, zone := range p.zones {
+		names = append(names, host+"."+zone.Name)
+	}
+	return names
+}
+
+// componentInfo describes comp, including its group and partition membership,
+// for matching against zone filters. The cache must be locked.
+func (p *Plugin) componentInfo(comp smdclient.Component) iface.IfaceInfo {
+	info := iface.ComponentInfo(comp)
+	info.Groups, info.Partition = p.cache.Membership(comp.ID)
+	return info
+}
@@ -6,16 +6,28 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/fall"
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/miekg/dns"
 	"github.com/sirupsen/logrus"
 
 	"github.com/openchami/coresmd/internal/cache"
+	"github.com/openchami/coresmd/internal/rule"
 	"github.com/openchami/coresmd/internal/smdclient"
 	"github.com/openchami/coresmd/internal/version"
 )
@@ -27,14 +39,33 @@ type Plugin struct {
 	// SMD connection settings
 	smdURL        string
 	caCert        string
+	auth          smdclient.AuthConfig
+	smdHTTP       smdclient.HTTPConfig
 	cacheDuration string
+	fullResync    time.Duration
+	eventListen   string
+	eventToken    string
+	eventFeed     string
+	cacheSnapshot string
 
 	// Zone configuration
 	zones []Zone
+	Fall  fall.F // zones in which names that don't exist are passed to the next plugin
+
+	// Naming rules shared with CoreDHCP
+	domain string
+	rules  []rule.Rule
 
 	// Shared infrastructure
 	cache     *cache.Cache
 	smdClient *smdclient.SmdClient
+	index     *indexHolder       // set by parse and shared by copies of the Plugin
+	zm        *ZoneManager       // forward and reverse zones, set by parse
+	transfer  *transfer.Transfer // notified when zones change, if configured
+
+	// Background work started by OnStartup and stopped by OnShutdown
+	eventServer *http.Server
+	stop        context.CancelFunc
 }
 
 // Global variables
@@ -60,17 +91,34 @@ func setup(c *caddy.Controller) error {
 	})
 
 	// Register metrics and readiness hooks
+	c.OnShutdown(coresmd.OnShutdown)
 	c.OnStartup(func() error {
+		// Send NOTIFYs through the transfer plugin, if it is configured
+		if t, ok := dnsserver.GetConfig(c).Handler("transfer").(*transfer.Transfer); ok {
+			coresmd.transfer = t
+		}
+
 		// Call plugin OnStartup for version logging and initialization
 		if err := coresmd.OnStartup(); err != nil {
 			return err
 		}
 
 		// Update cache metrics periodically
+		ctx, cancel := context.WithCancel(context.Background())
+		c.OnShutdown(func() error {
+			cancel()
+			return nil
+		})
 		go func() {
+			var lastFull, lastIncremental uint64
 			ticker := time.NewTicker(30 * time.Second)
 			defer ticker.Stop()
-			for range ticker.C {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
 				if coresmd.cache != nil {
 					coresmd.cache.Mutex.RLock()
 					if !coresmd.cache.LastUpdated.IsZero() {
@@ -78,8 +126,22 @@ func setup(c *caddy.Controller) error {
 						SMDCacheAge.WithLabelValues("default").Set(age)
 						SMDCacheSize.WithLabelValues("default", "ethernet_interfaces").Set(float64(len(coresmd.cache.EthernetInterfaces)))
 						SMDCacheSize.WithLabelValues("default", "components").Set(float64(len(coresmd.cache.Components)))
+						SMDCacheHWAddrProblems.WithLabelValues("default", "invalid").Set(float64(len(coresmd.cache.InvalidHWAddrs)))
+						SMDCacheHWAddrProblems.WithLabelValues("default", "collision").Set(float64(len(coresmd.cache.HWAddrCollisions)))
 					}
+					full, incremental := coresmd.cache.FullRefreshes, coresmd.cache.IncrementalRefreshes
+					stale := coresmd.cache.LoadedFromSnapshot
 					coresmd.cache.Mutex.RUnlock()
+
+					if stale {
+						SMDCacheFromSnapshot.WithLabelValues("default").Set(1)
+					} else {
+						SMDCacheFromSnapshot.WithLabelValues("default").Set(0)
+					}
+
+					SMDCacheRefreshes.WithLabelValues("default", "full").Add(float64(full - lastFull))
+					SMDCacheRefreshes.WithLabelValues("default", "incremental").Add(float64(incremental - lastIncremental))
+					lastFull, lastIncremental = full, incremental
 				}
 			}
 		}()
@@ -91,7 +153,7 @@ func setup(c *caddy.Controller) error {
 
 // parse parses the Corefile configuration for the coresmd plugin
 func parse(c *caddy.Controller) (*Plugin, error) {
-	p := &Plugin{}
+	p := &Plugin{index: &indexHolder{}}
 
 	// The outer for c.Next() handles each "coresmd" stanza in the Corefile.
 	// Typically you'd have only one, but this loop allows multiple if needed.
@@ -122,6 +184,113 @@ func parse(c *caddy.Controller) (*Plugin, error) {
 				p.caCert = c.Val()
 				log.Debugf("Set ca_cert to: %s", p.caCert)
 
+			case "token_file":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.auth.TokenFile = c.Val()
+				log.Debugf("Set token_file to: %s", p.auth.TokenFile)
+
+			case "token_url":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				if u, err := url.Parse(c.Val()); err != nil || !u.IsAbs() {
+					return nil, c.Errf("invalid token_url '%s'", c.Val())
+				}
+				p.auth.TokenURL = c.Val()
+				log.Debugf("Set token_url to: %s", p.auth.TokenURL)
+
+			case "client_id":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.auth.ClientID = c.Val()
+				log.Debugf("Set client_id to: %s", p.auth.ClientID)
+
+			case "client_secret_file":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.auth.ClientSecretFile = c.Val()
+				log.Debugf("Set client_secret_file to: %s", p.auth.ClientSecretFile)
+
+			case "token_scopes":
+				p.auth.Scopes = c.RemainingArgs()
+				if len(p.auth.Scopes) == 0 {
+					return nil, c.ArgErr()
+				}
+				log.Debugf("Set token_scopes to: %v", p.auth.Scopes)
+
+			case "client_cert":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.auth.ClientCert = c.Val()
+				log.Debugf("Set client_cert to: %s", p.auth.ClientCert)
+
+			case "client_key":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.auth.ClientKey = c.Val()
+				log.Debugf("Set client_key to: %s", p.auth.ClientKey)
+
+			case "smd_timeout":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid smd_timeout '%s': %v", c.Val(), err)
+				}
+				p.smdHTTP.Timeout = &d
+				log.Debugf("Set smd_timeout to: %s", d)
+
+			case "smd_retries":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid smd_retries '%s': %v", c.Val(), err)
+				}
+				p.smdHTTP.Retries = &n
+				log.Debugf("Set smd_retries to: %d", n)
+
+			case "smd_retry_backoff":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid smd_retry_backoff '%s': %v", c.Val(), err)
+				}
+				p.smdHTTP.RetryBackoff = &d
+				log.Debugf("Set smd_retry_backoff to: %s", d)
+
+			case "smd_keepalive":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				b, err := strconv.ParseBool(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid smd_keepalive '%s': %v", c.Val(), err)
+				}
+				p.smdHTTP.KeepAlive = &b
+				log.Debugf("Set smd_keepalive to: %v", b)
+
+			case "smd_max_idle_conns":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid smd_max_idle_conns '%s': %v", c.Val(), err)
+				}
+				p.smdHTTP.MaxIdleConns = &n
+				log.Debugf("Set smd_max_idle_conns to: %d", n)
+
 			case "cache_duration":
 				if !c.NextArg() {
 					return nil, c.ArgErr()
@@ -129,6 +298,77 @@ func parse(c *caddy.Controller) (*Plugin, error) {
 				p.cacheDuration = c.Val()
 				log.Debugf("Set cache_duration to: %s", p.cacheDuration)
 
+			case "cache_full_resync":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid cache_full_resync '%s': %v", c.Val(), err)
+				}
+				p.fullResync = d
+				log.Debugf("Set cache_full_resync to: %s", p.fullResync)
+
+			case "cache_snapshot":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.cacheSnapshot = c.Val()
+				log.Debugf("Set cache_snapshot to: %s", p.cacheSnapshot)
+
+			case "event_listen":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				if _, _, err := net.SplitHostPort(c.Val()); err != nil {
+					return nil, c.Errf("invalid event_listen address '%s': %v", c.Val(), err)
+				}
+				p.eventListen = c.Val()
+				log.Debugf("Set event_listen to: %s", p.eventListen)
+
+			case "event_token_file":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.eventToken = c.Val()
+				log.Debugf("Set event_token_file to: %s", p.eventToken)
+
+			case "event_feed":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.eventFeed = c.Val()
+				log.Debugf("Set event_feed to: %s", p.eventFeed)
+
+			case "domain":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				p.domain = c.Val()
+				log.Debugf("Set domain to: %s", p.domain)
+
+			case "rule":
+				// Same syntax as the CoreDHCP rule config key, e.g.:
+				//   rule type:Node,hostname:nid{04d}
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				r, err := rule.ParseRule(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid rule '%s': %v", c.Val(), err)
+				}
+				if c.NextArg() {
+					return nil, c.Errf("rule takes a single argument; quote rules containing spaces")
+				}
+				p.rules = append(p.rules, r)
+				log.Debugf("Added rule: %s", r)
+
+			case "fallthrough":
+				// Example usage in Corefile:
+				//   fallthrough [zones...]
+				p.Fall.SetZonesFromArgs(c.RemainingArgs())
+				log.Debugf("Set fallthrough zones to: %v", p.Fall.Zones)
+
 			case "zone":
 				// Example usage in Corefile:
 				//   zone cluster.local {
@@ -160,6 +400,23 @@ func parse(c *caddy.Controller) (*Plugin, error) {
 	if p.cacheDuration == "" {
 		p.cacheDuration = "30s"
 	}
+	if p.eventListen != "" && p.eventToken == "" {
+		return nil, fmt.Errorf("event_listen requires event_token_file")
+	}
+	if errs := p.auth.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if errs := p.smdHTTP.Validate(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	seenZones := make(map[string]bool)
+	for _, z := range p.allZones() {
+		if seenZones[z.origin()] {
+			return nil, fmt.Errorf("zone '%s' is declared more than once", z.Name)
+		}
+		seenZones[z.origin()] = true
+	}
+	p.initZones()
 
 	return p, nil
 }
@@ -171,6 +428,13 @@ func parseZone(c *caddy.Controller, zoneName string) (Zone, error) {
 	// Track whether directives have already been seen to prevent duplicates
 	seenNodes := false
 
+	// Reverse zones are created once the zone's name servers are known
+	type reverseDecl struct {
+		prefix netip.Prefix
+		name   string
+	}
+	var reverse []reverseDecl
+
 	// Enter the block for the zone directive (consume the opening brace if present)
 	if !c.Next() {
 		return zone, c.Errf("expected opening brace or directive after zone name")
@@ -196,14 +460,236 @@ func parseZone(c *caddy.Controller, zoneName string) (Zone, error) {
 			}
 			zone.NodePattern = c.Val()
 			seenNodes = true
+		case "type":
+			// Example usage:
+			//   type MgmtSwitch|MgmtHLSwitch sw-{id}
+			//   type RouterBMC|ChassisBMC
+			//   type Node mgmt{03d} role:Management
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return zone, c.ArgErr()
+			}
+			rt := RecordType{Types: parseTypes(args[0])}
+			if len(rt.Types) == 0 {
+				return zone, c.Errf("invalid type '%s' in zone '%s': at least one type is required", args[0], zoneName)
+			}
+			// The filter is the last argument, distinguished from the
+			// pattern by its key
+			args = args[1:]
+			if n := len(args); n > 0 && strings.Contains(args[n-1], ":") {
+				m, err := parseFilter(args[n-1])
+				if err != nil {
+					return zone, c.Errf("invalid type filter '%s' in zone '%s': %v", args[n-1], zoneName, err)
+				}
+				rt.Filter = m
+				args = args[:n-1]
+			}
+			switch len(args) {
+			case 0:
+			case 1:
+				rt.Pattern = args[0]
+			default:
+				return zone, c.ArgErr()
+			}
+			zone.Records = append(zone.Records, rt)
+		case "filter":
+			// Example usage:
+			//   filter enabled:true,role:Compute|Application
+			if !zone.Filter.IsZero() {
+				return zone, c.Errf("duplicate 'filter' directive in zone '%s'", zoneName)
+			}
+			if !c.NextArg() {
+				return zone, c.ArgErr()
+			}
+			m, err := parseFilter(c.Val())
+			if err != nil {
+				return zone, c.Errf("invalid filter '%s' in zone '%s': %v", c.Val(), zoneName, err)
+			}
+			if c.NextArg() {
+				return zone, c.ArgErr()
+			}
+			zone.Filter = m
+		case "ns":
+			// Example usage:
+			//   ns ns1.cluster.local ns2.cluster.local
+			args := c.RemainingArgs()
+			if len(args) == 0 {
+				return zone, c.ArgErr()
+			}
+			for _, ns := range args {
+				if _, ok := dns.IsDomainName(ns); !ok {
+					return zone, c.Errf("invalid name server '%s' in zone '%s'", ns, zoneName)
+				}
+			}
+			zone.NS = append(zone.NS, args...)
+		case "hostmaster":
+			if !c.NextArg() {
+				return zone, c.ArgErr()
+			}
+			zone.Hostmaster = strings.Replace(c.Val(), "@", ".", 1)
+		case "cname":
+			// Example usage:
+			//   cname nid{04d}
+			//   cname sw-{id} MgmtSwitch|MgmtHLSwitch
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return zone, c.ArgErr()
+			}
+			rt := RecordType{Types: map[string]bool{"Node": true}, Pattern: args[0]}
+			if len(args) == 2 {
+				rt.Types = parseTypes(args[1])
+				if len(rt.Types) == 0 {
+					return zone, c.Errf("invalid cname types '%s' in zone '%s': at least one type is required", args[1], zoneName)
+				}
+			}
+			zone.CNAMEs = append(zone.CNAMEs, rt)
+		case "srv":
+			// Example usage:
+			//   srv _redfish._tcp 443
+			//   srv _ssh._tcp 22 Node|NodeBMC
+			args := c.RemainingArgs()
+			if len(args) < 2 || len(args) > 3 {
+				return zone, c.ArgErr()
+			}
+			labels := strings.Split(args[0], ".")
+			if len(labels) != 2 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+				return zone, c.Errf("invalid srv service '%s' in zone '%s': expected _service._proto", args[0], zoneName)
+			}
+			port, err := strconv.ParseUint(args[1], 10, 16)
+			if err != nil || port == 0 {
+				return zone, c.Errf("invalid srv port '%s' in zone '%s'", args[1], zoneName)
+			}
+			sr := ServiceRecord{Service: args[0], Port: uint16(port), Types: map[string]bool{"NodeBMC": true}}
+			if len(args) == 3 {
+				sr.Types = parseTypes(args[2])
+				if len(sr.Types) == 0 {
+					return zone, c.Errf("invalid srv types '%s' in zone '%s': at least one type is required", args[2], zoneName)
+				}
+			}
+			zone.SRVs = append(zone.SRVs, sr)
+		case "txt":
+			// Example usage:
+			//   txt
+			//   txt type nid
+			keys := c.RemainingArgs()
+			if len(keys) == 0 {
+				keys = []string{"type", "nid"}
+			}
+			for _, key := range keys {
+				if !slices.Contains(txtKeys, key) {
+					return zone, c.Errf("invalid txt key '%s' in zone '%s' (valid keys: %s)", key, zoneName, strings.Join(txtKeys, ", "))
+				}
+			}
+			zone.TXT = keys
+		case "ttl":
+			// Example usage:
+			//   ttl 5m
+			//   ttl PTR 3600
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return zone, c.ArgErr()
+			}
+			rrtype := dns.TypeNone
+			if len(args) == 2 {
+				t, ok := dns.StringToType[strings.ToUpper(args[0])]
+				if !ok || t == dns.TypeNone {
+					return zone, c.Errf("invalid record type '%s' for ttl in zone '%s'", args[0], zoneName)
+				}
+				rrtype = t
+			}
+			ttl, err := parseTTL(args[len(args)-1])
+			if err != nil {
+				return zone, c.Errf("invalid ttl '%s' in zone '%s': %v", args[len(args)-1], zoneName, err)
+			}
+			if zone.TTLs == nil {
+				zone.TTLs = make(map[uint16]uint32)
+			}
+			zone.TTLs[rrtype] = ttl
+		case "cap_ttl_to_refresh":
+			if c.NextArg() {
+				return zone, c.ArgErr()
+			}
+			zone.CapTTL = true
+		case "reverse":
+			// Example usage:
+			//   reverse 10.1.0.0/16
+			//   reverse 192.168.1.64/26 64-26.1.168.192.in-addr.arpa
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return zone, c.ArgErr()
+			}
+			prefix, err := netip.ParsePrefix(args[0])
+			if err != nil {
+				return zone, c.Errf("invalid reverse network '%s' in zone '%s': %v", args[0], zoneName, err)
+			}
+			decl := reverseDecl{prefix: prefix}
+			if len(args) == 2 {
+				if _, ok := dns.IsDomainName(args[1]); !ok {
+					return zone, c.Errf("invalid reverse zone name '%s' in zone '%s'", args[1], zoneName)
+				}
+				decl.name = args[1]
+			}
+			reverse = append(reverse, decl)
 		default:
 			return zone, c.Errf("unknown zone directive '%s'", directive)
 		}
 	}
 
+	for _, decl := range reverse {
+		zones, err := newReverseZones(decl.prefix, decl.name, zone)
+		if err != nil {
+			return zone, c.Errf("invalid reverse network in zone '%s': %v", zoneName, err)
+		}
+		zone.Reverse = append(zone.Reverse, zones...)
+	}
+
 	return zone, nil
 }
 
+// parseTypes parses a list of component types separated by "|".
+func parseTypes(s string) map[string]bool {
+	types := make(map[string]bool)
+	for _, typ := range strings.Split(s, "|") {
+		if typ = strings.TrimSpace(typ); typ != "" {
+			types[typ] = true
+		}
+	}
+	return types
+}
+
+// parseFilter parses component criteria in rule syntax. Components are
+// filtered before their addresses are considered, so subnets can't be matched.
+func parseFilter(s string) (rule.Match, error) {
+	m, err := rule.ParseMatch(s)
+	if err != nil {
+		return m, err
+	}
+	if len(m.Subnets) > 0 {
+		return m, fmt.Errorf("'subnet' can't be used to filter components")
+	}
+	return m, nil
+}
+
+// parseTTL parses a TTL given in seconds or as a duration (e.g. "5m").
+func parseTTL(s string) (uint32, error) {
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		d, derr := time.ParseDuration(s)
+		if derr != nil {
+			return 0, fmt.Errorf("expected seconds or a duration: %w", derr)
+		}
+		if d%time.Second != 0 {
+			return 0, fmt.Errorf("must be a whole number of seconds")
+		}
+		secs = int64(d / time.Second)
+	}
+	// RFC 2181 limits TTLs to 2^31-1 seconds
+	if secs < 0 || secs > math.MaxInt32 {
+		return 0, fmt.Errorf("must be between 0 and %d seconds", math.MaxInt32)
+	}
+	return uint32(secs), nil
+}
+
 // OnStartup is called when the plugin starts up
 func (p *Plugin) OnStartup() error {
 	// Log version information
@@ -211,8 +697,23 @@ func (p *Plugin) OnStartup() error {
 		version.Version, version.GitState, version.BuildTime)
 	log.WithFields(version.VersionInfo).Debugln("detailed version info")
 
+	// Set default zones if none configured
+	if len(p.zones) == 0 {
+		p.zones = []Zone{
+			{
+				Name:        "cluster.local",
+				NodePattern: "nid{04d}",
+			},
+		}
+	} else {
+		log.Infof("configured zones: %v", p.zones)
+	}
+
 	// Initialize shared cache if not already done
 	if p.cache == nil {
+		var ctx context.Context
+		ctx, p.stop = context.WithCancel(context.Background())
+
 		baseURL, err := url.Parse(p.smdURL)
 		if err != nil {
 			return fmt.Errorf("failed to parse SMD URL: %w", err)
@@ -230,30 +731,51 @@ func (p *Plugin) OnStartup() error {
 			log.Infof("CA certificate path was empty, not setting")
 		}
 
+		// Set up authentication (bearer token and/or client certificate)
+		if err := p.smdClient.UseAuth(p.auth); err != nil {
+			return fmt.Errorf("failed to configure SMD authentication: %w", err)
+		}
+
+		// Set up timeouts, retries, and connection reuse
+		if err := p.smdClient.UseHTTPConfig(p.smdHTTP); err != nil {
+			return fmt.Errorf("failed to configure SMD client: %w", err)
+		}
+
 		// Create cache
 		p.cache, err = cache.NewCache(log, p.cacheDuration, p.smdClient)
 		if err != nil {
 			return fmt.Errorf("failed to create cache: %w", err)
 		}
 
+		p.cache.FullResync = p.fullResync
+		p.cache.SnapshotPath = p.cacheSnapshot
+
+		// Rebuild the record index whenever the cache changes so that
+		// queries don't have to
+		p.cache.OnUpdate = func() { p.rebuildIndex() }
+
 		// Start cache refresh loop
 		p.cache.RefreshLoop()
 
+		// Apply change notifications as they arrive, in addition to polling
+		if p.eventListen != "" {
+			token, err := smdclient.NewFileTokenSource(p.eventToken)
+			if err != nil {
+				return fmt.Errorf("failed to read event webhook token: %w", err)
+			}
+			p.cache.EventToken = token
+			if p.eventServer, err = p.cache.ListenEvents(p.eventListen); err != nil {
+				return err
+			}
+		}
+		if p.eventFeed != "" {
+			go p.cache.WatchEvents(ctx, p.eventFeed)
+		}
+
 		log.Infof("coresmd cache initialized with base URL %s and validity duration %s",
 			p.smdClient.BaseURL, p.cache.Duration.String())
 	}
 
-	// Set default zones if none configured
-	if len(p.zones) == 0 {
-		p.zones = []Zone{
-			{
-				Name:        "cluster.local",
-				NodePattern: "nid{04d}",
-			},
-		}
-	} else {
-		log.Infof("configured zones: %v", p.zones)
-	}
 	// Log cache initialization
 	log.Infof("coresmd cache initialized with %d zones", len(p.zones))
 
@@ -6,10 +6,18 @@
 package plugin
 
 import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
+	"github.com/miekg/dns"
 )
 
 // parseCorefile is a generic test function that accepts a full Corefile as a multiline string
@@ -64,6 +72,596 @@ func TestParseBasicConfiguration(t *testing.T) {
 	}
 }
 
+func TestParseCacheFullResync(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		cache_duration 30s
+		cache_full_resync 15m
+		cache_snapshot /var/lib/coresmd/cache.json
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plugin.fullResync != 15*time.Minute {
+		t.Errorf("Expected cache_full_resync to be 15m, got %s", plugin.fullResync)
+	}
+	if plugin.cacheSnapshot != "/var/lib/coresmd/cache.json" {
+		t.Errorf("Expected cache_snapshot to be '/var/lib/coresmd/cache.json', got '%s'", plugin.cacheSnapshot)
+	}
+
+	corefile = `coresmd {
+		smd_url https://smd.cluster.local
+		cache_full_resync often
+	}`
+	c = caddy.NewTestController("dns", corefile)
+	if _, err := parse(c); err == nil {
+		t.Fatal("Expected error for invalid cache_full_resync, got none")
+	}
+}
+
+func TestParseEventSources(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		event_listen 127.0.0.1:8090
+		event_token_file /etc/coresmd/event-token
+		event_feed https://smd.cluster.local/hsm/v2/events
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plugin.eventListen != "127.0.0.1:8090" {
+		t.Errorf("Expected event_listen to be '127.0.0.1:8090', got '%s'", plugin.eventListen)
+	}
+	if plugin.eventToken != "/etc/coresmd/event-token" {
+		t.Errorf("Expected event_token_file to be '/etc/coresmd/event-token', got '%s'", plugin.eventToken)
+	}
+	if plugin.eventFeed != "https://smd.cluster.local/hsm/v2/events" {
+		t.Errorf("Expected event_feed to be 'https://smd.cluster.local/hsm/v2/events', got '%s'", plugin.eventFeed)
+	}
+
+	corefile = `coresmd {
+		smd_url https://smd.cluster.local
+		event_listen 127.0.0.1:8090
+	}`
+	c = caddy.NewTestController("dns", corefile)
+	if _, err := parse(c); err == nil {
+		t.Fatal("Expected error for event_listen without event_token_file, got none")
+	}
+
+	corefile = `coresmd {
+		smd_url https://smd.cluster.local
+		event_listen 8090
+	}`
+	c = caddy.NewTestController("dns", corefile)
+	if _, err := parse(c); err == nil {
+		t.Fatal("Expected error for invalid event_listen, got none")
+	}
+}
+
+func TestParseAuth(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		token_url https://auth.cluster.local/oauth2/token
+		client_id coredns
+		client_secret_file /run/secrets/coredns
+		token_scopes smd.read smd.events
+		client_cert /etc/coredns/client.crt
+		client_key /etc/coredns/client.key
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plugin.auth.TokenURL != "https://auth.cluster.local/oauth2/token" || plugin.auth.ClientID != "coredns" || plugin.auth.ClientSecretFile != "/run/secrets/coredns" {
+		t.Errorf("Unexpected auth config: %+v", plugin.auth)
+	}
+	if len(plugin.auth.Scopes) != 2 || plugin.auth.Scopes[1] != "smd.events" {
+		t.Errorf("Expected token_scopes [smd.read smd.events], got %v", plugin.auth.Scopes)
+	}
+	if plugin.auth.ClientCert != "/etc/coredns/client.crt" || plugin.auth.ClientKey != "/etc/coredns/client.key" {
+		t.Errorf("Unexpected client cert/key: %q %q", plugin.auth.ClientCert, plugin.auth.ClientKey)
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			token_file /run/secrets/token
+			token_url https://auth.cluster.local/token
+			client_id coredns
+			client_secret_file /run/secrets/coredns
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			client_cert /etc/coredns/client.crt
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			token_url /token
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid authentication config:\n%s", corefile)
+		}
+	}
+}
+
+func TestParseSMDHTTP(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		smd_timeout 45s
+		smd_retries 4
+		smd_retry_backoff 1s
+		smd_keepalive true
+		smd_max_idle_conns 2
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	h := plugin.smdHTTP
+	if h.Timeout == nil || *h.Timeout != 45*time.Second {
+		t.Errorf("Expected smd_timeout 45s, got %v", h.Timeout)
+	}
+	if h.Retries == nil || *h.Retries != 4 {
+		t.Errorf("Expected smd_retries 4, got %v", h.Retries)
+	}
+	if h.RetryBackoff == nil || *h.RetryBackoff != time.Second {
+		t.Errorf("Expected smd_retry_backoff 1s, got %v", h.RetryBackoff)
+	}
+	if h.KeepAlive == nil || !*h.KeepAlive || h.MaxIdleConns == nil || *h.MaxIdleConns != 2 {
+		t.Errorf("Expected smd_keepalive true and smd_max_idle_conns 2, got %v %v", h.KeepAlive, h.MaxIdleConns)
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			smd_timeout soon
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			smd_retries -1
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			smd_keepalive false
+			smd_max_idle_conns 4
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid SMD client config:\n%s", corefile)
+		}
+	}
+}
+
+func TestParseZoneTypes(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			nodes nid{04d}
+			type NodeBMC
+			type MgmtSwitch|MgmtHLSwitch sw-{id}
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(plugin.zones) != 1 {
+		t.Fatalf("Expected 1 zone, got %d", len(plugin.zones))
+	}
+	records := plugin.zones[0].Records
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 record types, got %+v", records)
+	}
+	if !records[0].Types["NodeBMC"] || records[0].Pattern != "" {
+		t.Errorf("Unexpected first record type: %+v", records[0])
+	}
+	if !records[1].Types["MgmtSwitch"] || !records[1].Types["MgmtHLSwitch"] || records[1].Pattern != "sw-{id}" {
+		t.Errorf("Unexpected second record type: %+v", records[1])
+	}
+	if plugin.zones[0].NodePattern != "nid{04d}" {
+		t.Errorf("Expected node pattern nid{04d}, got %q", plugin.zones[0].NodePattern)
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				type
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				type |
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				type Node nid{04d} extra
+			}
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid zone type:\n%s", corefile)
+		}
+	}
+}
+
+func TestParseAuthority(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		fallthrough in-addr.arpa ip6.arpa
+		zone cluster.local {
+			ns ns1.cluster.local ns2.example.com
+			hostmaster admin@example.com
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := plugin.Fall.Zones; len(got) != 2 || got[0] != "in-addr.arpa." || got[1] != "ip6.arpa." {
+		t.Errorf("Unexpected fallthrough zones: %v", got)
+	}
+	zone := plugin.zones[0]
+	if len(zone.NS) != 2 || zone.NS[0] != "ns1.cluster.local" || zone.NS[1] != "ns2.example.com" {
+		t.Errorf("Unexpected name servers: %v", zone.NS)
+	}
+	if zone.Hostmaster != "admin.example.com" {
+		t.Errorf("Expected hostmaster admin.example.com, got %q", zone.Hostmaster)
+	}
+
+	// Fallthrough without zones applies to all zones
+	c = caddy.NewTestController("dns", `coresmd {
+		smd_url https://smd.cluster.local
+		fallthrough
+	}`)
+	plugin, err = parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !plugin.Fall.Through("nid0001.cluster.local.") {
+		t.Error("Expected fallthrough for all zones")
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				ns
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				hostmaster
+			}
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid authority configuration:\n%s", corefile)
+		}
+	}
+}
+
+func TestParseReverse(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			reverse 10.1.0.0/16
+			reverse 192.168.1.64/26 64-26.1.168.192.in-addr.arpa
+			ns ns1.cluster.local
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	reverse := plugin.zones[0].Reverse
+	if len(reverse) != 2 {
+		t.Fatalf("Expected 2 reverse zones, got %+v", reverse)
+	}
+	if reverse[0].Name != "1.10.in-addr.arpa" || reverse[0].Prefix.String() != "10.1.0.0/16" {
+		t.Errorf("Unexpected first reverse zone: %+v", reverse[0])
+	}
+	if reverse[1].Name != "64-26.1.168.192.in-addr.arpa" || reverse[1].Prefix.String() != "192.168.1.64/26" {
+		t.Errorf("Unexpected second reverse zone: %+v", reverse[1])
+	}
+	for _, rz := range reverse {
+		if rz.Forward != "cluster.local" || len(rz.NS) != 1 || rz.NS[0] != "ns1.cluster.local." {
+			t.Errorf("Reverse zone %s doesn't use the forward zone's settings: %+v", rz.Name, rz)
+		}
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				reverse
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				reverse 10.1.0.0
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				reverse 10.0.0.0/23 0.10.in-addr.arpa
+			}
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				reverse 10.1.0.0/16
+			}
+			zone other.local {
+				reverse 10.1.0.0/16
+			}
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid reverse zone:\n%s", corefile)
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			ttl 5m
+			ttl PTR 3600
+			ttl aaaa 30s
+			cap_ttl_to_refresh
+			reverse 10.1.0.0/16
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	zone := plugin.zones[0]
+	want := map[uint16]uint32{dns.TypeNone: 300, dns.TypePTR: 3600, dns.TypeAAAA: 30}
+	if fmt.Sprint(zone.TTLs) != fmt.Sprint(want) {
+		t.Errorf("TTLs = %v, want %v", zone.TTLs, want)
+	}
+	if !zone.CapTTL {
+		t.Error("Expected CapTTL to be set")
+	}
+	if rz := zone.Reverse[0]; rz.ttl(dns.TypePTR) != 3600 || !rz.CapTTL {
+		t.Errorf("Reverse zone doesn't use the forward zone's TTLs: %+v", rz)
+	}
+
+	invalid := []string{
+		"ttl",
+		"ttl FOO 30",
+		"ttl -1",
+		"ttl 1.5s",
+		"ttl 4294967296",
+		"ttl A 30 40",
+		"cap_ttl_to_refresh yes",
+	}
+	for _, line := range invalid {
+		corefile := fmt.Sprintf(`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				%s
+			}
+		}`, line)
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestParseComponentFilters(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			filter enabled:true
+			type Node mgmt{03d} role:Management
+			type Node nid{04d}
+			type NodeBMC state:Ready|On
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	zone := plugin.zones[0]
+	if zone.Filter.Enabled == nil || !*zone.Filter.Enabled {
+		t.Errorf("Unexpected zone filter: %v", zone.Filter)
+	}
+	if len(zone.Records) != 3 {
+		t.Fatalf("Expected 3 record types, got %d", len(zone.Records))
+	}
+	if zone.Records[0].Pattern != "mgmt{03d}" || !zone.Records[0].Filter.Roles["management"] {
+		t.Errorf("Unexpected record type: %+v", zone.Records[0])
+	}
+	if zone.Records[1].Pattern != "nid{04d}" || !zone.Records[1].Filter.IsZero() {
+		t.Errorf("Unexpected record type: %+v", zone.Records[1])
+	}
+	if zone.Records[2].Pattern != "" || !zone.Records[2].Filter.States["on"] {
+		t.Errorf("Unexpected record type: %+v", zone.Records[2])
+	}
+
+	invalid := []string{
+		"filter",
+		"filter enabled:maybe",
+		"filter hostname:x",
+		"filter subnet:10.0.0.0/8",
+		"filter enabled:true role:Compute",
+		"filter enabled:true\n\t\t\t\tfilter role:Compute",
+		"type Node nid{04d} role:",
+		"type Node nid{04d} extra role:Compute",
+	}
+	for _, line := range invalid {
+		corefile := fmt.Sprintf(`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				%s
+			}
+		}`, line)
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestParseInventoryRecords(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			cname nid{04d}
+			cname sw-{id} MgmtSwitch|MgmtHLSwitch
+			srv _redfish._tcp 443
+			srv _ssh._tcp 22 Node|NodeBMC
+			txt type nid id
+		}
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	zone := plugin.zones[0]
+	if len(zone.CNAMEs) != 2 || zone.CNAMEs[0].Pattern != "nid{04d}" || !zone.CNAMEs[0].Types["Node"] ||
+		zone.CNAMEs[1].Pattern != "sw-{id}" || !zone.CNAMEs[1].Types["MgmtHLSwitch"] {
+		t.Errorf("Unexpected CNAMEs: %+v", zone.CNAMEs)
+	}
+	if len(zone.SRVs) != 2 || zone.SRVs[0].Service != "_redfish._tcp" || zone.SRVs[0].Port != 443 || !zone.SRVs[0].Types["NodeBMC"] ||
+		zone.SRVs[1].Port != 22 || !zone.SRVs[1].Types["Node"] || !zone.SRVs[1].Types["NodeBMC"] {
+		t.Errorf("Unexpected SRVs: %+v", zone.SRVs)
+	}
+	if strings.Join(zone.TXT, " ") != "type nid id" {
+		t.Errorf("Unexpected TXT keys: %v", zone.TXT)
+	}
+
+	// TXT records default to type and NID
+	c = caddy.NewTestController("dns", `coresmd {
+		smd_url https://smd.cluster.local
+		zone cluster.local {
+			txt
+		}
+	}`)
+	plugin, err = parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(plugin.zones[0].TXT, " ") != "type nid" {
+		t.Errorf("Unexpected default TXT keys: %v", plugin.zones[0].TXT)
+	}
+
+	invalid := []string{
+		"cname",
+		"cname nid{04d} |",
+		"srv _redfish._tcp",
+		"srv redfish._tcp 443",
+		"srv _redfish 443",
+		"srv _redfish._tcp 0",
+		"srv _redfish._tcp 65536",
+		"txt color",
+	}
+	for _, line := range invalid {
+		corefile := fmt.Sprintf(`coresmd {
+			smd_url https://smd.cluster.local
+			zone cluster.local {
+				%s
+			}
+		}`, line)
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	corefile := `coresmd {
+		smd_url https://smd.cluster.local
+		domain cluster.local
+		rule type:Node,hostname:nid{04d}
+		rule "id_set:'x3000c0s[1-4]b0,x3001c0s1b0',hostname:{id},domain:bmc.cluster.local"
+	}`
+
+	c := caddy.NewTestController("dns", corefile)
+	plugin, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plugin.domain != "cluster.local" {
+		t.Errorf("Expected domain cluster.local, got %q", plugin.domain)
+	}
+	if len(plugin.rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(plugin.rules))
+	}
+	if plugin.rules[0].Action.Hostname != "nid{04d}" || !plugin.rules[0].Match.Types["Node"] {
+		t.Errorf("Unexpected first rule: %s", plugin.rules[0])
+	}
+	if plugin.rules[1].Match.IDSet == nil || !plugin.rules[1].Match.IDSet.Match("x3001c0s1b0") {
+		t.Errorf("Unexpected second rule: %s", plugin.rules[1])
+	}
+
+	invalid := []string{
+		`coresmd {
+			smd_url https://smd.cluster.local
+			rule
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			rule type:Node
+		}`,
+		`coresmd {
+			smd_url https://smd.cluster.local
+			rule type:Node, hostname:nid{04d}
+		}`,
+	}
+	for _, corefile := range invalid {
+		c := caddy.NewTestController("dns", corefile)
+		if _, err := parse(c); err == nil {
+			t.Errorf("Expected error for invalid rule:\n%s", corefile)
+		}
+	}
+}
+
 func TestParseConfigurationWithMultipleZones(t *testing.T) {
 	corefile := `
 .:1053 {
@@ -233,6 +831,7 @@ func TestParseConfigurationMissingArgument(t *testing.T) {
 
 func TestPluginOnStartup(t *testing.T) {
 	plugin := &Plugin{
+		index:         &indexHolder{},
 		smdURL:        "https://smd.cluster.local",
 		cacheDuration: "30s",
 		zones: []Zone{
@@ -250,6 +849,41 @@ func TestPluginOnStartup(t *testing.T) {
 	}
 }
 
+func TestPluginOnShutdown(t *testing.T) {
+	smd := httptest.NewServer(http.NotFoundHandler())
+	defer smd.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "event-token")
+	if err := os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// A new instance (as after a Corefile reload) must be able to listen on
+	// the same address once the previous one has shut down
+	for i := range 2 {
+		p := &Plugin{
+			index:         &indexHolder{},
+			smdURL:        smd.URL,
+			cacheDuration: "30s",
+			eventListen:   addr,
+			eventToken:    tokenFile,
+			eventFeed:     "/hsm/v2/events",
+		}
+		if err := p.OnStartup(); err != nil {
+			t.Fatalf("instance %d: OnStartup() unexpected error: %v", i, err)
+		}
+		if err := p.OnShutdown(); err != nil {
+			t.Fatalf("instance %d: OnShutdown() unexpected error: %v", i, err)
+		}
+	}
+}
+
 func TestPluginName(t *testing.T) {
 	plugin := &Plugin{}
 	if plugin.Name() != "coresmd" {
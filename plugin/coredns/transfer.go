@@ -0,0 +1,201 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/miekg/dns"
+)
+
+// maxZoneDeltas is the number of changes to each zone kept for IXFR. Secondaries
+// that are further behind get a full transfer.
+const maxZoneDeltas = 16
+
+// zoneContent holds the address records of a zone for one version of the
+// index, along with the recent changes that led to it.
+type zoneContent struct {
+	serial  uint32
+	records []dns.RR    // records other than SOA and NS, sorted by their text form
+	deltas  []zoneDelta // changes to the zone, oldest first
+}
+
+// zoneDelta is a change to a zone between two serials.
+type zoneDelta struct {
+	from, to uint32
+	removed  []dns.RR
+	added    []dns.RR
+}
+
+// zoneContents groups the records in idx by the zone that contains them. Each
+// name belongs to the most specific configured zone containing it. All zones
+// start at the given serial.
+func (p *Plugin) zoneContents(idx *recordIndex, serial uint32) map[string]*zoneContent {
+	zm := p.zm
+	zones := make(map[string]*zoneContent, len(zm.zones))
+	for _, z := range zm.zones {
+		zones[z.origin()] = &zoneContent{serial: serial}
+	}
+	add := func(z *Zone, rrs ...dns.RR) {
+		zc := zones[z.origin()]
+		for _, rr := range rrs {
+			rr.Header().Ttl = z.ttl(rr.Header().Rrtype)
+			zc.records = append(zc.records, rr)
+		}
+	}
+	for name := range idx.names {
+		if z := zm.FindZone(name); z != nil {
+			add(z, idx.lookupRecords(dns.Fqdn(name), name, dns.TypeANY)...)
+			if target, ok := idx.ptr[name]; ok {
+				add(z, ptrRecord(dns.Fqdn(name), target))
+			}
+		}
+	}
+	for _, zc := range zones {
+		sort.Slice(zc.records, func(i, j int) bool {
+			return zc.records[i].String() < zc.records[j].String()
+		})
+	}
+	return zones
+}
+
+// carryOver continues the serials and change history of the zones in prev,
+// the index idx replaces. Zones whose contents are unchanged keep their
+// serial; others get a new, larger serial and a delta for IXFR. The origins of
+// the changed zones are returned.
+func (idx *recordIndex) carryOver(prev *recordIndex) []string {
+	var changed []string
+	for origin, zc := range idx.zones {
+		old, ok := prev.zones[origin]
+		if !ok {
+			continue
+		}
+		removed, added := diffRecords(old.records, zc.records)
+		if len(removed) == 0 && len(added) == 0 {
+			zc.serial = old.serial
+			zc.deltas = old.deltas
+			continue
+		}
+
+		// Serials are timestamps, but must increase even if the contents
+		// change more than once a second
+		if zc.serial <= old.serial {
+			zc.serial = old.serial + 1
+		}
+		deltas := old.deltas
+		if len(deltas) >= maxZoneDeltas {
+			deltas = deltas[len(deltas)-maxZoneDeltas+1:]
+		}
+		zc.deltas = append(append([]zoneDelta(nil), deltas...), zoneDelta{
+			from:    old.serial,
+			to:      zc.serial,
+			removed: removed,
+			added:   added,
+		})
+		changed = append(changed, origin)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// diffRecords returns the records in old but not in cur and those in cur but
+// not in old. Both must be sorted by their text form.
+func diffRecords(old, cur []dns.RR) (removed, added []dns.RR) {
+	i, j := 0, 0
+	for i < len(old) || j < len(cur) {
+		switch {
+		case j == len(cur):
+			removed = append(removed, old[i])
+			i++
+		case i == len(old):
+			added = append(added, cur[j])
+			j++
+		default:
+			a, b := old[i].String(), cur[j].String()
+			switch {
+			case a == b:
+				i++
+				j++
+			case a < b:
+				removed = append(removed, old[i])
+				i++
+			default:
+				added = append(added, cur[j])
+				j++
+			}
+		}
+	}
+	return removed, added
+}
+
+// serial returns the current SOA serial of zone z.
+func (idx *recordIndex) serial(z Zone) uint32 {
+	if zc, ok := idx.zones[z.origin()]; ok {
+		return zc.serial
+	}
+	return 0
+}
+
+// Transfer implements transfer.Transferer so that the transfer plugin can
+// serve AXFR and IXFR for the configured zones. For an IXFR from a serial the
+// plugin still has the changes since, the differences are sent; otherwise
+// the whole zone is.
+func (p *Plugin) Transfer(zone string, serial uint32) (<-chan []dns.RR, error) {
+	var z *Zone
+	for i := range p.zm.zones {
+		if p.zm.zones[i].origin() == strings.ToLower(dns.Fqdn(zone)) {
+			z = &p.zm.zones[i]
+			break
+		}
+	}
+	if z == nil {
+		return nil, transfer.ErrNotAuthoritative
+	}
+	if p.cache == nil {
+		return nil, errors.New("SMD cache not initialized")
+	}
+
+	idx := p.records()
+	zc := idx.zones[z.origin()]
+	soa := z.soa(zc.serial, p.refreshInterval())
+
+	ch := make(chan []dns.RR, 1)
+	go func() {
+		defer close(ch)
+
+		// Up to date (serial is the same as or newer than ours)
+		if serial != 0 && int32(serial-zc.serial) >= 0 {
+			ch <- []dns.RR{soa}
+			return
+		}
+
+		// Incremental transfer, if the secondary's serial is in the history
+		if serial != 0 {
+			for i, d := range zc.deltas {
+				if d.from != serial {
+					continue
+				}
+				ch <- []dns.RR{soa}
+				for _, d := range zc.deltas[i:] {
+					ch <- append([]dns.RR{z.soa(d.from, p.refreshInterval())}, d.removed...)
+					ch <- append([]dns.RR{z.soa(d.to, p.refreshInterval())}, d.added...)
+				}
+				ch <- []dns.RR{soa}
+				return
+			}
+		}
+
+		// Full transfer
+		ch <- append([]dns.RR{soa}, z.ns()...)
+		if len(zc.records) > 0 {
+			ch <- zc.records
+		}
+		ch <- []dns.RR{soa}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: Copyright 2026 OpenCHAMI a Series of LF Projects, LLC
+//
+// SPDX-License-Identifier: MIT
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/transfer"
+	"github.com/miekg/dns"
+
+	"github.com/openchami/coresmd/internal/smdclient"
+)
+
+// collectTransfer returns all records sent by Transfer.
+func collectTransfer(t *testing.T, p *Plugin, zone string, serial uint32) []dns.RR {
+	t.Helper()
+
+	ch, err := p.Transfer(zone, serial)
+	if err != nil {
+		t.Fatalf("Transfer(%s, %d) unexpected error: %v", zone, serial, err)
+	}
+	var rrs []dns.RR
+	for batch := range ch {
+		rrs = append(rrs, batch...)
+	}
+	return rrs
+}
+
+// rrStrings returns the text form of each record with the SOA serial replaced
+// by its position in serials, so that expectations don't depend on the time.
+func rrStrings(rrs []dns.RR, serials ...uint32) []string {
+	var out []string
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			for i, s := range serials {
+				if soa.Serial == s {
+					out = append(out, "SOA "+string(rune('A'+i)))
+				}
+			}
+			continue
+		}
+		out = append(out, rr.String())
+	}
+	return out
+}
+
+// changeNodeAddress changes the address of node001 the way a cache refresh
+// would.
+func changeNodeAddress(p *Plugin, ip string) {
+	p.cache.Mutex.Lock()
+	defer p.cache.Mutex.Unlock()
+	ei := p.cache.EthernetInterfaces["00:11:22:33:44:55"]
+	ei.IPAddresses = []smdclient.IPAddress{{IPAddress: ip}}
+	p.cache.EthernetInterfaces["00:11:22:33:44:55"] = ei
+	p.cache.Generation++
+}
+
+func TestTransfer_AXFR(t *testing.T) {
+	p := createTestPlugin()
+
+	rrs := collectTransfer(t, p, "Cluster.Local.", 0)
+	serial := p.records().serial(p.zones[0])
+	want := []string{
+		"SOA A",
+		"cluster.local.\t60\tIN\tNS\tns.cluster.local.",
+		"bmc001.cluster.local.\t60\tIN\tA\t192.168.1.100",
+		"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.10",
+		"node001.cluster.local.\t60\tIN\tA\t192.168.1.10",
+		"SOA A",
+	}
+	if got := rrStrings(rrs, serial); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("AXFR records =\n%v\nwant\n%v", got, want)
+	}
+
+	if _, err := p.Transfer("example.com.", 0); !errors.Is(err, transfer.ErrNotAuthoritative) {
+		t.Errorf("Transfer(example.com.) error = %v, want ErrNotAuthoritative", err)
+	}
+}
+
+func TestTransfer_IXFR(t *testing.T) {
+	p := createTestPlugin()
+
+	first := p.records().serial(p.zones[0])
+
+	// Rebuilding without changes keeps the serial
+	p.cache.Mutex.Lock()
+	p.cache.Generation++
+	p.cache.Mutex.Unlock()
+	if got := p.records().serial(p.zones[0]); got != first {
+		t.Fatalf("serial changed from %d to %d without changes", first, got)
+	}
+
+	changeNodeAddress(p, "192.168.1.11")
+	second := p.records().serial(p.zones[0])
+	if second <= first {
+		t.Fatalf("serial %d not greater than %d after change", second, first)
+	}
+	changeNodeAddress(p, "192.168.1.12")
+	third := p.records().serial(p.zones[0])
+	if third <= second {
+		t.Fatalf("serial %d not greater than %d after change", third, second)
+	}
+
+	tests := []struct {
+		name   string
+		serial uint32
+		want   []string
+	}{
+		{
+			name:   "up to date",
+			serial: third,
+			want:   []string{"SOA C"},
+		},
+		{
+			name:   "one change behind",
+			serial: second,
+			want: []string{
+				"SOA C",
+				"SOA B",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"SOA C",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"SOA C",
+			},
+		},
+		{
+			name:   "two changes behind",
+			serial: first,
+			want: []string{
+				"SOA C",
+				"SOA A",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.10",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.10",
+				"SOA B",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"SOA B",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.11",
+				"SOA C",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"SOA C",
+			},
+		},
+		{
+			name:   "unknown serial falls back to AXFR",
+			serial: first - 100,
+			want: []string{
+				"SOA C",
+				"cluster.local.\t60\tIN\tNS\tns.cluster.local.",
+				"bmc001.cluster.local.\t60\tIN\tA\t192.168.1.100",
+				"nid0001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"node001.cluster.local.\t60\tIN\tA\t192.168.1.12",
+				"SOA C",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rrs := collectTransfer(t, p, "cluster.local.", tt.serial)
+			if got := rrStrings(rrs, first, second, third); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("IXFR records =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeDNS_AXFR_Refused(t *testing.T) {
+	p := createTestPlugin()
+	mock := &mockHandler{}
+	p.Next = mock
+
+	req := new(dns.Msg)
+	req.SetAxfr("cluster.local.")
+	w := &mockResponseWriter{}
+	rcode, err := p.ServeDNS(context.Background(), w, req)
+	if err != nil {
+		t.Fatalf("ServeDNS failed: %v", err)
+	}
+	if rcode != dns.RcodeRefused {
+		t.Errorf("rcode = %s, want REFUSED", dns.RcodeToString[rcode])
+	}
+	if mock.called {
+		t.Error("Expected next plugin not to be called")
+	}
+}
@@ -5,12 +5,128 @@
 
 package plugin
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/openchami/coresmd/internal/hostname"
+	"github.com/openchami/coresmd/internal/iface"
+	"github.com/openchami/coresmd/internal/rule"
+	"github.com/openchami/coresmd/internal/smdclient"
+)
 
 // Zone represents a DNS zone configuration
 type Zone struct {
-	Name        string // Zone name (e.g., "cluster.local")
-	NodePattern string // Pattern for node records (e.g., "nid{04d}.cluster.local")
+	Name        string        // Zone name (e.g., "cluster.local")
+	NodePattern string        // Pattern for node records (e.g., "nid{04d}")
+	Records     []RecordType  // Component types published in the zone (Node and NodeBMC if empty)
+	NS          []string      // Name servers published at the apex (ns.<zone> if empty)
+	Hostmaster  string        // SOA RNAME (hostmaster.<zone> if empty)
+	Reverse     []ReverseZone // Reverse zones whose PTR targets are in this zone
+	Filter      rule.Match    // Components published in the zone (all if zero)
+
+	TTLs   map[uint16]uint32 // TTLs by record type; dns.TypeNone is the default for all types (recordTTL if unset)
+	CapTTL bool              // Cap TTLs at the time until the next cache refresh
+
+	CNAMEs []RecordType    // Aliases (expanded patterns) published as CNAMEs to the xname
+	SRVs   []ServiceRecord // Services published as SRV records
+	TXT    []string        // Metadata keys published in TXT records at the xname
+}
+
+// RecordType selects SMD component types published in a zone and the hostname
+// pattern used for them. Types are matched the same way as the CoreDHCP rule
+// "type" key: exactly, against any of the listed types.
+type RecordType struct {
+	Types   map[string]bool // component types to publish ("*" matches all types)
+	Pattern string          // hostname pattern published in addition to the xname, if set
+	Filter  rule.Match      // further criteria components must meet (none if zero)
+}
+
+// Matches returns true if the component described by info is published by rt.
+func (rt RecordType) Matches(info iface.IfaceInfo) bool {
+	return (rt.Types["*"] || rt.Types[info.Type]) && rt.Filter.Matches(info)
+}
+
+// ServiceRecord publishes SRV records for a service offered by components of
+// the given types, such as Redfish on BMCs.
+type ServiceRecord struct {
+	Service string          // Service and protocol labels (e.g. "_redfish._tcp")
+	Port    uint16          // Port the service listens on
+	Types   map[string]bool // Component types offering the service ("*" matches all types)
+}
+
+// Matches returns true if components of type typ offer the service.
+func (sr ServiceRecord) Matches(typ string) bool {
+	return sr.Types["*"] || sr.Types[typ]
+}
+
+// txtKeys are the metadata keys that can be published in TXT records.
+var txtKeys = []string{"id", "type", "nid", "role", "subrole", "state", "flag", "enabled", "arch", "class"}
+
+// txtValue returns the value of metadata key for comp, and false if comp has
+// no value for it.
+func txtValue(comp smdclient.Component, key string) (string, bool) {
+	switch key {
+	case "id":
+		return comp.ID, true
+	case "type":
+		return comp.Type, comp.Type != ""
+	case "nid":
+		return strconv.FormatInt(comp.NID, 10), comp.NID != 0
+	case "role":
+		return comp.Role, comp.Role != ""
+	case "subrole":
+		return comp.SubRole, comp.SubRole != ""
+	case "state":
+		return comp.State, comp.State != ""
+	case "flag":
+		return comp.Flag, comp.Flag != ""
+	case "enabled":
+		return strconv.FormatBool(comp.IsEnabled()), true
+	case "arch":
+		return comp.Arch, comp.Arch != ""
+	case "class":
+		return comp.Class, comp.Class != ""
+	}
+	return "", false
+}
+
+// recordTypes returns the component types published in the zone, in order of
+// precedence. Without explicit record types, Nodes (with NodePattern) and
+// NodeBMCs are published. A NodePattern set alongside explicit record types
+// takes precedence for Nodes.
+func (z Zone) recordTypes() []RecordType {
+	node := RecordType{Types: map[string]bool{"Node": true}, Pattern: z.NodePattern}
+	if len(z.Records) == 0 {
+		return []RecordType{node, {Types: map[string]bool{"NodeBMC": true}}}
+	}
+	if z.NodePattern != "" {
+		return append([]RecordType{node}, z.Records...)
+	}
+	return z.Records
+}
+
+// hostnames returns the host names (relative to the zone) published for comp,
+// described by info, or nil if it is not published in the zone. The xname is
+// always published, followed by the expanded pattern of the first matching
+// record type, if any.
+func (z Zone) hostnames(comp smdclient.Component, info iface.IfaceInfo) []string {
+	if !z.Filter.Matches(info) {
+		return nil
+	}
+	for _, rt := range z.recordTypes() {
+		if !rt.Matches(info) {
+			continue
+		}
+		names := []string{comp.ID}
+		if rt.Pattern != "" {
+			if h := hostname.ExpandHostnamePattern(rt.Pattern, comp.NID, comp.ID); h != comp.ID {
+				names = append(names, h)
+			}
+		}
+		return names
+	}
+	return nil
 }
 
 // ZoneManager handles zone operations and record lookups
@@ -25,14 +141,16 @@ func NewZoneManager(zones []Zone) *ZoneManager {
 	}
 }
 
-// FindZone finds the appropriate zone for a given domain name
+// FindZone finds the appropriate zone for a given domain name, which is the
+// most specific zone containing it
 func (zm *ZoneManager) FindZone(domain string) *Zone {
+	var found *Zone
 	for i := range zm.zones {
-		if isSubdomain(domain, zm.zones[i].Name) {
-			return &zm.zones[i]
+		if isSubdomain(domain, zm.zones[i].Name) && (found == nil || len(zm.zones[i].Name) > len(found.Name)) {
+			found = &zm.zones[i]
 		}
 	}
-	return nil
+	return found
 }
 
 // isSubdomain checks if domain is a subdomain of zone.